@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+// runCache manages the cache of --cache, returning the process exit code.
+func runCache(args []string, stdout io.Writer) int {
+	flags := flag.NewFlagSet("prettier cache", flag.ExitOnError)
+	cacheLocation := flags.String("cache-location", "", "Path to the cache file, defaulting to node_modules/.cache/prettier/.prettier-cache.")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: prettier cache [flags] clean|path")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	p := runner.CacheLocation(runner.RunArgs{CacheLocation: *cacheLocation})
+	switch flags.Arg(0) {
+	case "clean":
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Unable to remove cache: %v\n", err)
+			return 2
+		}
+		return 0
+	case "path":
+		fmt.Fprintln(stdout, p)
+		return 0
+	default:
+		flags.Usage()
+		return 2
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+// formatRequest is a request to format source as the contents of the file at
+// FilePath, which is used to infer the parser and resolve options.
+type formatRequest struct {
+	FilePath string `json:"filePath"`
+	Source   string `json:"source"`
+	// Options take precedence over the resolved options of the file.
+	Options map[string]any `json:"options,omitempty"`
+	// Range, if set, are the start and end byte offsets of the code to
+	// format, leaving the rest of the source as is.
+	Range *[2]int `json:"range,omitempty"`
+}
+
+// formatResponse is the result of a formatRequest. Like with --stdin-filepath,
+// the source is returned unchanged for ignored files.
+type formatResponse struct {
+	Formatted string `json:"formatted"`
+	Ignored   bool   `json:"ignored,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// formatService formats requests with a long-lived runner, so they don't pay
+// the cost of compiling prettier. Formatting with a context that can be done
+// runs a copy of the module compiled to stop when it is, which is slower and
+// compiled on first use, so servers pass context.Background() unless their
+// transport can cancel requests.
+type formatService struct {
+	r    *runner.Runner
+	args runner.RunArgs
+	// timeout, if set, fails requests over HTTP that take longer to format.
+	timeout time.Duration
+	// metrics are recorded for requests over HTTP.
+	metrics serveMetrics
+	// ready is set by warmUp once requests over HTTP don't pay the cost of
+	// compiling prettier.
+	ready atomic.Bool
+}
+
+func (s *formatService) format(ctx context.Context, req formatRequest) formatResponse {
+	if req.FilePath == "" {
+		return formatResponse{Error: "filePath is required"}
+	}
+	if ignored, _ := s.r.IsIgnored(s.args, req.FilePath); ignored {
+		return formatResponse{Formatted: req.Source, Ignored: true}
+	}
+
+	pCfg, err := s.r.ResolveConfig(ctx, s.args, req.FilePath)
+	if err != nil {
+		return formatResponse{Error: err.Error()}
+	}
+	if len(req.Options) > 0 {
+		pCfg = maps.Clone(pCfg)
+		maps.Copy(pCfg, req.Options)
+	}
+
+	var out []byte
+	if req.Range != nil {
+		out, err = s.r.FormatRange(ctx, req.FilePath, []byte(req.Source), req.Range[0], req.Range[1], pCfg)
+	} else {
+		out, err = s.r.Format(ctx, req.FilePath, []byte(req.Source), pCfg)
+	}
+	if err != nil {
+		return formatResponse{Error: err.Error()}
+	}
+	return formatResponse{Formatted: string(out)}
+}
+
+// runDaemon serves format requests on a unix socket until interrupted,
+// returning the process exit code. Each connection sends requests as JSON
+// values and receives a JSON response for each, in order.
+func runDaemon(args []string) int {
+	fs := flag.NewFlagSet("prettier daemon", flag.ExitOnError)
+	socket := fs.String("socket", defaultDaemonSocket(), "Path to the unix socket to listen on.")
+	var rf runFlags
+	rf.register(fs)
+	_ = fs.Parse(args)
+	runArgs, err := rf.runArgs(nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	// A socket left behind by a daemon that didn't exit cleanly prevents
+	// listening, so remove it if nothing is listening on it.
+	if conn, err := net.Dial("unix", *socket); err == nil {
+		_ = conn.Close()
+		fmt.Fprintf(os.Stderr, "A daemon is already listening on %s\n", *socket)
+		return 2
+	}
+	_ = os.Remove(*socket)
+
+	l, err := net.Listen("unix", *socket)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to listen on %s: %v\n", *socket, err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		_ = l.Close()
+	}()
+
+	s := &formatService{r: newRunner(runner.WithLogger(rf.logger())), args: runArgs}
+	slog.Info(fmt.Sprintf("Listening on %s", *socket))
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return 0
+			}
+			fmt.Fprintf(os.Stderr, "Unable to accept connection: %v\n", err)
+			return 2
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *formatService) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	enc := json.NewEncoder(conn)
+	for {
+		var req formatRequest
+		if err := dec.Decode(&req); err != nil {
+			if !errors.Is(err, io.EOF) {
+				_ = enc.Encode(formatResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+			}
+			return
+		}
+		if err := enc.Encode(s.format(context.Background(), req)); err != nil {
+			return
+		}
+	}
+}
+
+// defaultDaemonSocket returns the path of the socket in the user cache
+// directory, which unlike the temp directory is not shared with other users.
+func defaultDaemonSocket() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "prettier.sock"
+	}
+	dir = filepath.Join(dir, "com.github.wasilibs")
+	_ = os.MkdirAll(dir, 0o755)
+	return filepath.Join(dir, "prettier.sock")
+}
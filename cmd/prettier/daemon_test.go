@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+func TestDaemon(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "prettier.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	s := &formatService{r: runner.NewRunner(runner.WithStderr(io.Discard)), args: runner.RunArgs{
+		IgnorePaths: []string{".prettierignore"},
+		FS: fstest.MapFS{
+			".prettierignore": {Data: []byte("ignored.js\n")},
+			".prettierrc":     {Data: []byte(`{"semi": false}`)},
+		},
+	}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serveConn(conn)
+		}
+	}()
+
+	conn, err := net.Dial("unix", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	dec := json.NewDecoder(bufio.NewReader(conn))
+
+	tests := []struct {
+		name string
+		req  formatRequest
+		want formatResponse
+	}{
+		{
+			name: "config",
+			req:  formatRequest{FilePath: "a.js", Source: "a  =  1;\n"},
+			want: formatResponse{Formatted: "a = 1\n"},
+		},
+		{
+			name: "options",
+			req:  formatRequest{FilePath: "a.js", Source: "a  =  1;\n", Options: map[string]any{"semi": true}},
+			want: formatResponse{Formatted: "a = 1;\n"},
+		},
+		{
+			name: "range",
+			req:  formatRequest{FilePath: "a.js", Source: "a  =  1;\nb  =  2;\n", Range: &[2]int{9, 17}},
+			want: formatResponse{Formatted: "a  =  1;\nb = 2\n"},
+		},
+		{
+			name: "ignored",
+			req:  formatRequest{FilePath: "ignored.js", Source: "a  =  1;\n"},
+			want: formatResponse{Formatted: "a  =  1;\n", Ignored: true},
+		},
+		{
+			name: "no path",
+			req:  formatRequest{Source: "a  =  1;\n"},
+			want: formatResponse{Error: "filePath is required"},
+		},
+	}
+
+	// Requests on a connection are answered in order.
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := json.NewEncoder(conn).Encode(tc.req); err != nil {
+				t.Fatal(err)
+			}
+			var got formatResponse
+			if err := dec.Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("parse error", func(t *testing.T) {
+		if err := json.NewEncoder(conn).Encode(formatRequest{FilePath: "a.js", Source: "function {"}); err != nil {
+			t.Fatal(err)
+		}
+		var got formatResponse
+		if err := dec.Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Error == "" || got.Formatted != "" {
+			t.Errorf("got %+v, want error", got)
+		}
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		if _, err := io.WriteString(conn, "not json\n"); err != nil {
+			t.Fatal(err)
+		}
+		var got formatResponse
+		if err := dec.Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Error == "" {
+			t.Errorf("got %+v, want error", got)
+		}
+		// The connection is closed after an invalid request.
+		if err := dec.Decode(&got); !errors.Is(err, io.EOF) {
+			t.Errorf("got %v, want EOF", err)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// hookMarker identifies pre-commit hooks written by install-hook, which it
+// can replace.
+const hookMarker = "# Installed by prettier install-hook."
+
+// runInstallHook writes a git pre-commit hook formatting staged files to the
+// repository of dir, or the working directory if empty, returning the process
+// exit code.
+func runInstallHook(args []string, dir string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("prettier install-hook", flag.ExitOnError)
+	command := fs.String("command", "", "Command the hook runs prettier with, defaulting to the path of this executable.")
+	force := fs.Bool("force", false, "Replace an existing pre-commit hook not installed by prettier.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: prettier install-hook [flags] [patterns...]")
+		fmt.Fprintln(fs.Output(), "The hook formats staged files matching the patterns, defaulting to all supported files.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	cmd := *command
+	if cmd == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to find the path of prettier: %v\n", err)
+			return 2
+		}
+		cmd = shellQuote(exe)
+	}
+
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	for i, p := range patterns {
+		patterns[i] = shellQuote(p)
+	}
+
+	// git resolves core.hooksPath, relative to dir.
+	gitCmd := exec.Command("git", "rev-parse", "--git-path", "hooks")
+	gitCmd.Dir = dir
+	out, err := gitCmd.Output()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to find git hooks directory: %v\n", err)
+		return 2
+	}
+	hooksDir := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(dir, hooksDir)
+	}
+	hook := filepath.Join(hooksDir, "pre-commit")
+
+	if existing, err := os.ReadFile(hook); err == nil && !bytes.Contains(existing, []byte(hookMarker)) && !*force {
+		fmt.Fprintf(os.Stderr, "A pre-commit hook already exists at %s, use --force to replace it.\n", hook)
+		return 2
+	}
+
+	// The hook runs in the root of the working tree.
+	script := fmt.Sprintf("#!/bin/sh\n%s\nexec %s --staged --write %s\n", hookMarker, cmd, strings.Join(patterns, " "))
+	if err := os.MkdirAll(hooksDir, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to create git hooks directory: %v\n", err)
+		return 2
+	}
+	if err := os.WriteFile(hook, []byte(script), 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write pre-commit hook: %v\n", err)
+		return 2
+	}
+	// WriteFile doesn't change the mode of existing files.
+	if err := os.Chmod(hook, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to make pre-commit hook executable: %v\n", err)
+		return 2
+	}
+
+	fmt.Fprintf(stdout, "Installed pre-commit hook at %s\n", hook)
+	return 0
+}
+
+// shellQuote quotes s as a single word for sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInstallHook(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	hook := filepath.Join(dir, ".git", "hooks", "pre-commit")
+
+	install := func(args ...string) int {
+		t.Helper()
+		var out bytes.Buffer
+		code := runInstallHook(args, dir, &out)
+		if code == 0 && !strings.Contains(out.String(), hook) {
+			t.Errorf("got output %q, want hook path %s", out.String(), hook)
+		}
+		return code
+	}
+	read := func() string {
+		t.Helper()
+		b, err := os.ReadFile(hook)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	if code := install("--command", "prettier"); code != 0 {
+		t.Fatalf("got exit code %d", code)
+	}
+	want := "#!/bin/sh\n" + hookMarker + "\nexec prettier --staged --write '.'\n"
+	if got := read(); got != want {
+		t.Errorf("got hook %q, want %q", got, want)
+	}
+	if fi, err := os.Stat(hook); err != nil {
+		t.Fatal(err)
+	} else if fi.Mode().Perm()&0o100 == 0 {
+		t.Errorf("got mode %v, want executable", fi.Mode())
+	}
+
+	// Hooks installed by prettier are overwritten.
+	if code := install("--command", "prettier", "src", "it's.md"); code != 0 {
+		t.Fatalf("reinstall: got exit code %d", code)
+	}
+	want = "#!/bin/sh\n" + hookMarker + "\nexec prettier --staged --write 'src' 'it'\\''s.md'\n"
+	if got := read(); got != want {
+		t.Errorf("reinstall: got hook %q, want %q", got, want)
+	}
+
+	// Other hooks are only replaced with --force.
+	other := "#!/bin/sh\nexec lint\n"
+	if err := os.WriteFile(hook, []byte(other), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code := install("--command", "prettier"); code != 2 {
+		t.Errorf("existing hook: got exit code %d, want 2", code)
+	}
+	if got := read(); got != other {
+		t.Errorf("existing hook: got hook %q, want it unchanged", got)
+	}
+	if code := install("--command", "prettier", "--force"); code != 0 {
+		t.Fatalf("force: got exit code %d", code)
+	}
+	want = "#!/bin/sh\n" + hookMarker + "\nexec prettier --staged --write '.'\n"
+	if got := read(); got != want {
+		t.Errorf("force: got hook %q, want %q", got, want)
+	}
+	// The mode of the replaced hook is changed too.
+	if fi, err := os.Stat(hook); err != nil {
+		t.Fatal(err)
+	} else if fi.Mode().Perm()&0o100 == 0 {
+		t.Errorf("force: got mode %v, want executable", fi.Mode())
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+// runFileInfo prints how the file at path is handled as JSON, like prettier's
+// --file-info.
+func runFileInfo(ctx context.Context, r *runner.Runner, args runner.RunArgs, path string, stdout io.Writer) int {
+	info, err := r.FileInfo(ctx, args, path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return printJSON(stdout, info)
+}
+
+// printJSON prints v as indented JSON.
+func printJSON(stdout io.Writer, v any) int {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Fprintln(stdout, string(b))
+	return 0
+}
+
+// runSupportInfo prints the languages and options supported by the embedded
+// prettier as JSON, like prettier's --support-info.
+func runSupportInfo(ctx context.Context, r *runner.Runner, stdout io.Writer) int {
+	info, err := r.SupportInfo(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return printJSON(stdout, info)
+}
+
+// runInfo prints information about prettier or how it handles files,
+// returning the process exit code.
+func runInfo(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("prettier info", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the version information as JSON.")
+	var rf runFlags
+	rf.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: prettier info [flags] version|support|file <path>|config <path>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	runArgs, err := rf.runArgs(nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	r := func() *runner.Runner {
+		return newRunner(runner.WithLogger(rf.logger()))
+	}
+
+	ctx := context.Background()
+	topic, path := fs.Arg(0), fs.Arg(1)
+	switch {
+	case topic == "version":
+		return runVersion(*asJSON, stdout)
+	case topic == "support":
+		return runSupportInfo(ctx, r(), stdout)
+	case topic == "file" && path != "":
+		return runFileInfo(ctx, r(), runArgs, path, stdout)
+	case topic == "config" && path != "":
+		return runFindConfigPath(r(), runArgs, path, stdout)
+	default:
+		fs.Usage()
+		return 2
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"testing/fstest"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+func TestFileInfo(t *testing.T) {
+	t.Parallel()
+
+	args := runner.RunArgs{
+		IgnorePaths: []string{".prettierignore"},
+		FS: fstest.MapFS{
+			".prettierignore": {Data: []byte("build\n")},
+			"a.ts":            {},
+			"build/out.js":    {},
+		},
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "a.ts", want: "{\n  \"ignored\": false,\n  \"inferredParser\": \"typescript\"\n}\n"},
+		{path: "build/out.js", want: "{\n  \"ignored\": true,\n  \"inferredParser\": \"babel\"\n}\n"},
+		{path: "a.txt", want: "{\n  \"ignored\": false,\n  \"inferredParser\": \"\"\n}\n"},
+	}
+
+	r := runner.NewRunner()
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			var out bytes.Buffer
+			if code := runFileInfo(context.Background(), r, args, tc.path, &out); code != 0 {
+				t.Fatalf("got exit code %d", code)
+			}
+			if got := out.String(); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSupportInfo(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	if code := runSupportInfo(context.Background(), runner.NewRunner(), &out); code != 0 {
+		t.Fatalf("got exit code %d", code)
+	}
+
+	var info struct {
+		Languages []struct {
+			Name       string   `json:"name"`
+			Parsers    []string `json:"parsers"`
+			Extensions []string `json:"extensions"`
+		} `json:"languages"`
+		Options []struct {
+			Name    string `json:"name"`
+			Default any    `json:"default"`
+		} `json:"options"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &info); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out.String(), err)
+	}
+
+	var markdown bool
+	for _, l := range info.Languages {
+		if l.Name == "Markdown" {
+			markdown = len(l.Parsers) > 0 && l.Parsers[0] == "markdown" && len(l.Extensions) > 0 && l.Extensions[0] == ".md"
+		}
+	}
+	if !markdown {
+		t.Errorf("markdown not in languages %+v", info.Languages)
+	}
+
+	var printWidth bool
+	for _, o := range info.Options {
+		if o.Name == "printWidth" {
+			printWidth = o.Default == float64(80)
+		}
+	}
+	if !printWidth {
+		t.Errorf("printWidth not in options %+v", info.Options)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/wasilibs/go-prettier/internal/diff"
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+// runInteractive formats the files matching args, showing the diff for each
+// changed file and prompting whether to write it.
+func runInteractive(ctx context.Context, r *runner.Runner, args runner.RunArgs, stdin io.Reader, stdout io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr := &prompter{in: bufio.NewReader(stdin), out: stdout}
+
+	// The runner formats ahead of the prompts so the next diff is usually
+	// ready by the time the user answers.
+	applyAll, quit := false, false
+	args.Review = func(path string, in []byte, out []byte) []byte {
+		switch {
+		case quit:
+			return nil
+		case applyAll:
+			return out
+		}
+
+		fmt.Fprint(stdout, diff.Unified(path, path, in, out))
+		switch pr.ask(fmt.Sprintf("Apply changes to %s [y,n,a,p,q,?]? ", path), "ynapq") {
+		case 'n':
+			return nil
+		case 'a':
+			applyAll = true
+		case 'p':
+			selected, q := pr.selectHunks(in, out)
+			if q {
+				quit = true
+				cancel()
+				return nil
+			}
+			return selected
+		case 'q':
+			quit = true
+			cancel()
+			return nil
+		}
+		return out
+	}
+
+	res, err := r.Run(ctx, args)
+	if !quit {
+		return err
+	}
+
+	// Files not processed after quitting are not errors.
+	var errs []error
+	if res != nil {
+		for _, f := range res.Files {
+			if f.Status == runner.StatusError {
+				errs = append(errs, f.Err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask prints prompt and reads answers until one of the single-character
+// choices is entered, printing help for any other input.
+func (p *prompter) ask(prompt string, choices string) byte {
+	for {
+		fmt.Fprint(p.out, prompt)
+		line, err := p.in.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if len(line) == 1 && strings.IndexByte(choices, line[0]) >= 0 {
+			return line[0]
+		}
+		if err != nil {
+			// Treat closed input like quitting so we never write without an answer.
+			fmt.Fprintln(p.out)
+			return 'q'
+		}
+		p.help(choices)
+	}
+}
+
+func (p *prompter) help(choices string) {
+	for _, c := range choices {
+		switch c {
+		case 'y':
+			fmt.Fprintln(p.out, "y - apply")
+		case 'n':
+			fmt.Fprintln(p.out, "n - skip")
+		case 'a':
+			fmt.Fprintln(p.out, "a - apply this and all remaining changes")
+		case 'p':
+			fmt.Fprintln(p.out, "p - choose individual patches")
+		case 'd':
+			fmt.Fprintln(p.out, "d - skip this and all remaining patches in the file")
+		case 'q':
+			fmt.Fprintln(p.out, "q - quit without applying further changes")
+		}
+	}
+}
+
+// selectHunks prompts for each hunk of the diff between in and out and returns
+// in with only the accepted hunks applied, or whether the user quit.
+func (p *prompter) selectHunks(in []byte, out []byte) ([]byte, bool) {
+	hunks := diff.Hunks(in, out, 3)
+
+	var accepted []diff.Hunk
+	for i, h := range hunks {
+		var sb strings.Builder
+		diff.WriteHunk(&sb, h)
+		fmt.Fprint(p.out, sb.String())
+
+		switch p.ask(fmt.Sprintf("(%d/%d) Apply this patch [y,n,a,d,q,?]? ", i+1, len(hunks)), "ynadq") {
+		case 'y':
+			accepted = append(accepted, h)
+		case 'a':
+			accepted = append(accepted, hunks[i:]...)
+			return diff.Apply(in, accepted), false
+		case 'd':
+			return diff.Apply(in, accepted), false
+		case 'q':
+			return nil, true
+		}
+	}
+
+	return diff.Apply(in, accepted), false
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+func TestInteractive(t *testing.T) {
+	t.Parallel()
+
+	// Formatting changes the headings on the first and last lines, far enough
+	// apart to be separate hunks.
+	twoHunks := "#  a\n\n" + strings.Repeat("text\n\n", 5) + "#  b\n"
+
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]string
+	}{
+		{
+			name:  "apply and skip",
+			input: "y\nn\n",
+			want: map[string]string{
+				"a.md": "# a\n",
+			},
+		},
+		{
+			name:  "apply all",
+			input: "a\n",
+			want: map[string]string{
+				"a.md": "# a\n",
+				"b.md": "# b\n",
+				"c.md": "# a\n\n" + strings.Repeat("text\n\n", 5) + "# b\n",
+			},
+		},
+		{
+			name:  "help then apply",
+			input: "x\ny\nn\n",
+			want: map[string]string{
+				"a.md": "# a\n",
+			},
+		},
+		{
+			name:  "quit",
+			input: "q\n",
+			want:  map[string]string{},
+		},
+		{
+			name:  "closed input",
+			input: "",
+			want:  map[string]string{},
+		},
+		{
+			name:  "first hunk",
+			input: "n\nn\np\ny\nn\n",
+			want: map[string]string{
+				"c.md": "# a\n\n" + strings.Repeat("text\n\n", 5) + "#  b\n",
+			},
+		},
+		{
+			name:  "last hunk",
+			input: "n\nn\np\nn\ny\n",
+			want: map[string]string{
+				"c.md": "#  a\n\n" + strings.Repeat("text\n\n", 5) + "# b\n",
+			},
+		},
+		{
+			name:  "all remaining hunks",
+			input: "n\nn\np\na\n",
+			want: map[string]string{
+				"c.md": "# a\n\n" + strings.Repeat("text\n\n", 5) + "# b\n",
+			},
+		},
+		{
+			name:  "no hunks",
+			input: "n\nn\np\nd\n",
+			want:  map[string]string{},
+		},
+		{
+			name:  "quit in hunks",
+			input: "n\nn\np\ny\nq\n",
+			want:  map[string]string{},
+		},
+	}
+
+	r := runner.NewRunner(runner.WithStderr(io.Discard))
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fsys := fstest.MapFS{
+				"a.md":         {Data: []byte("#  a\n")},
+				"b.md":         {Data: []byte("#  b\n")},
+				"c.md":         {Data: []byte(twoHunks)},
+				"formatted.md": {Data: []byte("# ok\n")},
+			}
+
+			var mu sync.Mutex
+			written := map[string]string{}
+			err := runInteractive(context.Background(), r, runner.RunArgs{
+				Patterns: []string{"."},
+				FS:       fsys,
+				Write:    true,
+				// Prompt in the order of the files.
+				Concurrency: 1,
+				WriteFile: func(path string, content []byte) error {
+					mu.Lock()
+					defer mu.Unlock()
+					// Unchanged files are written too.
+					if string(content) != string(fsys[path].Data) {
+						written[path] = string(content)
+					}
+					return nil
+				},
+			}, strings.NewReader(tc.input), io.Discard)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(written) != len(tc.want) {
+				t.Errorf("got written files %v, want %v", written, tc.want)
+			}
+			for path, want := range tc.want {
+				if got := written[path]; got != want {
+					t.Errorf("%s: got %q, want %q", path, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+// JSON-RPC 2.0 error codes, see https://www.jsonrpc.org/specification.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+// rpcRequest is a JSON-RPC request, or a notification if it has no ID.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+func (r *rpcRequest) isNotification() bool {
+	return len(r.ID) == 0
+}
+
+// rpcResponse is a JSON-RPC response, with either a result or an error.
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// newRPCResponse returns the response to the request with the given ID, with
+// result if err is nil. Errors other than *rpcError are internal errors.
+func newRPCResponse(id json.RawMessage, result any, err error) rpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	res := rpcResponse{JSONRPC: "2.0", ID: id}
+	if err != nil {
+		rErr, ok := err.(*rpcError)
+		if !ok {
+			rErr = &rpcError{Code: rpcInternalError, Message: err.Error()}
+		}
+		res.Error = rErr
+		return res
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		res.Error = &rpcError{Code: rpcInternalError, Message: err.Error()}
+		return res
+	}
+	res.Result = b
+	return res
+}
+
+// decodeParams unmarshals the params of a request into v.
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return &rpcError{Code: rpcInvalidParams, Message: "missing params"}
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// runJSONRPC serves JSON-RPC 2.0 requests on stdin and stdout until stdin is
+// closed, returning the process exit code. Messages are JSON values, each
+// response followed by a newline, which is simpler for editor scripts than the
+// framing of LSP. The methods are:
+//
+//   - format, with a formatRequest, returning a formatResponse.
+//   - check, with a formatRequest, returning whether the source is formatted.
+//   - resolveConfig, with a filePath, returning the options of the file.
+func runJSONRPC(args []string) int {
+	fs := flag.NewFlagSet("prettier jsonrpc", flag.ExitOnError)
+	var rf runFlags
+	rf.register(fs)
+	_ = fs.Parse(args)
+	runArgs, err := rf.runArgs(nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	s := &formatService{r: newRunner(runner.WithLogger(rf.logger())), args: runArgs}
+	return s.serveJSONRPC(os.Stdin, os.Stdout)
+}
+
+func (s *formatService) serveJSONRPC(in io.Reader, out io.Writer) int {
+	dec := json.NewDecoder(bufio.NewReader(in))
+	enc := json.NewEncoder(out)
+	for {
+		var req rpcRequest
+		if err := dec.Decode(&req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return 0
+			}
+			// The rest of the stream can't be parsed after a syntax error.
+			_ = enc.Encode(newRPCResponse(nil, nil, &rpcError{Code: rpcParseError, Message: err.Error()}))
+			return 1
+		}
+		result, err := s.handleJSONRPC(req)
+		if req.isNotification() {
+			continue
+		}
+		if err := enc.Encode(newRPCResponse(req.ID, result, err)); err != nil {
+			return 1
+		}
+	}
+}
+
+func (s *formatService) handleJSONRPC(req rpcRequest) (any, error) {
+	if req.JSONRPC != "2.0" {
+		return nil, &rpcError{Code: rpcInvalidRequest, Message: `jsonrpc must be "2.0"`}
+	}
+
+	// JSON-RPC has no way to cancel requests.
+	ctx := context.Background()
+
+	switch req.Method {
+	case "format", "check":
+		var params formatRequest
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		res := s.format(ctx, params)
+		if res.Error != "" {
+			return nil, errors.New(res.Error)
+		}
+		if req.Method == "check" {
+			return map[string]any{"formatted": res.Formatted == params.Source, "ignored": res.Ignored}, nil
+		}
+		return res, nil
+	case "resolveConfig":
+		var params struct {
+			FilePath string `json:"filePath"`
+		}
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if params.FilePath == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "filePath is required"}
+		}
+		return s.r.ResolveConfig(ctx, s.args, params.FilePath)
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+}
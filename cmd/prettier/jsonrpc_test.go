@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+func TestJSONRPC(t *testing.T) {
+	t.Parallel()
+
+	s := &formatService{r: runner.NewRunner(runner.WithStderr(io.Discard)), args: runner.RunArgs{
+		IgnorePaths: []string{".prettierignore"},
+		FS: fstest.MapFS{
+			".prettierignore": {Data: []byte("ignored.js\n")},
+			".prettierrc":     {Data: []byte(`{"semi": false}`)},
+		},
+	}}
+
+	tests := []struct {
+		name string
+		req  string
+		// want is the response, or empty if none is expected.
+		want string
+		code int
+	}{
+		{
+			name: "format",
+			req:  `{"jsonrpc": "2.0", "id": 1, "method": "format", "params": {"filePath": "a.js", "source": "a  =  1;\n"}}`,
+			want: `{"jsonrpc":"2.0","id":1,"result":{"formatted":"a = 1\n"}}`,
+		},
+		{
+			name: "string ID",
+			req:  `{"jsonrpc": "2.0", "id": "a", "method": "format", "params": {"filePath": "a.js", "source": "a = 1\n"}}`,
+			want: `{"jsonrpc":"2.0","id":"a","result":{"formatted":"a = 1\n"}}`,
+		},
+		{
+			name: "check unformatted",
+			req:  `{"jsonrpc": "2.0", "id": 1, "method": "check", "params": {"filePath": "a.js", "source": "a  =  1;\n"}}`,
+			want: `{"jsonrpc":"2.0","id":1,"result":{"formatted":false,"ignored":false}}`,
+		},
+		{
+			name: "check ignored",
+			req:  `{"jsonrpc": "2.0", "id": 1, "method": "check", "params": {"filePath": "ignored.js", "source": "a  =  1;\n"}}`,
+			want: `{"jsonrpc":"2.0","id":1,"result":{"formatted":true,"ignored":true}}`,
+		},
+		{
+			name: "resolve config",
+			req:  `{"jsonrpc": "2.0", "id": 1, "method": "resolveConfig", "params": {"filePath": "a.js"}}`,
+			want: `{"jsonrpc":"2.0","id":1,"result":{"semi":false}}`,
+		},
+		{
+			name: "notification",
+			req:  `{"jsonrpc": "2.0", "method": "format", "params": {"filePath": "a.js", "source": "a\n"}}`,
+		},
+		{
+			name: "format error",
+			req:  `{"jsonrpc": "2.0", "id": 1, "method": "format", "params": {"filePath": "a.js", "source": "function {"}}`,
+			code: rpcInternalError,
+		},
+		{
+			name: "missing params",
+			req:  `{"jsonrpc": "2.0", "id": 1, "method": "format"}`,
+			want: `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"missing params"}}`,
+		},
+		{
+			name: "invalid params",
+			req:  `{"jsonrpc": "2.0", "id": 1, "method": "resolveConfig", "params": {}}`,
+			want: `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"filePath is required"}}`,
+		},
+		{
+			name: "unknown method",
+			req:  `{"jsonrpc": "2.0", "id": 1, "method": "lint", "params": {}}`,
+			want: `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found: lint"}}`,
+		},
+		{
+			name: "invalid version",
+			req:  `{"jsonrpc": "1.0", "id": 1, "method": "format", "params": {}}`,
+			want: `{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"jsonrpc must be \"2.0\""}}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+			if code := s.serveJSONRPC(strings.NewReader(tc.req), &out); code != 0 {
+				t.Errorf("got exit code %d", code)
+			}
+			if tc.code != 0 {
+				var res rpcResponse
+				if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+					t.Fatal(err)
+				}
+				if res.Error == nil || res.Error.Code != tc.code {
+					t.Errorf("got %s, want error code %d", out.String(), tc.code)
+				}
+				return
+			}
+			want := tc.want
+			if want != "" {
+				want += "\n"
+			}
+			if got := out.String(); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+
+	// Responses are written in order, each on its own line, and a syntax
+	// error ends the stream.
+	t.Run("stream", func(t *testing.T) {
+		t.Parallel()
+
+		in := `{"jsonrpc": "2.0", "id": 1, "method": "format", "params": {"filePath": "a.md", "source": "#  a\n"}}
+{"jsonrpc": "2.0", "id": 2, "method": "resolveConfig", "params": {"filePath": "a.js"}}
+{"jsonrpc"
+{"jsonrpc": "2.0", "id": 3, "method": "resolveConfig", "params": {"filePath": "a.js"}}
+`
+		var out bytes.Buffer
+		if code := s.serveJSONRPC(strings.NewReader(in), &out); code != 1 {
+			t.Errorf("got exit code %d, want 1", code)
+		}
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("got responses %q, want 3", lines)
+		}
+		if want := `{"jsonrpc":"2.0","id":1,"result":{"formatted":"# a\n"}}`; lines[0] != want {
+			t.Errorf("got %s, want %s", lines[0], want)
+		}
+		if want := `{"jsonrpc":"2.0","id":2,"result":{"semi":false}}`; lines[1] != want {
+			t.Errorf("got %s, want %s", lines[1], want)
+		}
+		var res rpcResponse
+		if err := json.Unmarshal([]byte(lines[2]), &res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Error == nil || res.Error.Code != rpcParseError || string(res.ID) != "null" {
+			t.Errorf("got %s, want parse error", lines[2])
+		}
+	})
+}
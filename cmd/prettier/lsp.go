@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wasilibs/go-prettier/internal/diff"
+	"github.com/wasilibs/go-prettier/internal/runner"
+	"github.com/wasilibs/go-prettier/internal/wasm"
+)
+
+// lspRequestCancelled is the error code of LSP for requests cancelled with
+// $/cancelRequest.
+const lspRequestCancelled = -32800
+
+// lspPosition is a position in a document, with the character in UTF-16
+// code units.
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspTextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type lspDidOpenParams struct {
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+}
+
+type lspDidChangeParams struct {
+	TextDocument   lspTextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type lspDidCloseParams struct {
+	TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+}
+
+type lspCancelParams struct {
+	ID json.RawMessage `json:"id"`
+}
+
+type lspFormattingParams struct {
+	TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+	// Range is only set for range formatting.
+	Range *lspRange `json:"range"`
+}
+
+// lspServer is a language server formatting documents opened by the editor.
+// Only full document synchronization is supported, which editors fall back
+// to. Formatting requests run concurrently with reading further messages, so
+// they can be cancelled with $/cancelRequest.
+type lspServer struct {
+	s    *formatService
+	docs map[string]string
+
+	shutdown bool
+
+	outMu sync.Mutex
+	// inflight cancels the formatting requests being run, keyed by ID.
+	inflightMu sync.Mutex
+	inflight   map[string]context.CancelFunc
+}
+
+// runLSP runs a language server on stdin and stdout until the client exits
+// it, returning the process exit code.
+func runLSP(args []string) int {
+	fs := flag.NewFlagSet("prettier lsp", flag.ExitOnError)
+	var rf runFlags
+	rf.register(fs)
+	_ = fs.Parse(args)
+	runArgs, err := rf.runArgs(nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	srv := &lspServer{
+		s:    &formatService{r: newRunner(runner.WithLogger(rf.logger())), args: runArgs},
+		docs: map[string]string{},
+	}
+	return srv.serve(os.Stdin, os.Stdout)
+}
+
+func (l *lspServer) serve(in io.Reader, out io.Writer) int {
+	var wg sync.WaitGroup
+	// Responses to formatting requests are written before exiting.
+	defer wg.Wait()
+
+	r := textproto.NewReader(bufio.NewReader(in))
+	for {
+		body, err := readLSPMessage(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Fprintf(os.Stderr, "Unable to read message: %v\n", err)
+			}
+			return 1
+		}
+
+		var req rpcRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			l.write(out, newRPCResponse(nil, nil, &rpcError{Code: rpcParseError, Message: err.Error()}))
+			continue
+		}
+		switch req.Method {
+		case "exit":
+			if l.shutdown {
+				return 0
+			}
+			return 1
+		case "$/cancelRequest":
+			var params lspCancelParams
+			if err := decodeParams(req.Params, &params); err == nil {
+				l.cancel(params.ID)
+			}
+			continue
+		case "textDocument/formatting", "textDocument/rangeFormatting":
+			if req.isNotification() {
+				continue
+			}
+			// The document is read now, as later messages may change it.
+			fReq, err := l.formatRequest(req.Params)
+			if err != nil {
+				l.write(out, newRPCResponse(req.ID, nil, err))
+				continue
+			}
+			ctx := l.start(req.ID)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer l.cancel(req.ID)
+				edits, err := l.format(ctx, fReq)
+				if ctx.Err() != nil {
+					err = &rpcError{Code: lspRequestCancelled, Message: "request cancelled"}
+				}
+				l.write(out, newRPCResponse(req.ID, edits, err))
+			}()
+			continue
+		}
+
+		result, err := l.handle(req)
+		if req.isNotification() {
+			continue
+		}
+		l.write(out, newRPCResponse(req.ID, result, err))
+	}
+}
+
+// write writes res to out, which formatting requests write to concurrently.
+func (l *lspServer) write(out io.Writer, res rpcResponse) {
+	l.outMu.Lock()
+	defer l.outMu.Unlock()
+	writeLSPMessage(out, res)
+}
+
+// start returns the context of the request with id, done once the request is
+// cancelled.
+func (l *lspServer) start(id json.RawMessage) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	l.inflightMu.Lock()
+	defer l.inflightMu.Unlock()
+	if l.inflight == nil {
+		l.inflight = map[string]context.CancelFunc{}
+	}
+	l.inflight[string(id)] = cancel
+	return ctx
+}
+
+// cancel cancels the request with id if it is still running.
+func (l *lspServer) cancel(id json.RawMessage) {
+	l.inflightMu.Lock()
+	defer l.inflightMu.Unlock()
+	if cancel, ok := l.inflight[string(id)]; ok {
+		cancel()
+		delete(l.inflight, string(id))
+	}
+}
+
+func (l *lspServer) handle(req rpcRequest) (any, error) {
+	switch req.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				// Full document sync.
+				"textDocumentSync":                1,
+				"documentFormattingProvider":      true,
+				"documentRangeFormattingProvider": true,
+			},
+			"serverInfo": map[string]any{"name": "go-prettier", "version": wasm.PrettierVersion},
+		}, nil
+	case "shutdown":
+		l.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params lspDidOpenParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		l.docs[params.TextDocument.URI] = params.TextDocument.Text
+		return nil, nil
+	case "textDocument/didChange":
+		var params lspDidChangeParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			l.docs[params.TextDocument.URI] = params.ContentChanges[n-1].Text
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params lspDidCloseParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		delete(l.docs, params.TextDocument.URI)
+		return nil, nil
+	}
+
+	if req.isNotification() {
+		// Notifications such as initialized don't need handling.
+		return nil, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+}
+
+// formatRequest returns the request formatting the open document of a
+// formatting or range formatting request.
+func (l *lspServer) formatRequest(rawParams json.RawMessage) (formatRequest, error) {
+	var params lspFormattingParams
+	if err := decodeParams(rawParams, &params); err != nil {
+		return formatRequest{}, err
+	}
+	uri := params.TextDocument.URI
+	text, ok := l.docs[uri]
+	if !ok {
+		return formatRequest{}, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("document not open: %s", uri)}
+	}
+	path, err := uriPath(uri)
+	if err != nil {
+		return formatRequest{}, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+
+	req := formatRequest{FilePath: path, Source: text}
+	if params.Range != nil {
+		src := []byte(text)
+		start, end := positionOffset(src, params.Range.Start), positionOffset(src, params.Range.End)
+		if start > end {
+			return formatRequest{}, &rpcError{Code: rpcInvalidParams, Message: "range start after range end"}
+		}
+		req.Range = &[2]int{start, end}
+	}
+	return req, nil
+}
+
+// format returns the edits formatting the document of req.
+func (l *lspServer) format(ctx context.Context, req formatRequest) ([]lspTextEdit, error) {
+	res := l.s.format(ctx, req)
+	if res.Error != "" {
+		return nil, errors.New(res.Error)
+	}
+	return textEdits([]byte(req.Source), []byte(res.Formatted)), nil
+}
+
+// textEdits returns the edits transforming src into formatted, one for each
+// changed group of lines, so editors keep the cursor in unchanged lines.
+func textEdits(src []byte, formatted []byte) []lspTextEdit {
+	var lineStarts []int
+	lineStarts = append(lineStarts, 0)
+	for i, c := range src {
+		if c == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	lineOffset := func(line int) int {
+		if line >= len(lineStarts) {
+			return len(src)
+		}
+		return lineStarts[line]
+	}
+
+	edits := []lspTextEdit{}
+	for _, h := range diff.Hunks(src, formatted, 0) {
+		var sb strings.Builder
+		for _, l := range h.Lines {
+			if l.Kind == diff.Insert {
+				sb.WriteString(l.Text)
+			}
+		}
+		start := offsetPosition(src, lineOffset(h.FromLine-1))
+		end := offsetPosition(src, lineOffset(h.FromLine-1+h.FromCount))
+		if n := len(edits); n > 0 && edits[n-1].Range.End == start {
+			// Hunks without context aren't merged when adjacent.
+			edits[n-1].Range.End = end
+			edits[n-1].NewText += sb.String()
+			continue
+		}
+		edits = append(edits, lspTextEdit{Range: lspRange{Start: start, End: end}, NewText: sb.String()})
+	}
+	return edits
+}
+
+// positionOffset returns the byte offset in src of pos, clamped to the end of
+// its line.
+func positionOffset(src []byte, pos lspPosition) int {
+	off := 0
+	for line := 0; line < pos.Line; line++ {
+		i := strings.IndexByte(string(src[off:]), '\n')
+		if i < 0 {
+			return len(src)
+		}
+		off += i + 1
+	}
+	units := 0
+	for i, r := range string(src[off:]) {
+		if r == '\n' || units >= pos.Character {
+			return off + i
+		}
+		units += utf16Units(r)
+	}
+	return len(src)
+}
+
+// offsetPosition returns the position of the byte offset off in src.
+func offsetPosition(src []byte, off int) lspPosition {
+	var pos lspPosition
+	for _, r := range string(src[:off]) {
+		if r == '\n' {
+			pos.Line++
+			pos.Character = 0
+			continue
+		}
+		pos.Character += utf16Units(r)
+	}
+	return pos
+}
+
+// uriPath returns the file path of a file URI.
+func uriPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported URI scheme %q", u.Scheme)
+	}
+	p := u.Path
+	if runtime.GOOS == "windows" {
+		// file:///C:/foo has the path /C:/foo.
+		p = strings.TrimPrefix(p, "/")
+	}
+	return filepath.FromSlash(p), nil
+}
+
+// maxLSPMessageSize is the largest message content accepted from the client,
+// so that a bad Content-Length can't exhaust memory.
+const maxLSPMessageSize = 64 << 20
+
+// readLSPMessage reads the content of a message with LSP's base protocol,
+// which has HTTP-like headers.
+func readLSPMessage(r *textproto.Reader) ([]byte, error) {
+	header, err := r.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length: %w", err)
+	}
+	if n < 0 || n > maxLSPMessageSize {
+		return nil, fmt.Errorf("invalid Content-Length %d, expected at most %d", n, maxLSPMessageSize)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r.R, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func writeLSPMessage(w io.Writer, res rpcResponse) {
+	b, err := json.Marshal(res)
+	if err != nil {
+		// Programming bug
+		panic(err)
+	}
+	fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(b), b)
+}
+
+// utf16Units returns the number of UTF-16 code units encoding r.
+func utf16Units(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
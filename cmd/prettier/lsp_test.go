@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+func TestLSP(t *testing.T) {
+	t.Parallel()
+
+	src := "a  =  1;\n\nb  =  [\n1];\n"
+
+	var in bytes.Buffer
+	send := func(id int, method string, params any) {
+		msg := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+		if id != 0 {
+			msg["id"] = id
+		}
+		b, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(b), b)
+	}
+	doc := map[string]any{"uri": "file:///a.js"}
+	send(1, "initialize", map[string]any{})
+	send(0, "initialized", map[string]any{})
+	send(0, "textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": "file:///a.js", "text": src}})
+	send(2, "textDocument/formatting", map[string]any{"textDocument": doc})
+	send(3, "textDocument/rangeFormatting", map[string]any{
+		"textDocument": doc,
+		"range":        lspRange{Start: lspPosition{Line: 2}, End: lspPosition{Line: 3, Character: 3}},
+	})
+	send(0, "textDocument/didChange", map[string]any{"textDocument": doc, "contentChanges": []any{map[string]any{"text": "a = 1;\n"}}})
+	send(4, "textDocument/formatting", map[string]any{"textDocument": doc})
+	send(0, "textDocument/didClose", map[string]any{"textDocument": doc})
+	send(5, "textDocument/formatting", map[string]any{"textDocument": doc})
+	send(6, "textDocument/hover", map[string]any{})
+	send(7, "shutdown", nil)
+	send(0, "exit", nil)
+
+	srv := &lspServer{
+		s: &formatService{r: runner.NewRunner(runner.WithStderr(io.Discard)), args: runner.RunArgs{
+			FS: fstest.MapFS{},
+		}},
+		docs: map[string]string{},
+	}
+	var out bytes.Buffer
+	if code := srv.serve(&in, &out); code != 0 {
+		t.Errorf("got exit code %d, want 0 after shutdown", code)
+	}
+
+	responses := map[int]rpcResponse{}
+	r := textproto.NewReader(bufio.NewReader(&out))
+	for {
+		body, err := readLSPMessage(r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res rpcResponse
+		if err := json.Unmarshal(body, &res); err != nil {
+			t.Fatal(err)
+		}
+		var id int
+		if err := json.Unmarshal(res.ID, &id); err != nil {
+			t.Fatalf("invalid ID in %s: %v", body, err)
+		}
+		responses[id] = res
+	}
+	// Notifications are not answered.
+	if len(responses) != 7 {
+		t.Errorf("got %d responses, want 7", len(responses))
+	}
+
+	edits := func(id int) []lspTextEdit {
+		t.Helper()
+		res := responses[id]
+		if res.Error != nil {
+			t.Fatalf("%d: got error %v", id, res.Error)
+		}
+		var edits []lspTextEdit
+		if err := json.Unmarshal(res.Result, &edits); err != nil {
+			t.Fatalf("%d: invalid result %s: %v", id, res.Result, err)
+		}
+		return edits
+	}
+	line := func(l int) lspPosition {
+		return lspPosition{Line: l}
+	}
+
+	if caps := string(responses[1].Result); !bytes.Contains([]byte(caps), []byte(`"documentFormattingProvider":true`)) {
+		t.Errorf("got initialize result %s", caps)
+	}
+
+	// Each changed group of lines is an edit.
+	want := []lspTextEdit{
+		{Range: lspRange{Start: line(0), End: line(1)}, NewText: "a = 1;\n"},
+		{Range: lspRange{Start: line(2), End: line(4)}, NewText: "b = [1];\n"},
+	}
+	if got := edits(2); !reflect.DeepEqual(got, want) {
+		t.Errorf("formatting: got %+v, want %+v", got, want)
+	}
+	if got := edits(3); !reflect.DeepEqual(got, want[1:]) {
+		t.Errorf("range formatting: got %+v, want %+v", got, want[1:])
+	}
+	// Formatted documents have no edits.
+	if got := edits(4); len(got) != 0 {
+		t.Errorf("formatting after change: got %+v, want no edits", got)
+	}
+
+	if res := responses[5]; res.Error == nil || res.Error.Code != rpcInvalidParams {
+		t.Errorf("formatting closed document: got %+v, want invalid params", res)
+	}
+	if res := responses[6]; res.Error == nil || res.Error.Code != rpcMethodNotFound {
+		t.Errorf("unknown method: got %+v, want method not found", res)
+	}
+	if res := responses[7]; res.Error != nil || string(res.Result) != "null" {
+		t.Errorf("shutdown: got %+v", res)
+	}
+}
+
+func TestReadLSPMessage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+		// err is whether reading fails.
+		err bool
+	}{
+		{name: "valid", in: "Content-Length: 2\r\n\r\n{}", want: "{}"},
+		{name: "missing length", in: "Content-Type: application/json\r\n\r\n{}", err: true},
+		{name: "invalid length", in: "Content-Length: two\r\n\r\n{}", err: true},
+		{name: "negative length", in: "Content-Length: -1\r\n\r\n{}", err: true},
+		{name: "length too large", in: fmt.Sprintf("Content-Length: %d\r\n\r\n{}", maxLSPMessageSize+1), err: true},
+		{name: "truncated", in: "Content-Length: 3\r\n\r\n{}", err: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			body, err := readLSPMessage(textproto.NewReader(bufio.NewReader(strings.NewReader(tc.in))))
+			if tc.err {
+				if err == nil {
+					t.Errorf("got %q, want error", body)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(body); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	// The server stops on a malformed header rather than panicking.
+	srv := &lspServer{docs: map[string]string{}}
+	var out bytes.Buffer
+	if code := srv.serve(strings.NewReader("Content-Length: -1\r\n\r\n"), &out); code != 1 {
+		t.Errorf("got exit code %d, want 1", code)
+	}
+}
+
+func TestLSPCancel(t *testing.T) {
+	t.Parallel()
+
+	// Large enough that formatting is still running when the cancellation,
+	// read right after the request, arrives.
+	src := strings.Repeat("a  =  [1,2,3];\n", 1000)
+
+	var in bytes.Buffer
+	for _, msg := range []map[string]any{
+		{"method": "textDocument/didOpen", "params": map[string]any{"textDocument": map[string]any{"uri": "file:///a.js", "text": src}}},
+		{"id": 1, "method": "textDocument/formatting", "params": map[string]any{"textDocument": map[string]any{"uri": "file:///a.js"}}},
+		{"method": "$/cancelRequest", "params": map[string]any{"id": 1}},
+		// Cancelling finished or unknown requests does nothing.
+		{"method": "$/cancelRequest", "params": map[string]any{"id": 2}},
+	} {
+		msg["jsonrpc"] = "2.0"
+		b, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(b), b)
+	}
+
+	srv := &lspServer{
+		s: &formatService{r: runner.NewRunner(runner.WithStderr(io.Discard)), args: runner.RunArgs{
+			FS: fstest.MapFS{},
+		}},
+		docs: map[string]string{},
+	}
+	var out bytes.Buffer
+	// The input ends without exit.
+	if code := srv.serve(&in, &out); code != 1 {
+		t.Errorf("got exit code %d, want 1", code)
+	}
+
+	body, err := readLSPMessage(textproto.NewReader(bufio.NewReader(&out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res rpcResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Error == nil || res.Error.Code != lspRequestCancelled {
+		t.Errorf("got error %v, want request cancelled", res.Error)
+	}
+}
@@ -3,47 +3,569 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/wasilibs/go-prettier/internal/runner"
 )
 
+// defaultConfig is the config used when no config file is found, settable
+// when building with -ldflags "-X main.defaultConfig=<config>".
+var defaultConfig string
+
 func main() {
-	var check bool
+	os.Exit(run(os.Args[1:], "", os.Stdout))
+}
+
+// run dispatches args to their subcommand, returning the process exit code.
+func run(args []string, dir string, stdout io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "format":
+			return runFormat("prettier format", args[1:], false, dir, stdout)
+		case "check":
+			return runFormat("prettier check", args[1:], true, dir, stdout)
+		case "cache":
+			return runCache(args[1:], stdout)
+		case "daemon":
+			return runDaemon(args[1:])
+		case "jsonrpc":
+			return runJSONRPC(args[1:])
+		case "lsp":
+			return runLSP(args[1:])
+		case "serve":
+			return runServe(args[1:])
+		case "install-hook":
+			return runInstallHook(args[1:], dir, stdout)
+		case "info":
+			return runInfo(args[1:], stdout)
+		case "tui":
+			return runTUI(args[1:])
+		case "verify":
+			return runVerify(args[1:], dir, stdout)
+		}
+	}
+
+	// Without a subcommand, the flags match those of prettier's CLI.
+	return runFormat("prettier", args, false, dir, stdout)
+}
+
+// runFormat formats the files matching the patterns in args, or checks them
+// if check is set, returning the process exit code. Patterns are relative to
+// dir, or the working directory if empty.
+func runFormat(name string, args []string, check bool, dir string, stdout io.Writer) int {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+
+	var version bool
 	var write bool
 
-	flag.BoolVar(&check, "check", false, "Check if the given files are formatted, print a human-friendly summary message and paths to unformatted files")
-	flag.BoolVar(&check, "c", false, "Check if the given files are formatted, print a human-friendly summary message and paths to unformatted files")
-	flag.BoolVar(&write, "write", false, "Edit files in-place. (Beware!)")
-	flag.BoolVar(&write, "w", false, "Edit files in-place. (Beware!)")
+	fs.BoolVar(&check, "check", check, "Check if the given files are formatted, print a human-friendly summary message and paths to unformatted files")
+	fs.BoolVar(&check, "c", check, "Check if the given files are formatted, print a human-friendly summary message and paths to unformatted files")
+	fs.BoolVar(&write, "write", false, "Edit files in-place. (Beware!)")
+	fs.BoolVar(&write, "w", false, "Edit files in-place. (Beware!)")
+	fs.BoolVar(&version, "version", false, "Print the versions of prettier and the components embedding it.")
+	fs.BoolVar(&version, "v", false, "Print the versions of prettier and the components embedding it.")
+	versionJSON := fs.Bool("json", false, "With --version, print the versions as JSON.")
+	stdinFilepath := fs.String("stdin-filepath", "", "Format stdin as the contents of the given path, used to infer the parser and find the config,\nand print the result to stdout.")
+	fileInfo := fs.String("file-info", "", "Print the inferred parser of the given file and whether it is ignored, as JSON.")
+	supportInfo := fs.Bool("support-info", false, "Print the languages and options supported by the embedded prettier, as JSON.")
+	findConfigPath := fs.String("find-config-path", "", "Find and print the path to a configuration file for the given input file.")
+	watch := fs.Bool("watch", false, "Keep running and format, or check, the given files again as they change.")
+	outDir := fs.String("out-dir", "", "Write formatted files to the given directory, at their paths relative to the working directory,\ninstead of in place.")
+	cache := fs.Bool("cache", false, "Only format files that changed since they were last found to be formatted, with --write or --check.")
+	cacheLocation := fs.String("cache-location", "", "Path to the cache file, defaulting to node_modules/.cache/prettier/.prettier-cache.")
+	cacheStrategy := fs.String("cache-strategy", "", "Strategy for detecting changed files: content (default) or metadata.")
+	dryRun := fs.Bool("dry-run", false, "Print the paths of files that formatting would change, without writing them.")
+	captureRepro := fs.String("capture-repro", "", "Write the input, options and error of files that fail to format to the given directory,\nfor attaching to bug reports.")
+	delegateToNode := fs.Bool("delegate-to-node", false, "Format files the embedded prettier can't handle, such as with JavaScript config files or plugins,\nwith prettier installed in node_modules.")
+	journal := fs.String("journal", "", "Record processed files in the given file, so an interrupted run can be resumed with --resume.")
+	resume := fs.Bool("resume", false, "Skip files recorded in --journal by a previous, interrupted run.")
+	fileTimeout := fs.Duration("file-timeout", 0, "Fail files that take longer than the given duration, such as 30s, to format and continue with the others.")
+	timeout := fs.Duration("timeout", 0, "Stop the run after the given duration, such as 10m, and print the files that were not processed.")
+	concurrency := fs.Int("concurrency", 0, "Maximum number of files to format in parallel, each using its own memory.\nDefaults to the number of CPUs.")
+	var maxFileSize sizeFlag
+	fs.Var(&maxFileSize, "max-file-size", "Skip files larger than the given size, such as 512K or 2M, with a warning.")
+	var memoryLimit sizeFlag
+	fs.Var(&memoryLimit, "memory-limit", "Format fewer files concurrently while memory usage approaches the given size, such as 512M or 2G.")
+	rangeStart := fs.Int("range-start", 0, "Format only code starting at the given byte offset, extended to the start of its statement.")
+	rangeEnd := fs.Int("range-end", 0, "Format only code ending before the given byte offset, extended to the end of its statement.")
+	maxFailures := fs.Int("max-failures", 0, "Stop after the given number of files fail the check or can't be formatted.")
+	failFast := fs.Bool("fail-fast", false, "Stop after the first file fails the check or can't be formatted, same as --max-failures=1.")
+	var unknownParser sliceFlag
+	fs.Var(&unknownParser, "unknown-parser", "Severity of files no parser could be inferred for: ignore, warn or error.\nUse <pattern>=<severity> to set it for files matching a gitignore-style pattern.\nMultiple values are accepted, later values take precedence.")
+	showDiff := fs.Bool("diff", false, "With --check, print a unified diff of the changes to each unformatted file.")
+	color := colorAuto
+	fs.Var(&color, "color", "Color the output: auto, always or never. --color alone means always.\nauto colors output when stdout and stderr are terminals and NO_COLOR is not set.")
+	fs.Var(noColorFlag{&color}, "no-color", "Do not color the output, same as --color=never.")
+	nul := fs.Bool("z", false, "With --check, print the paths of unformatted files to stdout separated by NUL characters, e.g. for xargs -0.")
+	interactive := fs.Bool("interactive", false, "With --write, show the changes to each file and prompt before applying them.")
+	output := fs.String("output", "text", "Format of the output: text, or json or sarif to print a report of the processed files to stdout\ninstead of messages, like --report-format.")
+	reportFile := fs.String("report-file", "", "Write a machine-readable report of the processed files to the given path.")
+	reportFormat := fs.String("report-format", "", "Format of --report-file: json, junit or sarif.\nDefaults to the format matching its extension (.json, .xml, .sarif).")
+	manifest := fs.String("manifest", "", "Write the SHA-256 hash of the formatted contents of each processed file to the given JSON file.")
+
+	var rf runFlags
+	rf.register(fs)
+
+	_ = fs.Parse(args)
+
+	if version {
+		return runVersion(*versionJSON, stdout)
+	}
+
+	runArgs, err := rf.runArgs(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	runArgs.Dir = dir
+	runArgs.Stdout = stdout
+	runArgs.Check = check
+	runArgs.Write = write
+	runArgs.DryRun = *dryRun
+	runArgs.Cache = *cache
+	runArgs.CacheLocation = *cacheLocation
+	runArgs.CacheStrategy = *cacheStrategy
+	runArgs.OutDir = *outDir
+	runArgs.CaptureReproDir = *captureRepro
+	runArgs.DelegateToNode = *delegateToNode
+	runArgs.MaxFailures = *maxFailures
+	if *failFast {
+		runArgs.MaxFailures = 1
+	}
+	runArgs.Timeout = *timeout
+	runArgs.FileTimeout = *fileTimeout
+	runArgs.Concurrency = *concurrency
+	runArgs.MemoryLimit = uint64(memoryLimit)
+	runArgs.MaxFileSize = uint64(maxFileSize)
+	runArgs.RangeStart = *rangeStart
+	runArgs.RangeEnd = *rangeEnd
+	runArgs.Journal = *journal
+	runArgs.Diff = *showDiff
+	runArgs.Color = color.enabled()
+	runArgs.NulSeparated = *nul
+	runArgs.Resume = *resume
+	for _, v := range unknownParser {
+		if i := strings.LastIndexByte(v, '='); i >= 0 {
+			runArgs.UnknownParserOverrides = append(runArgs.UnknownParserOverrides, runner.UnknownParserOverride{Pattern: v[:i], Severity: v[i+1:]})
+		} else {
+			runArgs.UnknownParser = v
+		}
+	}
+
+	// Flag combinations are validated before dispatching on the mode so
+	// they're rejected the same way whatever the mode.
+	if *concurrency < 0 {
+		fmt.Fprintln(os.Stderr, "--concurrency must not be negative")
+		return 2
+	}
+	if *interactive && !write {
+		fmt.Fprintln(os.Stderr, "--interactive can only be used with --write")
+		return 2
+	}
+	if *resume && *journal == "" {
+		fmt.Fprintln(os.Stderr, "--resume can only be used with --journal")
+		return 2
+	}
+	switch *output {
+	case "text":
+	case runner.ReportFormatJSON, runner.ReportFormatSARIF:
+		if *reportFile != "" {
+			fmt.Fprintf(os.Stderr, "--output %s can't be used with --report-file\n", *output)
+			return 2
+		}
+		if *interactive {
+			fmt.Fprintf(os.Stderr, "--output %s can't be used with --interactive\n", *output)
+			return 2
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid --output %q, must be text, json or sarif\n", *output)
+		return 2
+	}
+	if *reportFile != "" && *reportFormat == "" {
+		*reportFormat = runner.ReportFormatForPath(*reportFile)
+		if *reportFormat == "" {
+			fmt.Fprintln(os.Stderr, "--report-format is required when it can't be inferred from the extension of --report-file")
+			return 2
+		}
+	}
+	if (*watch || *stdinFilepath != "") && (*reportFile != "" || *output != "text" || *manifest != "") {
+		fmt.Fprintln(os.Stderr, "--report-file, --output and --manifest can't be used with --watch or --stdin-filepath")
+		return 2
+	}
+
+	r := newRunner(runner.WithLogger(rf.logger()))
+
+	if *supportInfo {
+		return runSupportInfo(context.Background(), r, stdout)
+	}
+
+	if *fileInfo != "" {
+		return runFileInfo(context.Background(), r, runArgs, *fileInfo, stdout)
+	}
+
+	if *findConfigPath != "" {
+		return runFindConfigPath(r, runArgs, *findConfigPath, stdout)
+	}
+
+	if *watch {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if err := r.Watch(ctx, runArgs, 0); err != nil {
+			return 2
+		}
+		return 0
+	}
+
+	if *stdinFilepath != "" {
+		if err := runStdin(context.Background(), r, runArgs, *stdinFilepath, os.Stdin, stdout); err != nil {
+			return 2
+		}
+		return 0
+	}
 
-	var ignorePaths sliceFlag
-	flag.Var(&ignorePaths, "ignore-path", "Path to a file with patterns describing files to ignore.\nMultiple values are accepted.\nDefaults to [.gitignore, .prettierignore].")
+	if *reportFile != "" {
+		f, err := os.Create(*reportFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to create report file: %v\n", err)
+			return 2
+		}
+		defer f.Close()
+		runArgs.Report = f
+		runArgs.ReportFormat = *reportFormat
+	}
 
-	noConfig := flag.Bool("no-config", false, "Do not look for a configuration file.")
-	noErrorOnUnmatchedPattern := flag.Bool("no-error-on-unmatched-pattern", false, "Prevent errors when pattern is unmatched.")
-	withNodeModules := flag.Bool("with-node-modules", false, "Process files inside 'node_modules' directory.")
+	if *output != "text" {
+		runArgs.Report = stdout
+		runArgs.ReportFormat = *output
+		// Only the report is printed to stdout.
+		runArgs.Stdout = io.Discard
+	}
 
-	flag.Parse()
+	if *manifest != "" {
+		f, err := os.Create(*manifest)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to create manifest: %v\n", err)
+			return 2
+		}
+		defer f.Close()
+		runArgs.Manifest = f
+	}
 
-	if len(ignorePaths) == 0 {
-		ignorePaths = append(ignorePaths, ".gitignore", ".prettierignore")
+	if *interactive {
+		if err := runInteractive(context.Background(), r, runArgs, os.Stdin, stdout); err != nil {
+			return 1
+		}
+		return 0
 	}
 
-	r := runner.NewRunner()
-	if err := r.Run(context.Background(), runner.RunArgs{
-		Patterns:                  flag.Args(),
-		Check:                     check,
-		Write:                     write,
-		IgnorePaths:               ignorePaths,
-		NoConfig:                  *noConfig,
-		NoErrorOnUnmatchedPattern: *noErrorOnUnmatchedPattern,
-		WithNodeModules:           *withNodeModules,
-	}); err != nil {
+	// The bar is only drawn once the run has enough files.
+	var bar *progressBar
+	if isTerminal(os.Stderr) {
+		bar = newProgressBar(os.Stderr)
+		runArgs.Progress = bar
+		log.SetOutput(bar.wrap(os.Stderr))
+		if f, ok := stdout.(*os.File); ok && isTerminal(f) {
+			if runArgs.Stdout == stdout {
+				runArgs.Stdout = bar.wrap(stdout)
+			}
+			if runArgs.Report == stdout {
+				runArgs.Report = bar.wrap(stdout)
+			}
+		}
+	}
+
+	_, err = r.Run(context.Background(), runArgs)
+	if bar != nil {
+		bar.finish()
+	}
+	if err != nil {
 		// Runner handles logging so we just need to set error code.
-		os.Exit(1)
+		return 1
+	}
+	return 0
+}
+
+// runFindConfigPath prints the path to the config file used for the file at
+// path, relative to the working directory.
+func runFindConfigPath(r *runner.Runner, args runner.RunArgs, path string, stdout io.Writer) int {
+	// Config files are searched for from the directory of the file.
+	if dir := filepath.Dir(path); filepath.IsAbs(dir) {
+		args.Dir = dir
+	} else {
+		args.Dir = filepath.Join(args.Dir, dir)
+	}
+	cfgPath := r.ResolveConfigFile(args)
+	if cfgPath == "" {
+		fmt.Fprintf(os.Stderr, "Can not find configure file for \"%s\".\n", path)
+		return 1
+	}
+	if wd, err := os.Getwd(); err == nil && filepath.IsAbs(cfgPath) {
+		if rel, err := filepath.Rel(wd, cfgPath); err == nil {
+			cfgPath = rel
+		}
+	}
+	fmt.Fprintln(stdout, cfgPath)
+	return 0
+}
+
+func newRunner(opts ...runner.Option) *runner.Runner {
+	if defaultConfig != "" {
+		opts = append(opts, runner.WithDefaultConfig([]byte(defaultConfig)))
+	}
+	return runner.NewRunner(opts...)
+}
+
+// logLevels maps the values of --log-level, named like prettier's, to slog
+// levels. silent is above any level the runner logs at.
+var logLevels = map[string]slog.Level{
+	"silent": slog.LevelError + 4,
+	"error":  slog.LevelError,
+	"warn":   slog.LevelWarn,
+	"log":    slog.LevelInfo,
+	"debug":  slog.LevelDebug,
+}
+
+// runFlags are the flags common to all commands that format files matching patterns.
+type runFlags struct {
+	config                     string
+	configExpandEnv            bool
+	configIntegrity            string
+	changedSince               string
+	dirtyFirst                 bool
+	embeddedLanguageFormatting string
+	gitOnly                    bool
+	ignorePaths                sliceFlag
+	logLevel                   string
+	maxDepth                   int
+	noConfig                   bool
+	noEditorConfig             bool
+	noGitIgnore                bool
+	noErrorOnUnmatchedPattern  bool
+	options                    optionFlags
+	parser                     string
+	presets                    sliceFlag
+	shard                      string
+	staged                     bool
+	withNodeModules            bool
+}
+
+func (f *runFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.config, "config", "", "Path to a Prettier configuration file (.prettierrc, .prettierrc.json, .prettierrc.yaml, .prettierrc.toml)\nor an https:// URL to fetch it from.")
+	fs.BoolVar(&f.configExpandEnv, "config-expand-env", false, "Replace ${VAR} and ${VAR:-default} in the configuration file with environment variables.")
+	fs.StringVar(&f.configIntegrity, "config-integrity", "", "Subresource Integrity hash the configuration file must match, e.g. sha256-<base64 digest>.")
+	fs.StringVar(&f.embeddedLanguageFormatting, "embedded-language-formatting", "", "Control how Prettier formats quoted code embedded in the file.\nDefaults to auto.")
+	fs.StringVar(&f.changedSince, "changed-since", "", "Only process files changed in git since the merge base of the given ref and HEAD,\nincluding uncommitted and untracked files.")
+	fs.BoolVar(&f.dirtyFirst, "dirty-first", false, "Process files with uncommitted changes in git before other files.")
+	fs.BoolVar(&f.staged, "staged", false, "Only process files staged in git, reading them from the index.\nWith --write, formatted files are staged, and written to the working tree if it has no unstaged changes to them.")
+	fs.BoolVar(&f.gitOnly, "git-only", false, "Only process files tracked by git.")
+	fs.StringVar(&f.logLevel, "log-level", "log", "Level of messages to print: silent, error, warn, log or debug.")
+	fs.IntVar(&f.maxDepth, "max-depth", 0, "Only descend this many levels into directories, 1 only includes files directly in them.")
+	fs.StringVar(&f.parser, "parser", "", "Parser to format files with instead of inferring it from their paths, one of\n"+strings.Join(runner.Parsers, ", ")+".")
+	fs.StringVar(&f.shard, "shard", "", "Only process the i-th of n disjoint subsets of the files, given as i/n, e.g. 2/4.")
+	fs.Var(&f.presets, "preset", "Name of a preset in the presets section of the configuration file to apply.\nMultiple values are accepted and applied in order.")
+	fs.Var(&f.ignorePaths, "ignore-path", "Path to a file with patterns describing files to ignore.\nMultiple values are accepted.\nDefaults to [.gitignore, .prettierignore].")
+
+	f.options.register(fs)
+
+	fs.BoolVar(&f.noConfig, "no-config", false, "Do not look for a configuration file.")
+	fs.BoolVar(&f.noEditorConfig, "no-editorconfig", false, "Don't take .editorconfig into account when parsing configuration.")
+	fs.BoolVar(&f.noGitIgnore, "no-gitignore", false, "Don't ignore files matched by .gitignore files when --ignore-path is not given.")
+	fs.BoolVar(&f.noErrorOnUnmatchedPattern, "no-error-on-unmatched-pattern", false, "Prevent errors when pattern is unmatched.")
+	fs.BoolVar(&f.withNodeModules, "with-node-modules", false, "Process files inside 'node_modules' directory.")
+}
+
+// runArgs returns the arguments of a run of the files matching patterns, or
+// an error if a flag is invalid.
+func (f *runFlags) runArgs(patterns []string) (runner.RunArgs, error) {
+	if _, ok := logLevels[f.logLevel]; !ok {
+		return runner.RunArgs{}, fmt.Errorf("invalid --log-level %q, expected one of silent, error, warn, log or debug", f.logLevel)
+	}
+
+	patterns, err := expandPatternFiles(patterns)
+	if err != nil {
+		return runner.RunArgs{}, err
+	}
+
+	var shardIndex, shardCount int
+	if f.shard != "" {
+		if _, err := fmt.Sscanf(f.shard, "%d/%d", &shardIndex, &shardCount); err != nil || shardCount < 1 || shardIndex < 1 || shardIndex > shardCount {
+			return runner.RunArgs{}, fmt.Errorf("invalid --shard %q, expected i/n with 1 <= i <= n", f.shard)
+		}
+	}
+
+	if f.parser != "" && !slices.Contains(runner.Parsers, f.parser) {
+		return runner.RunArgs{}, fmt.Errorf("invalid --parser %q, expected one of %s", f.parser, strings.Join(runner.Parsers, ", "))
+	}
+
+	options, err := f.options.options()
+	if err != nil {
+		return runner.RunArgs{}, err
+	}
+	if f.parser != "" {
+		options["parser"] = f.parser
+	}
+	if f.embeddedLanguageFormatting != "" {
+		options["embeddedLanguageFormatting"] = f.embeddedLanguageFormatting
+	}
+
+	return runner.RunArgs{
+		Patterns:                  patterns,
+		Config:                    f.config,
+		ConfigExpandEnv:           f.configExpandEnv,
+		ConfigIntegrity:           f.configIntegrity,
+		IgnorePaths:               f.ignorePaths,
+		NoGitIgnore:               f.noGitIgnore,
+		NoConfig:                  f.noConfig,
+		NoEditorConfig:            f.noEditorConfig,
+		NoErrorOnUnmatchedPattern: f.noErrorOnUnmatchedPattern,
+		ChangedSince:              f.changedSince,
+		DirtyFirst:                f.dirtyFirst,
+		GitOnly:                   f.gitOnly,
+		MaxDepth:                  f.maxDepth,
+		Options:                   options,
+		Presets:                   f.presets,
+		ShardIndex:                shardIndex,
+		ShardCount:                shardCount,
+		Staged:                    f.staged,
+		WithNodeModules:           f.withNodeModules,
+	}, nil
+}
+
+// logger returns the logger for runners, which prints messages at or above
+// --log-level with the default handler.
+func (f *runFlags) logger() *slog.Logger {
+	level, ok := logLevels[f.logLevel]
+	if !ok {
+		level = slog.LevelInfo
+	}
+	return slog.New(levelHandler{Handler: slog.Default().Handler(), level: level})
+}
+
+// levelHandler is a handler that drops records below level.
+type levelHandler struct {
+	slog.Handler
+	level slog.Level
+}
+
+func (h levelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
+// colorFlag is the value of --color.
+type colorFlag string
+
+const (
+	colorAuto   colorFlag = "auto"
+	colorAlways colorFlag = "always"
+	colorNever  colorFlag = "never"
+)
+
+func (c *colorFlag) String() string {
+	return string(*c)
+}
+
+func (c *colorFlag) Set(s string) error {
+	switch s {
+	case "auto", "always", "never":
+		*c = colorFlag(s)
+	case "true":
+		*c = colorAlways
+	case "false":
+		*c = colorNever
+	default:
+		return fmt.Errorf("invalid color %q, must be auto, always or never", s)
+	}
+	return nil
+}
+
+// IsBoolFlag allows --color without a value.
+func (c *colorFlag) IsBoolFlag() bool {
+	return true
+}
+
+// enabled returns whether output is colored. auto follows the convention of
+// https://no-color.org.
+func (c colorFlag) enabled() bool {
+	switch c {
+	case colorAlways:
+		return true
+	case colorNever:
+		return false
+	}
+	return os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout) && isTerminal(os.Stderr)
+}
+
+// noColorFlag sets --color to never.
+type noColorFlag struct {
+	c *colorFlag
+}
+
+func (n noColorFlag) String() string {
+	return ""
+}
+
+func (n noColorFlag) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	if v {
+		*n.c = colorNever
+	}
+	return nil
+}
+
+func (n noColorFlag) IsBoolFlag() bool {
+	return true
+}
+
+// isTerminal returns whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// expandPatternFiles replaces each pattern of the form @file with the
+// patterns listed in file, one per line. Blank lines and lines starting with #
+// are skipped.
+func expandPatternFiles(patterns []string) ([]string, error) {
+	var res []string
+	for _, p := range patterns {
+		if !strings.HasPrefix(p, "@") {
+			res = append(res, p)
+			continue
+		}
+		b, err := os.ReadFile(p[1:])
+		if err != nil {
+			return nil, fmt.Errorf("unable to read pattern file: %w", err)
+		}
+		for _, line := range strings.Split(string(b), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			res = append(res, line)
+		}
 	}
+	return res, nil
 }
 
 type sliceFlag []string
@@ -56,3 +578,33 @@ func (f *sliceFlag) Set(s string) error {
 	*f = append(*f, s)
 	return nil
 }
+
+// sizeFlag is a number of bytes, accepting the suffixes K, M and G for
+// multiples of 1024.
+type sizeFlag uint64
+
+func (f *sizeFlag) String() string {
+	return strconv.FormatUint(uint64(*f), 10)
+}
+
+func (f *sizeFlag) Set(s string) error {
+	mult := uint64(1)
+	switch strings.ToUpper(s[len(s)-min(len(s), 1):]) {
+	case "K":
+		mult = 1 << 10
+	case "M":
+		mult = 1 << 20
+	case "G":
+		mult = 1 << 30
+	}
+	num := s
+	if mult > 1 {
+		num = s[:len(s)-1]
+	}
+	n, err := strconv.ParseUint(num, 10, 64)
+	if err != nil || n > math.MaxUint64/mult {
+		return fmt.Errorf("invalid size %q", s)
+	}
+	*f = sizeFlag(n * mult)
+	return nil
+}
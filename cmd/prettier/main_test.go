@@ -0,0 +1,459 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+func TestFindConfigPath(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for path, content := range map[string]string{
+		".prettierrc":             "semi: false\n",
+		"sub/.prettierrc.json":    `{"semi": true}`,
+		"sub/a.js":                "",
+		"sub/nested/b.js":         "",
+		"other/c.js":              "",
+		"pkg/package.json":        `{"name": "pkg"}`,
+		"pkg/d.js":                "",
+		"configured/package.json": `{"prettier": {"semi": true}}`,
+		"configured/e.js":         "",
+	} {
+		p := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "a.js", want: ".prettierrc"},
+		{path: "sub/a.js", want: "sub/.prettierrc.json"},
+		{path: "sub/nested/b.js", want: "sub/.prettierrc.json"},
+		{path: "other/c.js", want: ".prettierrc"},
+		// package.json without a prettier key is not a config file.
+		{path: "pkg/d.js", want: ".prettierrc"},
+		{path: "configured/e.js", want: "configured/package.json"},
+	}
+
+	r := runner.NewRunner()
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			var out bytes.Buffer
+			if code := runFindConfigPath(r, runner.RunArgs{}, filepath.Join(dir, tc.path), &out); code != 0 {
+				t.Fatalf("got exit code %d", code)
+			}
+			// Printed relative to the working directory.
+			got := strings.TrimSpace(out.String())
+			if !filepath.IsAbs(got) {
+				got = filepath.Join(wd, got)
+			}
+			if want := filepath.Join(dir, tc.want); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestExpandPatternFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	patterns := filepath.Join(dir, "patterns.txt")
+	content := "# Sources\nsrc/**/*.js\n\n  docs/*.md  \n#lib\r\nlib\r\n"
+	if err := os.WriteFile(patterns, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := expandPatternFiles([]string{"a.js", "@" + patterns, "b.js"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Comments and blank lines are skipped, and other patterns kept in order.
+	if want := []string{"a.js", "src/**/*.js", "docs/*.md", "lib", "b.js"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := expandPatternFiles([]string{"@" + filepath.Join(dir, "missing.txt")}); err == nil || !strings.Contains(err.Error(), "unable to read pattern file") {
+		t.Errorf("got error %v for missing file, want unable to read pattern file", err)
+	}
+}
+
+func TestRunFormat(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]string{
+		"a.md": "# a\n",
+		"b.md": "#  b\n",
+		"c.md": "#  c\n",
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+	}{
+		{
+			name:       "check",
+			args:       []string{"--check", "."},
+			wantCode:   1,
+			wantStdout: "Checking formatting...\n",
+		},
+		{
+			name:       "nul separated",
+			args:       []string{"--check", "-z", "."},
+			wantCode:   1,
+			wantStdout: "b.md\x00c.md\x00",
+		},
+		{
+			name:       "fail fast",
+			args:       []string{"--check", "-z", "--fail-fast", "--concurrency", "1", "."},
+			wantCode:   1,
+			wantStdout: "b.md\x00",
+		},
+		{
+			name:       "color",
+			args:       []string{"--check", "--color=always", "a.md"},
+			wantCode:   0,
+			wantStdout: "Checking formatting...\n\x1b[32mAll matched files use Prettier code style!\x1b[0m\n",
+		},
+		{
+			name:       "no color",
+			args:       []string{"--check", "--color", "--no-color", "a.md"},
+			wantCode:   0,
+			wantStdout: "Checking formatting...\nAll matched files use Prettier code style!\n",
+		},
+		{
+			name:       "concurrency",
+			args:       []string{"--check", "-z", "--concurrency", "1", "."},
+			wantCode:   1,
+			wantStdout: "b.md\x00c.md\x00",
+		},
+		{
+			name:     "negative concurrency",
+			args:     []string{"--check", "--concurrency", "-1", "."},
+			wantCode: 2,
+		},
+		{
+			name:     "resume without journal",
+			args:     []string{"--resume", "--stdin-filepath", "a.md"},
+			wantCode: 2,
+		},
+		{
+			name:     "invalid output with watch",
+			args:     []string{"--watch", "--output", "yaml", "."},
+			wantCode: 2,
+		},
+		{
+			name:     "report file with stdin",
+			args:     []string{"--stdin-filepath", "a.md", "--report-file", "report.json"},
+			wantCode: 2,
+		},
+		{
+			name:     "interactive with output json",
+			args:     []string{"--write", "--interactive", "--output", "json", "."},
+			wantCode: 2,
+		},
+		{
+			name:     "invalid log level",
+			args:     []string{"--log-level", "verbose", "."},
+			wantCode: 2,
+		},
+		{
+			name:       "nul separated formatted",
+			args:       []string{"--check", "-z", "a.md"},
+			wantCode:   0,
+			wantStdout: "",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			for path, content := range files {
+				if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var stdout bytes.Buffer
+			if code := runFormat("prettier", tc.args, false, dir, &stdout); code != tc.wantCode {
+				t.Errorf("got exit code %d, want %d", code, tc.wantCode)
+			}
+			if got := stdout.String(); got != tc.wantStdout {
+				t.Errorf("got stdout %q, want %q", got, tc.wantStdout)
+			}
+		})
+	}
+}
+
+func TestOutputJSON(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for path, content := range map[string]string{
+		"a.md": "# a\n",
+		"b.md": "#  b\n",
+		"c.js": "const = ;\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout bytes.Buffer
+	if code := runFormat("prettier", []string{"--check", "--output", "json", "."}, false, dir, &stdout); code != 1 {
+		t.Errorf("got exit code %d, want 1", code)
+	}
+	// Timings vary between runs.
+	got := regexp.MustCompile(`,\n\s+"durationMs": [^\n]+`).ReplaceAllString(stdout.String(), "")
+
+	want, err := os.ReadFile(filepath.Join("testdata", "output.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("got output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestInteractiveManifest(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte("# a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := filepath.Join(t.TempDir(), "manifest.json")
+
+	// a.md is already formatted, so there is nothing to prompt for.
+	args := []string{"--write", "--interactive", "--manifest", manifest, "a.md"}
+	if code := runFormat("prettier", args, false, dir, io.Discard); code != 0 {
+		t.Fatalf("got exit code %d", code)
+	}
+	b, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"a.md"`) {
+		t.Errorf("got manifest %s, want a.md", b)
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantB      string
+	}{
+		{
+			name:       "check",
+			args:       []string{"check", "."},
+			wantCode:   1,
+			wantStdout: "Checking formatting...",
+			wantB:      "#  b\n",
+		},
+		{
+			name:     "format",
+			args:     []string{"format", "--write", "."},
+			wantCode: 0,
+			wantB:    "# b\n",
+		},
+		{
+			name:       "info",
+			args:       []string{"info", "version"},
+			wantCode:   0,
+			wantStdout: "go-prettier: ",
+			wantB:      "#  b\n",
+		},
+		{
+			name:       "cache",
+			args:       []string{"cache", "path"},
+			wantCode:   0,
+			wantStdout: ".prettier-cache",
+			wantB:      "#  b\n",
+		},
+		{
+			name:       "no subcommand",
+			args:       []string{"--check", "."},
+			wantCode:   1,
+			wantStdout: "Checking formatting...",
+			wantB:      "#  b\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			for path, content := range map[string]string{"a.md": "# a\n", "b.md": "#  b\n"} {
+				if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var stdout bytes.Buffer
+			if code := run(tc.args, dir, &stdout); code != tc.wantCode {
+				t.Errorf("got exit code %d, want %d", code, tc.wantCode)
+			}
+			if got := stdout.String(); !strings.Contains(got, tc.wantStdout) {
+				t.Errorf("got stdout %q, want it to contain %q", got, tc.wantStdout)
+			}
+			b, err := os.ReadFile(filepath.Join(dir, "b.md"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tc.wantB {
+				t.Errorf("got b.md %q, want %q", got, tc.wantB)
+			}
+		})
+	}
+}
+
+func TestColorFlag(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args    []string
+		want    colorFlag
+		wantErr bool
+	}{
+		{args: nil, want: colorAuto},
+		{args: []string{"--color"}, want: colorAlways},
+		{args: []string{"--color=true"}, want: colorAlways},
+		{args: []string{"--color=false"}, want: colorNever},
+		{args: []string{"--color=never"}, want: colorNever},
+		{args: []string{"--no-color"}, want: colorNever},
+		{args: []string{"--color", "--no-color"}, want: colorNever},
+		{args: []string{"--no-color=false"}, want: colorAuto},
+		{args: []string{"--color=sometimes"}, wantErr: true},
+	}
+	for _, tc := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		color := colorAuto
+		fs.Var(&color, "color", "")
+		fs.Var(noColorFlag{&color}, "no-color", "")
+		err := fs.Parse(tc.args)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%v: got no error", tc.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: %v", tc.args, err)
+		}
+		if color != tc.want {
+			t.Errorf("%v: got %q, want %q", tc.args, color, tc.want)
+		}
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		level       string
+		wantEnabled slog.Level
+		wantDropped slog.Level
+	}{
+		{level: "silent", wantEnabled: slog.LevelError + 4, wantDropped: slog.LevelError},
+		{level: "error", wantEnabled: slog.LevelError, wantDropped: slog.LevelWarn},
+		{level: "warn", wantEnabled: slog.LevelWarn, wantDropped: slog.LevelInfo},
+		{level: "log", wantEnabled: slog.LevelInfo, wantDropped: slog.LevelDebug},
+		{level: "debug", wantEnabled: slog.LevelDebug, wantDropped: slog.LevelDebug - 4},
+	}
+	for _, tc := range tests {
+		rf := runFlags{logLevel: tc.level}
+		if _, err := rf.runArgs(nil); err != nil {
+			t.Fatalf("%s: %v", tc.level, err)
+		}
+		l := rf.logger()
+		if !l.Enabled(context.Background(), tc.wantEnabled) {
+			t.Errorf("%s: got %v dropped, want enabled", tc.level, tc.wantEnabled)
+		}
+		if l.Enabled(context.Background(), tc.wantDropped) {
+			t.Errorf("%s: got %v enabled, want dropped", tc.level, tc.wantDropped)
+		}
+	}
+	// The default logger is left as is.
+	if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("got debug enabled for the default logger")
+	}
+
+	rf := runFlags{logLevel: "verbose"}
+	if _, err := rf.runArgs(nil); err == nil || !strings.Contains(err.Error(), "invalid --log-level") {
+		t.Errorf("got error %v, want invalid --log-level", err)
+	}
+}
+
+func TestSizeFlag(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value   string
+		want    sizeFlag
+		wantErr bool
+	}{
+		{value: "512", want: 512},
+		{value: "0", want: 0},
+		{value: "4K", want: 4 << 10},
+		{value: "4k", want: 4 << 10},
+		{value: "2M", want: 2 << 20},
+		{value: "2m", want: 2 << 20},
+		{value: "3G", want: 3 << 30},
+		{value: "", wantErr: true},
+		{value: "K", wantErr: true},
+		{value: "1.5M", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "2T", wantErr: true},
+		{value: "17179869184G", wantErr: true},
+	}
+	for _, tc := range tests {
+		var f sizeFlag
+		err := f.Set(tc.value)
+		if tc.wantErr {
+			if err == nil || !strings.Contains(err.Error(), strconv.Quote(tc.value)) {
+				t.Errorf("%q: got error %v, want invalid size of the value", tc.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", tc.value, err)
+		}
+		if f != tc.want {
+			t.Errorf("%q: got %d, want %d", tc.value, f, tc.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds in seconds of the buckets of the
+// request latency histogram, from small files formatted by a warm module to
+// large ones.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// serveMetrics are the metrics of the format requests of serve, exposed in
+// the Prometheus text format so a shared formatting service can be monitored.
+type serveMetrics struct {
+	mu sync.Mutex
+	// requests are the numbers of requests by response status.
+	requests map[int]uint64
+	formats  uint64
+	failures uint64
+	ignored  uint64
+	// latencyCounts are the numbers of requests in each of latencyBuckets,
+	// not cumulative, with the last for requests slower than all of them.
+	latencyCounts []uint64
+	latencySum    float64
+}
+
+// observe records a format request answered with status and res after d.
+func (m *serveMetrics) observe(status int, res formatResponse, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.requests == nil {
+		m.requests = map[int]uint64{}
+		m.latencyCounts = make([]uint64, len(latencyBuckets)+1)
+	}
+	m.requests[status]++
+	switch {
+	case status == http.StatusOK && res.Ignored:
+		m.ignored++
+	case status == http.StatusOK:
+		m.formats++
+	case status != http.StatusBadRequest:
+		// Requests that couldn't be parsed are not counted as failures to
+		// format.
+		m.failures++
+	}
+
+	secs := d.Seconds()
+	i, _ := slices.BinarySearch(latencyBuckets, secs)
+	m.latencyCounts[i]++
+	m.latencySum += secs
+}
+
+func (m *serveMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.write(w)
+}
+
+func (m *serveMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP prettier_requests_total Format requests by response status code.")
+	fmt.Fprintln(w, "# TYPE prettier_requests_total counter")
+	codes := make([]int, 0, len(m.requests))
+	for code := range m.requests {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "prettier_requests_total{code=\"%d\"} %d\n", code, m.requests[code])
+	}
+
+	counter := func(name string, help string, v uint64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+	}
+	counter("prettier_formats_total", "Files formatted.", m.formats)
+	counter("prettier_format_failures_total", "Files that failed to format, such as because of syntax errors or timeouts.", m.failures)
+	counter("prettier_ignored_total", "Files returned as is because they are ignored.", m.ignored)
+
+	fmt.Fprintln(w, "# HELP prettier_request_duration_seconds Latency of format requests.")
+	fmt.Fprintln(w, "# TYPE prettier_request_duration_seconds histogram")
+	var count uint64
+	for i, le := range latencyBuckets {
+		if m.latencyCounts != nil {
+			count += m.latencyCounts[i]
+		}
+		fmt.Fprintf(w, "prettier_request_duration_seconds_bucket{le=\"%g\"} %d\n", le, count)
+	}
+	if m.latencyCounts != nil {
+		count += m.latencyCounts[len(latencyBuckets)]
+	}
+	fmt.Fprintf(w, "prettier_request_duration_seconds_bucket{le=\"+Inf\"} %d\n", count)
+	fmt.Fprintf(w, "prettier_request_duration_seconds_sum %g\n", m.latencySum)
+	fmt.Fprintf(w, "prettier_request_duration_seconds_count %d\n", count)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeMetrics(t *testing.T) {
+	t.Parallel()
+
+	var m serveMetrics
+	m.observe(http.StatusOK, formatResponse{}, 5*time.Millisecond)
+	m.observe(http.StatusOK, formatResponse{Ignored: true}, 30*time.Millisecond)
+	m.observe(http.StatusServiceUnavailable, formatResponse{Error: "timed out"}, 20*time.Second)
+
+	var buf bytes.Buffer
+	m.write(&buf)
+	got := buf.String()
+
+	for _, want := range []string{
+		`prettier_requests_total{code="200"} 2`,
+		`prettier_requests_total{code="503"} 1`,
+		"prettier_formats_total 1",
+		"prettier_format_failures_total 1",
+		"prettier_ignored_total 1",
+		// Buckets are cumulative and include their upper bound.
+		`prettier_request_duration_seconds_bucket{le="0.005"} 1`,
+		`prettier_request_duration_seconds_bucket{le="0.025"} 1`,
+		`prettier_request_duration_seconds_bucket{le="0.05"} 2`,
+		`prettier_request_duration_seconds_bucket{le="10"} 2`,
+		`prettier_request_duration_seconds_bucket{le="+Inf"} 3`,
+		"prettier_request_duration_seconds_sum 20.035",
+		"prettier_request_duration_seconds_count 3",
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("missing %q in\n%s", want, got)
+		}
+	}
+
+	// Metrics are exposed before any request.
+	var empty serveMetrics
+	buf.Reset()
+	empty.write(&buf)
+	if !strings.Contains(buf.String(), "prettier_request_duration_seconds_count 0\n") {
+		t.Errorf("got\n%s, want empty metrics", buf.String())
+	}
+}
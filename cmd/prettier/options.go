@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+// optionFlags are the flags for prettier options, named like those of
+// prettier's CLI, which take precedence over config files.
+type optionFlags struct {
+	opts runner.Options
+}
+
+func (f *optionFlags) register(fs *flag.FlagSet) {
+	o := &f.opts
+	fs.StringVar(&o.ArrowParens, "arrow-parens", "", "Include parentheses around a sole arrow function parameter: always or avoid.")
+	fs.Var(boolOptionFlag{p: &o.BracketSameLine}, "bracket-same-line", "Put > of opening tags on the last line instead of on a new line.")
+	fs.Var(boolOptionFlag{p: &o.BracketSpacing, negate: true}, "no-bracket-spacing", "Do not print spaces between brackets.")
+	fs.StringVar(&o.EndOfLine, "end-of-line", "", "Which end of line characters to apply: lf, crlf, cr or auto.")
+	fs.Var(boolOptionFlag{p: &o.ExperimentalTernaries}, "experimental-ternaries", "Use curious ternaries, with the question mark after the condition.")
+	fs.StringVar(&o.HTMLWhitespaceSensitivity, "html-whitespace-sensitivity", "", "How to handle whitespaces in HTML: css, strict or ignore.")
+	fs.Var(boolOptionFlag{p: &o.JSXSingleQuote}, "jsx-single-quote", "Use single quotes in JSX.")
+	fs.IntVar(&o.PrintWidth, "print-width", 0, "The line length where Prettier will try wrap.")
+	fs.StringVar(&o.ProseWrap, "prose-wrap", "", "How to wrap prose: always, never or preserve.")
+	fs.StringVar(&o.QuoteProps, "quote-props", "", "Change when properties in objects are quoted: as-needed, consistent or preserve.")
+	fs.Var(boolOptionFlag{p: &o.Semi, negate: true}, "no-semi", "Do not print semicolons, except at the beginning of lines which may need them.")
+	fs.Var(boolOptionFlag{p: &o.SingleAttributePerLine}, "single-attribute-per-line", "Enforce single attribute per line in HTML, Vue and JSX.")
+	fs.Var(boolOptionFlag{p: &o.SingleQuote}, "single-quote", "Use single quotes instead of double quotes.")
+	fs.IntVar(&o.TabWidth, "tab-width", 0, "Number of spaces per indentation level.")
+	fs.StringVar(&o.TrailingComma, "trailing-comma", "", "Print trailing commas wherever possible when multi-line: all, es5 or none.")
+	fs.Var(boolOptionFlag{p: &o.UseTabs}, "use-tabs", "Indent with tabs instead of spaces.")
+	fs.Var(boolOptionFlag{p: &o.VueIndentScriptAndStyle}, "vue-indent-script-and-style", "Indent script and style tags in Vue files.")
+}
+
+// options returns the options set by the flags, keyed by their names in
+// config files.
+func (f *optionFlags) options() (map[string]any, error) {
+	if err := f.opts.Validate(); err != nil {
+		return nil, err
+	}
+	return f.opts.Map(), nil
+}
+
+// boolOptionFlag sets a boolean option, leaving it unset if the flag isn't
+// passed. negate sets it to false for flags such as --no-semi.
+type boolOptionFlag struct {
+	p      **bool
+	negate bool
+}
+
+func (f boolOptionFlag) String() string {
+	if f.p == nil || *f.p == nil {
+		return ""
+	}
+	return strconv.FormatBool(**f.p != f.negate)
+}
+
+func (f boolOptionFlag) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	v = v != f.negate
+	*f.p = &v
+	return nil
+}
+
+func (f boolOptionFlag) IsBoolFlag() bool {
+	return true
+}
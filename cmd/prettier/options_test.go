@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"maps"
+	"testing"
+)
+
+func TestOptionFlags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+		want map[string]any
+		// parseErr is set when parsing the flags fails, and err when they
+		// parse but the options are invalid.
+		parseErr bool
+		err      bool
+	}{
+		{
+			name: "unset",
+			want: map[string]any{},
+		},
+		{
+			name: "bool",
+			args: []string{"--single-quote", "--bracket-same-line=true"},
+			want: map[string]any{"singleQuote": true, "bracketSameLine": true},
+		},
+		{
+			name: "bool false",
+			args: []string{"--use-tabs=false"},
+			want: map[string]any{"useTabs": false},
+		},
+		{
+			name: "negated bool",
+			args: []string{"--no-semi", "--no-bracket-spacing=false"},
+			want: map[string]any{"semi": false, "bracketSpacing": true},
+		},
+		{
+			name: "int",
+			args: []string{"--tab-width", "4", "--print-width=100"},
+			want: map[string]any{"tabWidth": 4.0, "printWidth": 100.0},
+		},
+		{
+			name: "enum",
+			args: []string{"--trailing-comma", "es5", "--end-of-line=crlf"},
+			want: map[string]any{"trailingComma": "es5", "endOfLine": "crlf"},
+		},
+		{
+			name:     "invalid bool",
+			args:     []string{"--single-quote=maybe"},
+			parseErr: true,
+		},
+		{
+			name:     "invalid int",
+			args:     []string{"--print-width", "wide"},
+			parseErr: true,
+		},
+		{
+			name: "negative int",
+			args: []string{"--tab-width=-2"},
+			err:  true,
+		},
+		{
+			name: "invalid enum",
+			args: []string{"--prose-wrap", "sometimes"},
+			err:  true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := flag.NewFlagSet("prettier", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			var f optionFlags
+			f.register(fs)
+			if err := fs.Parse(tc.args); (err != nil) != tc.parseErr {
+				t.Fatalf("got parse error %v, want error %v", err, tc.parseErr)
+			}
+			if tc.parseErr {
+				return
+			}
+
+			got, err := f.options()
+			if (err != nil) != tc.err {
+				t.Fatalf("got error %v, want error %v", err, tc.err)
+			}
+			if !tc.err && !maps.Equal(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+const (
+	// progressMinFiles is the number of files a run must have to show a
+	// progress bar, since smaller runs finish quickly.
+	progressMinFiles = 500
+	// progressInterval is the minimum time between redraws of the bar.
+	progressInterval = 100 * time.Millisecond
+	progressWidth    = 30
+)
+
+// progressBar renders the progress of a run on the last line of a terminal.
+// Output to the terminal must go through writers returned by wrap, which
+// print above the bar.
+type progressBar struct {
+	out io.Writer
+
+	mu    sync.Mutex
+	total int
+	done  int
+	start time.Time
+	drawn bool
+	// hidden is set while the bar is cleared for a partial line written
+	// through wrap, which the bar must not be drawn over.
+	hidden   bool
+	lastDraw time.Time
+}
+
+func newProgressBar(out io.Writer) *progressBar {
+	return &progressBar{out: out}
+}
+
+func (p *progressBar) Discovered(total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total = total
+	p.start = time.Now()
+}
+
+func (p *progressBar) Started(string) {}
+
+func (p *progressBar) Completed(string, runner.FileStatus) {
+	p.advance()
+}
+
+func (p *progressBar) Failed(string, error) {
+	p.advance()
+}
+
+func (p *progressBar) advance() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	if p.done == p.total || time.Since(p.lastDraw) >= progressInterval {
+		p.draw()
+	}
+}
+
+// finish removes the bar from the terminal.
+func (p *progressBar) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+}
+
+// active returns whether the bar is shown. Must be called with mu held.
+func (p *progressBar) active() bool {
+	return p.total >= progressMinFiles
+}
+
+// draw must be called with mu held.
+func (p *progressBar) draw() {
+	if !p.active() || p.hidden {
+		return
+	}
+	filled := progressWidth * p.done / p.total
+	eta := "--"
+	if p.done > 0 && p.done < p.total {
+		elapsed := time.Since(p.start)
+		eta = (elapsed * time.Duration(p.total-p.done) / time.Duration(p.done)).Round(time.Second).String()
+	}
+	fmt.Fprintf(p.out, "\r\x1b[K[%s%s] %d/%d files, ETA %s", strings.Repeat("=", filled), strings.Repeat(" ", progressWidth-filled), p.done, p.total, eta)
+	p.drawn = true
+	p.lastDraw = time.Now()
+}
+
+// clear must be called with mu held.
+func (p *progressBar) clear() {
+	if !p.drawn {
+		return
+	}
+	fmt.Fprint(p.out, "\r\x1b[K")
+	p.drawn = false
+}
+
+// wrap returns a writer to w, a file on the same terminal as the bar, that
+// clears the bar before writing and redraws it after.
+func (p *progressBar) wrap(w io.Writer) io.Writer {
+	return progressWriter{p: p, w: w}
+}
+
+type progressWriter struct {
+	p *progressBar
+	w io.Writer
+}
+
+func (w progressWriter) Write(b []byte) (int, error) {
+	w.p.mu.Lock()
+	defer w.p.mu.Unlock()
+	if w.p.drawn {
+		w.p.clear()
+		w.p.hidden = true
+	}
+	n, err := w.w.Write(b)
+	// Partial lines are completed by the next write, so the bar is only
+	// redrawn after full lines.
+	if w.p.hidden && len(b) > 0 && b[len(b)-1] == '\n' {
+		w.p.hidden = false
+		w.p.draw()
+	}
+	return n, err
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+func TestProgressBar(t *testing.T) {
+	t.Parallel()
+
+	t.Run("small run", func(t *testing.T) {
+		t.Parallel()
+
+		var out bytes.Buffer
+		p := newProgressBar(&out)
+		p.Discovered(progressMinFiles - 1)
+		w := p.wrap(&out)
+		for i := 0; i < progressMinFiles-1; i++ {
+			p.Completed("a.js", runner.StatusFormatted)
+		}
+		fmt.Fprintln(w, "a.js")
+		p.finish()
+		if got := out.String(); got != "a.js\n" {
+			t.Errorf("got output %q, want no bar", got)
+		}
+	})
+
+	t.Run("large run", func(t *testing.T) {
+		t.Parallel()
+
+		var out bytes.Buffer
+		p := newProgressBar(&out)
+		p.Discovered(progressMinFiles)
+		w := p.wrap(&out)
+
+		p.Completed("a.js", runner.StatusFormatted)
+		if got, want := out.String(), "\r\x1b[K["+strings.Repeat(" ", progressWidth)+"] 1/500 files, ETA "; !strings.HasPrefix(got, want) {
+			t.Fatalf("got output %q, want prefix %q", got, want)
+		}
+
+		// Lines are printed above the bar, which is redrawn after them.
+		out.Reset()
+		fmt.Fprint(w, "b.js")
+		// The bar isn't drawn over a partial line.
+		time.Sleep(progressInterval)
+		p.Completed("a.js", runner.StatusFormatted)
+		if got, want := out.String(), "\r\x1b[Kb.js"; got != want {
+			t.Errorf("got output %q, want %q", got, want)
+		}
+		fmt.Fprint(w, "\n")
+		if got, want := out.String(), "\r\x1b[Kb.js\n\r\x1b[K["; !strings.HasPrefix(got, want) {
+			t.Errorf("got output %q, want prefix %q", got, want)
+		}
+
+		out.Reset()
+		for i := 2; i < progressMinFiles-1; i++ {
+			p.Completed("a.js", runner.StatusFormatted)
+		}
+		p.Failed("c.js", errors.New("syntax error"))
+		// The last file always redraws the bar, however recently it was drawn.
+		if got, want := out.String(), "\r\x1b[K["+strings.Repeat("=", progressWidth)+"] 500/500 files, ETA --"; !strings.HasSuffix(got, want) {
+			t.Errorf("got output %q, want suffix %q", got, want)
+		}
+
+		out.Reset()
+		p.finish()
+		if got := out.String(); got != "\r\x1b[K" {
+			t.Errorf("got output %q after finish, want the bar cleared", got)
+		}
+		out.Reset()
+		fmt.Fprintln(w, "d.js")
+		if got := out.String(); got != "d.js\n" {
+			t.Errorf("got output %q after finish, want no bar", got)
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+// maxServeRequestSize is the largest request body accepted by serve, which
+// bounds the memory used by a request.
+const maxServeRequestSize = 16 << 20
+
+// runServe serves format requests over HTTP until interrupted, returning the
+// process exit code. Files of requests are resolved within the served root
+// directory, so clients can't read config or ignore files outside of it.
+// Besides POST /format, GET /metrics exposes metrics of the requests in the
+// Prometheus text format, and GET /healthz and GET /readyz are liveness and
+// readiness probes, ready once prettier is warmed up.
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("prettier serve", flag.ExitOnError)
+	listen := fs.String("listen", "localhost:8080", "Address to listen on.")
+	root := fs.String("root", ".", "Directory the file paths of requests are relative to. Config, ignore and .editorconfig files\nare only read from within it, and --config is relative to it.")
+	timeout := fs.Duration("timeout", 30*time.Second, "Fail requests that take longer than the given duration to format, 0 for no limit.")
+	var rf runFlags
+	rf.register(fs)
+	_ = fs.Parse(args)
+
+	runArgs, err := rf.runArgs(nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	runArgs.FS = os.DirFS(*root)
+	s := &formatService{r: newRunner(runner.WithLogger(rf.logger())), args: runArgs, timeout: *timeout}
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           s.httpHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go s.warmUp(ctx)
+	go func() {
+		<-ctx.Done()
+		s.ready.Store(false)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info(fmt.Sprintf("Listening on %s", *listen))
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "Unable to serve: %v\n", err)
+		return 2
+	}
+	return 0
+}
+
+// httpHandler returns the handler of the endpoints of serve.
+func (s *formatService) httpHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /format", s.serveHTTP)
+	mux.Handle("GET /metrics", &s.metrics)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok\n")
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, _ *http.Request) {
+		if !s.ready.Load() {
+			http.Error(w, "warming up", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "ok\n")
+	})
+	return mux
+}
+
+// warmUp formats a file with ctx, which must be cancellable like the contexts
+// of requests over HTTP, to compile the module they run before setting ready.
+func (s *formatService) warmUp(ctx context.Context) {
+	if err := s.r.Prewarm(ctx); err != nil {
+		if ctx.Err() == nil {
+			slog.Error(fmt.Sprintf("Unable to warm up prettier: %v", err))
+		}
+		return
+	}
+	s.ready.Store(true)
+}
+
+// serveHTTP formats the formatRequest in the body of the request. Requests
+// that can't be formatted, such as because of syntax errors, fail with
+// status 422 and the error in the response. Formatting stops when the client
+// disconnects, and requests that time out fail with status 503.
+func (s *formatService) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+	status, res := s.formatHTTP(w, req)
+	writeJSON(w, status, res)
+	s.metrics.observe(status, res, time.Since(start))
+}
+
+func (s *formatService) formatHTTP(w http.ResponseWriter, req *http.Request) (int, formatResponse) {
+	var fReq formatRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxServeRequestSize)).Decode(&fReq); err != nil {
+		return http.StatusBadRequest, formatResponse{Error: fmt.Sprintf("invalid request: %v", err)}
+	}
+	if fReq.FilePath != "" && !filepath.IsLocal(fReq.FilePath) {
+		return http.StatusBadRequest, formatResponse{Error: "invalid request: filePath must be a relative path within the served directory"}
+	}
+
+	ctx := req.Context()
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	res := s.format(ctx, fReq)
+	status := http.StatusOK
+	switch {
+	case ctx.Err() != nil:
+		status = http.StatusServiceUnavailable
+	case res.Error != "":
+		status = http.StatusUnprocessableEntity
+	}
+	return status, res
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+
+	s := &formatService{r: runner.NewRunner(runner.WithStderr(io.Discard)), args: runner.RunArgs{
+		FS: fstest.MapFS{
+			".prettierrc": {Data: []byte(`{"semi": false}`)},
+		},
+	}}
+	srv := httptest.NewServer(s.httpHandler())
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		want       formatResponse
+	}{
+		{
+			name:       "format",
+			method:     http.MethodPost,
+			body:       `{"filePath": "a.js", "source": "a  =  1;\n"}`,
+			wantStatus: http.StatusOK,
+			want:       formatResponse{Formatted: "a = 1\n"},
+		},
+		{
+			name:       "options",
+			method:     http.MethodPost,
+			body:       `{"filePath": "a.js", "source": "a  =  1;\n", "options": {"semi": true}}`,
+			wantStatus: http.StatusOK,
+			want:       formatResponse{Formatted: "a = 1;\n"},
+		},
+		{
+			name:       "parse error",
+			method:     http.MethodPost,
+			body:       `{"filePath": "a.js", "source": "function {"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "no path",
+			method:     http.MethodPost,
+			body:       `{"source": "a  =  1;\n"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			want:       formatResponse{Error: "filePath is required"},
+		},
+		{
+			name:       "path in subdirectory",
+			method:     http.MethodPost,
+			body:       `{"filePath": "sub/../a.js", "source": "a  =  1;\n"}`,
+			wantStatus: http.StatusOK,
+			want:       formatResponse{Formatted: "a = 1\n"},
+		},
+		{
+			name:       "absolute path",
+			method:     http.MethodPost,
+			body:       `{"filePath": "/etc/a.js", "source": "a  =  1;\n"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "path outside of root",
+			method:     http.MethodPost,
+			body:       `{"filePath": "sub/../../a.js", "source": "a  =  1;\n"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid request",
+			method:     http.MethodPost,
+			body:       `not json`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "too large",
+			method:     http.MethodPost,
+			body:       `{"filePath": "a.js", "source": "` + strings.Repeat("a", maxServeRequestSize) + `"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "method not allowed",
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, srv.URL+"/format", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tc.wantStatus {
+				t.Errorf("got status %d, want %d", res.StatusCode, tc.wantStatus)
+			}
+			if res.StatusCode == http.StatusMethodNotAllowed {
+				return
+			}
+
+			var got formatResponse
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if tc.want == (formatResponse{}) {
+				if got.Error == "" {
+					t.Errorf("got %+v, want error", got)
+				}
+				return
+			}
+			if got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("health", func(t *testing.T) {
+		get := func(path string) int {
+			t.Helper()
+			res, err := srv.Client().Get(srv.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			return res.StatusCode
+		}
+
+		if got := get("/healthz"); got != http.StatusOK {
+			t.Errorf("got status %d for /healthz, want %d", got, http.StatusOK)
+		}
+		if got := get("/readyz"); got != http.StatusServiceUnavailable {
+			t.Errorf("got status %d for /readyz before warming up, want %d", got, http.StatusServiceUnavailable)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		s.warmUp(ctx)
+		if got := get("/readyz"); got != http.StatusOK {
+			t.Errorf("got status %d for /readyz after warming up, want %d", got, http.StatusOK)
+		}
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		res, err := srv.Client().Get(srv.URL + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Requests with an unsupported method are not format requests.
+		for _, want := range []string{
+			`prettier_requests_total{code="200"} 3`,
+			`prettier_requests_total{code="400"} 4`,
+			`prettier_requests_total{code="422"} 2`,
+			"prettier_formats_total 3",
+			"prettier_format_failures_total 2",
+			"prettier_ignored_total 0",
+			`prettier_request_duration_seconds_bucket{le="+Inf"} 9`,
+			"prettier_request_duration_seconds_count 9",
+		} {
+			if !strings.Contains(string(b), want+"\n") {
+				t.Errorf("metrics missing %q in\n%s", want, b)
+			}
+		}
+	})
+}
+
+func TestServeTimeout(t *testing.T) {
+	t.Parallel()
+
+	s := &formatService{r: runner.NewRunner(runner.WithStderr(io.Discard)), args: runner.RunArgs{
+		FS: fstest.MapFS{},
+	}, timeout: time.Nanosecond}
+
+	req := httptest.NewRequest(http.MethodPost, "/format", strings.NewReader(`{"filePath": "a.js", "source": "a  =  1;\n"}`))
+	rec := httptest.NewRecorder()
+	s.httpHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	// Requests of disconnected clients are cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.timeout = 0
+	req = httptest.NewRequest(http.MethodPost, "/format", strings.NewReader(`{"filePath": "a.js", "source": "a  =  1;\n"}`)).WithContext(ctx)
+	rec = httptest.NewRecorder()
+	s.httpHandler().ServeHTTP(rec, req)
+	var got formatResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Error == "" {
+		t.Errorf("got %+v, want error", got)
+	}
+}
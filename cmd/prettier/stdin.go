@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+// runStdin formats stdin as the contents of the file at path, which is used
+// to infer the parser and resolve options, writing the result to stdout. Like
+// prettier, the input is written unchanged if path is ignored.
+func runStdin(ctx context.Context, r *runner.Runner, args runner.RunArgs, path string, stdin io.Reader, stdout io.Writer) error {
+	if ignored, _ := r.IsIgnored(args, path); ignored {
+		_, err := io.Copy(stdout, stdin)
+		return err
+	}
+
+	pCfg, err := r.ResolveConfig(ctx, args, path)
+	if err != nil {
+		return err
+	}
+
+	if err := r.FormatReader(ctx, path, stdin, stdout, pCfg); err != nil {
+		slog.ErrorContext(ctx, fmt.Sprintf("%s: %v", path, err))
+		return err
+	}
+	return nil
+}
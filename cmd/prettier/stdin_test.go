@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+func TestStdin(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		".prettierignore": {Data: []byte("ignored.js\n")},
+		".prettierrc":     {Data: []byte(`{"semi": false}`)},
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "config",
+			path:  "a.js",
+			input: "a  =  \"b\";\n",
+			want:  "a = \"b\"\n",
+		},
+		{
+			name:  "parser from path",
+			path:  "a.md",
+			input: "#  a\n",
+			want:  "# a\n",
+		},
+		{
+			name:  "ignored",
+			path:  "ignored.js",
+			input: "a  =  \"b\";\n",
+			want:  "a  =  \"b\";\n",
+		},
+		{
+			name:    "parse error",
+			path:    "a.js",
+			input:   "function {",
+			wantErr: true,
+		},
+		{
+			name:    "unknown parser",
+			path:    "a.unknown",
+			input:   "a",
+			wantErr: true,
+		},
+	}
+
+	r := runner.NewRunner(runner.WithStderr(io.Discard))
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+			err := runStdin(context.Background(), r, runner.RunArgs{
+				FS:          fsys,
+				IgnorePaths: []string{".prettierignore"},
+			}, tc.path, strings.NewReader(tc.input), &out)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got output %q", out.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
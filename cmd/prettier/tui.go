@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+// runTUI checks the files matching the patterns in args while showing a live
+// dashboard of the run, returning the process exit code.
+func runTUI(args []string) int {
+	fs := flag.NewFlagSet("prettier tui", flag.ExitOnError)
+	var rf runFlags
+	rf.register(fs)
+	_ = fs.Parse(args)
+	runArgs, err := rf.runArgs(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := newTUIModel()
+	p := tea.NewProgram(m, tea.WithAltScreen())
+
+	// Diagnostics would draw over the dashboard, which shows the outcome of
+	// each file instead.
+	r := newRunner(runner.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	go checkForTUI(ctx, r, runArgs, p)
+
+	res, err := p.Run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if res.(*tuiModel).failed() {
+		return 1
+	}
+	return 0
+}
+
+func checkForTUI(ctx context.Context, r *runner.Runner, args runner.RunArgs, p *tea.Program) {
+	args.Check = true
+	args.Write = false
+	args.DryRun = false
+	args.Diff = true
+	args.Stdout = io.Discard
+	args.Progress = tuiProgress{p: p}
+
+	results, wait := r.RunStream(ctx, args)
+	for res := range results {
+		p.Send(tuiFileMsg(tuiFileOf(res)))
+	}
+
+	// The result is only missing if the run failed before processing files,
+	// otherwise failures are shown with each file.
+	if res, err := wait(); res == nil {
+		p.Send(tuiFinishedMsg{err: err})
+		return
+	}
+	p.Send(tuiFinishedMsg{})
+}
+
+func tuiFileOf(res runner.FileResult) tuiFile {
+	f := tuiFile{path: res.Path}
+	switch res.Status {
+	case runner.StatusUnformatted:
+		f.status = tuiStatusUnformatted
+		f.diff = res.Diff
+	case runner.StatusError:
+		f.status = tuiStatusError
+		f.err = res.Err
+		if res.Message != "" {
+			f.err = errors.New(res.Message)
+		}
+	case runner.StatusSkipped:
+		f.status = tuiStatusSkipped
+	default:
+		f.status = tuiStatusFormatted
+	}
+	return f
+}
+
+// tuiProgress sends the progress of the run to the dashboard, which counts
+// files without a result, such as ignored files without a parser, as done.
+type tuiProgress struct {
+	p *tea.Program
+}
+
+func (t tuiProgress) Discovered(total int) {
+	t.p.Send(tuiStartedMsg{total: total})
+}
+
+func (tuiProgress) Started(string) {}
+
+func (t tuiProgress) Completed(string, runner.FileStatus) {
+	t.p.Send(tuiDoneMsg{})
+}
+
+func (t tuiProgress) Failed(string, error) {
+	t.p.Send(tuiDoneMsg{})
+}
+
+type tuiStatus byte
+
+const (
+	tuiStatusFormatted tuiStatus = iota
+	tuiStatusUnformatted
+	tuiStatusSkipped
+	tuiStatusError
+)
+
+type tuiFile struct {
+	path   string
+	status tuiStatus
+	diff   string
+	err    error
+}
+
+type (
+	tuiStartedMsg struct {
+		total int
+	}
+	tuiDoneMsg     struct{}
+	tuiFileMsg     tuiFile
+	tuiFinishedMsg struct {
+		err error
+	}
+)
+
+var (
+	tuiTitleStyle    = lipgloss.NewStyle().Bold(true)
+	tuiSelectedStyle = lipgloss.NewStyle().Reverse(true)
+	tuiHelpStyle     = lipgloss.NewStyle().Faint(true)
+	tuiErrorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	tuiWarnStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	tuiOKStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	tuiHunkStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
+)
+
+type tuiModel struct {
+	total    int
+	done     int
+	finished bool
+	err      error
+
+	// failing are the unformatted or errored files, in order of completion.
+	failing []tuiFile
+	cursor  int
+	// listOffset is the index of the first failing file shown in the list.
+	listOffset int
+
+	showDiff bool
+	diff     viewport.Model
+	progress progress.Model
+
+	width  int
+	height int
+}
+
+func newTUIModel() *tuiModel {
+	return &tuiModel{
+		progress: progress.New(progress.WithDefaultGradient()),
+		diff:     viewport.New(0, 0),
+	}
+}
+
+func (m *tuiModel) failed() bool {
+	return m.err != nil || len(m.failing) > 0
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.progress.Width = max(msg.Width-20, 10)
+		m.diff.Width = msg.Width
+		m.diff.Height = max(msg.Height-3, 1)
+		return m, nil
+	case tuiStartedMsg:
+		m.total = msg.total
+		return m, nil
+	case tuiDoneMsg:
+		m.done++
+		return m, nil
+	case tuiFileMsg:
+		if f := tuiFile(msg); f.status == tuiStatusUnformatted || f.status == tuiStatusError {
+			m.failing = append(m.failing, f)
+		}
+		return m, nil
+	case tuiFinishedMsg:
+		m.finished = true
+		m.err = msg.err
+		return m, nil
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+	}
+
+	if m.showDiff {
+		var cmd tea.Cmd
+		m.diff, cmd = m.diff.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+func (m *tuiModel) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	}
+
+	if m.showDiff {
+		switch msg.String() {
+		case "esc", "backspace", "left", "h":
+			m.showDiff = false
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.diff, cmd = m.diff.Update(msg)
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.failing)-1 {
+			m.cursor++
+		}
+	case "home", "g":
+		m.cursor = 0
+	case "end", "G":
+		m.cursor = max(len(m.failing)-1, 0)
+	case "enter", "right", "l", "d":
+		if m.cursor < len(m.failing) {
+			m.diff.SetContent(renderTUIDiff(m.failing[m.cursor]))
+			m.diff.GotoTop()
+			m.showDiff = true
+		}
+	}
+	return m, nil
+}
+
+func (m *tuiModel) View() string {
+	if m.showDiff {
+		f := m.failing[m.cursor]
+		return tuiTitleStyle.Render(f.path) + "\n" +
+			m.diff.View() + "\n" +
+			tuiHelpStyle.Render(fmt.Sprintf("%3.f%% • ↑/↓ scroll • esc back • q quit", m.diff.ScrollPercent()*100))
+	}
+
+	var sb strings.Builder
+
+	switch {
+	case m.err != nil:
+		sb.WriteString(tuiErrorStyle.Render(fmt.Sprintf("Failed to start: %v", m.err)))
+	case m.finished && len(m.failing) == 0:
+		sb.WriteString(tuiOKStyle.Render("All matched files use Prettier code style!"))
+	case m.finished:
+		sb.WriteString(tuiWarnStyle.Render(fmt.Sprintf("Code style issues found in %d files.", len(m.failing))))
+	default:
+		sb.WriteString(tuiTitleStyle.Render("Checking formatting..."))
+	}
+	sb.WriteString("\n")
+
+	percent := 1.0
+	if m.total > 0 {
+		percent = float64(m.done) / float64(m.total)
+	}
+	fmt.Fprintf(&sb, "%s %d/%d\n\n", m.progress.ViewAs(percent), m.done, m.total)
+
+	// Title, progress and the blank line above take three lines, help and the
+	// blank line above take two.
+	listHeight := max(m.height-5, 1)
+	if m.cursor < m.listOffset {
+		m.listOffset = m.cursor
+	} else if m.cursor >= m.listOffset+listHeight {
+		m.listOffset = m.cursor - listHeight + 1
+	}
+
+	lines := 0
+	for i := m.listOffset; i < len(m.failing) && lines < listHeight; i++ {
+		f := m.failing[i]
+		var line string
+		if f.status == tuiStatusError {
+			line = tuiErrorStyle.Render("[error]") + " " + f.path
+			if f.err != nil {
+				line += ": " + f.err.Error()
+			}
+		} else {
+			line = tuiWarnStyle.Render("[warn]") + " " + f.path
+		}
+		if i == m.cursor {
+			line = tuiSelectedStyle.Render(line)
+		}
+		sb.WriteString(line)
+		sb.WriteString("\n")
+		lines++
+	}
+	for ; lines < listHeight; lines++ {
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString(tuiHelpStyle.Render("↑/↓ select • enter view diff • q quit"))
+
+	return sb.String()
+}
+
+func renderTUIDiff(f tuiFile) string {
+	if f.status == tuiStatusError {
+		if f.err == nil {
+			return tuiErrorStyle.Render(f.path)
+		}
+		return tuiErrorStyle.Render(f.err.Error())
+	}
+
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(f.diff, "\n") {
+		text := strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(text, "+++"), strings.HasPrefix(text, "---"):
+			text = tuiTitleStyle.Render(text)
+		case strings.HasPrefix(text, "@@"):
+			text = tuiHunkStyle.Render(text)
+		case strings.HasPrefix(text, "+"):
+			text = tuiOKStyle.Render(text)
+		case strings.HasPrefix(text, "-"):
+			text = tuiErrorStyle.Render(text)
+		}
+		sb.WriteString(text)
+		if strings.HasSuffix(line, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
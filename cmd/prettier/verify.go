@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/wasilibs/go-prettier/internal/diff"
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+type verifyResult struct {
+	// diff is the unified diff from the native output to the embedded output.
+	diff string
+	// skipped is set when neither prettier could infer a parser.
+	skipped bool
+	err     error
+}
+
+// runVerify formats the files matching the patterns in args with both the
+// embedded prettier and a locally installed one, reporting any differences.
+// Both are passed the same resolved options, with those of native prettier
+// as flags. Paths are relative to dir, or the working directory if empty. It
+// returns the process exit code.
+func runVerify(args []string, dir string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("prettier verify", flag.ExitOnError)
+	against := fs.String("against", "npx", "The prettier to compare against: npx, node_modules, or the path to a prettier executable.")
+	var rf runFlags
+	rf.register(fs)
+	_ = fs.Parse(args)
+
+	runArgs, err := rf.runArgs(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	runArgs.Dir = dir
+
+	native, err := nativePrettierCommand(*against, runArgs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ctx := context.Background()
+	r := newRunner(runner.WithLogger(rf.logger()))
+
+	pCfg, paths, err := r.Expand(ctx, runArgs)
+	if err != nil {
+		return 1
+	}
+
+	results := make([]chan verifyResult, len(paths))
+	for i := range paths {
+		results[i] = make(chan verifyResult, 1)
+	}
+	var g errgroup.Group
+	g.SetLimit(runtime.NumCPU())
+	go func() {
+		for i, p := range paths {
+			if p.Error != "" {
+				results[i] <- verifyResult{err: errors.New(p.Error)}
+				continue
+			}
+			g.Go(func() error {
+				results[i] <- verifyFile(ctx, r, native, runArgs, p.FilePath, p.Config(pCfg))
+				return nil
+			})
+		}
+	}()
+
+	numDiffs, numErrors, numVerified := 0, 0, 0
+	for i, p := range paths {
+		res := <-results[i]
+		switch {
+		case res.err != nil && p.Error != "":
+			numErrors++
+			fmt.Fprintln(stdout, p.Error)
+		case res.err != nil:
+			numErrors++
+			fmt.Fprintf(stdout, "%s: %v\n", p.FilePath, res.err)
+		case res.skipped:
+		case res.diff != "":
+			numDiffs++
+			fmt.Fprintf(stdout, "%s: output differs from native prettier\n%s", p.FilePath, res.diff)
+		default:
+			numVerified++
+		}
+	}
+
+	fmt.Fprintf(stdout, "%d files identical, %d files differ, %d errors.\n", numVerified, numDiffs, numErrors)
+	if numDiffs > 0 || numErrors > 0 {
+		return 1
+	}
+	return 0
+}
+
+func verifyFile(ctx context.Context, r *runner.Runner, native []string, args runner.RunArgs, path string, pCfg map[string]any) verifyResult {
+	in, err := os.ReadFile(filepath.Join(args.Dir, path))
+	if err != nil {
+		return verifyResult{err: err}
+	}
+
+	out, err := r.Format(ctx, path, in, pCfg)
+	unknown := errors.Is(err, runner.ErrUnknownParser)
+	if err != nil && !unknown {
+		return verifyResult{err: err}
+	}
+
+	nativeOut, nativeErr := formatNative(ctx, native, args, path, in, pCfg)
+	switch {
+	case unknown && nativeErr != nil:
+		return verifyResult{skipped: true}
+	case unknown:
+		return verifyResult{err: errors.New("no parser could be inferred, but native prettier formatted the file")}
+	case nativeErr != nil:
+		return verifyResult{err: fmt.Errorf("native prettier failed: %w", nativeErr)}
+	}
+
+	if bytes.Equal(out, nativeOut) {
+		return verifyResult{}
+	}
+	return verifyResult{diff: diff.Unified("native/"+path, "embedded/"+path, nativeOut, out)}
+}
+
+// nativePrettierCommand returns the command line to execute prettier on Node
+// described by against.
+func nativePrettierCommand(against string, args runner.RunArgs) ([]string, error) {
+	switch against {
+	case "npx":
+		if _, err := exec.LookPath("npx"); err != nil {
+			return nil, errors.New("npx not found, install Node or use --against with the path to prettier")
+		}
+		return []string{"npx", "--no-install", "prettier"}, nil
+	case "node_modules":
+		p, err := runner.FindNodePrettier(args)
+		if err != nil {
+			return nil, errors.New("no prettier found in node_modules")
+		}
+		return []string{p}, nil
+	default:
+		return []string{against}, nil
+	}
+}
+
+func formatNative(ctx context.Context, native []string, runArgs runner.RunArgs, path string, in []byte, pCfg map[string]any) ([]byte, error) {
+	args := append(native[1:len(native):len(native)], "--stdin-filepath", path)
+	if config := runArgs.Config; config != "" && !strings.HasPrefix(config, "https://") {
+		args = append(args, "--config", config)
+	}
+	flags, err := runner.NodeOptionFlags(pCfg)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, flags...)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, native[0], args...)
+	cmd.Dir = runArgs.Dir
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
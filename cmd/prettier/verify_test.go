@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	t.Run("options", func(t *testing.T) {
+		t.Parallel()
+
+		if runtime.GOOS == "windows" {
+			t.Skip("fake prettier is a shell script")
+		}
+
+		dir := t.TempDir()
+		files := map[string]string{
+			"a.md":        "# a\n",
+			"b.md":        "#  b\n",
+			".prettierrc": `{"proseWrap": "always", "overrides": [{"files": "b.md", "options": {"tabWidth": 8}}]}`,
+			// Stands in for native prettier, recording the flags it is passed
+			// and leaving files unchanged.
+			"bin/prettier": "#!/bin/sh\necho \"$@\" >> args.txt\ncat\n",
+		}
+		for path, content := range files {
+			path = filepath.Join(dir, path)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var out bytes.Buffer
+		code := runVerify([]string{"--against", filepath.Join(dir, "bin", "prettier"), "--print-width", "100", "a.md", "b.md"}, dir, &out)
+		if code != 1 {
+			t.Errorf("got exit code %d, want 1", code)
+		}
+		if !strings.Contains(out.String(), "b.md: output differs from native prettier\n") {
+			t.Errorf("got output %q, want b.md to differ", out.String())
+		}
+		if !strings.HasSuffix(out.String(), "1 files identical, 1 files differ, 0 errors.\n") {
+			t.Errorf("got output %q, want summary", out.String())
+		}
+
+		b, err := os.ReadFile(filepath.Join(dir, "args.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := strings.Split(strings.TrimSpace(string(b)), "\n")
+		slices.Sort(got)
+		want := []string{
+			"--stdin-filepath a.md --print-width=100 --prose-wrap=always",
+			"--stdin-filepath b.md --print-width=100 --prose-wrap=always --tab-width=8",
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("got native prettier args %q, want %q", got, want)
+		}
+	})
+
+	t.Run("node", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := exec.LookPath("node"); err != nil {
+			t.Skip("node is not installed")
+		}
+		native, err := runner.FindNodePrettier(runner.RunArgs{})
+		if err != nil {
+			t.Skip("prettier is not installed in node_modules")
+		}
+
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte("#  a\n\nsome  text\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		if code := runVerify([]string{"--against", native, "--prose-wrap", "always", "--print-width", "20", "a.md"}, dir, &out); code != 0 {
+			t.Errorf("got exit code %d, want 0, output:\n%s", code, out.String())
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/wasilibs/go-prettier/internal/wasm"
+)
+
+// versionInfo are the versions of the components of the command, for tying
+// bug reports and caches to them.
+type versionInfo struct {
+	// Prettier is the version of the embedded prettier.
+	Prettier string `json:"prettier"`
+	// GoPrettier is the version of this module, or (devel) if it was not
+	// built from a released version.
+	GoPrettier string `json:"goPrettier"`
+	// Plugins are the prettier plugins embedded with prettier.
+	Plugins []string `json:"plugins"`
+	// Wazero is the version of the wasm runtime.
+	Wazero string `json:"wazero"`
+	// Go is the version of Go the command was built with.
+	Go string `json:"go"`
+}
+
+func newVersionInfo() versionInfo {
+	info := versionInfo{
+		Prettier:   wasm.PrettierVersion,
+		GoPrettier: "(devel)",
+		Plugins:    wasm.Plugins,
+		Wazero:     "unknown",
+		Go:         runtime.Version(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if bi.Main.Version != "" {
+			info.GoPrettier = bi.Main.Version
+		}
+		for _, dep := range bi.Deps {
+			if dep.Path == "github.com/tetratelabs/wazero" {
+				info.Wazero = dep.Version
+			}
+		}
+	}
+	return info
+}
+
+// runVersion prints the versions of the components of the command. Like
+// prettier, the first line of the text form is the version of prettier alone.
+func runVersion(asJSON bool, stdout io.Writer) int {
+	info := newVersionInfo()
+	if asJSON {
+		return printJSON(stdout, info)
+	}
+	fmt.Fprintln(stdout, info.Prettier)
+	fmt.Fprintf(stdout, "go-prettier: %s\n", info.GoPrettier)
+	fmt.Fprintf(stdout, "plugins: %s\n", strings.Join(info.Plugins, ", "))
+	fmt.Fprintf(stdout, "wazero: %s\n", info.Wazero)
+	fmt.Fprintf(stdout, "go: %s\n", info.Go)
+	return 0
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/wasilibs/go-prettier/internal/wasm"
+)
+
+func TestVersion(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	if code := runFormat("prettier", []string{"--version"}, false, "", &out); code != 0 {
+		t.Fatalf("got exit code %d", code)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	want := []string{
+		// Like prettier, the first line is the version of prettier alone.
+		wasm.PrettierVersion,
+		// Tests are built without the version of the main module.
+		"go-prettier: (devel)",
+		"plugins: " + strings.Join(wasm.Plugins, ", "),
+		"wazero: " + newVersionInfo().Wazero,
+		"go: " + runtime.Version(),
+	}
+	if !slices.Equal(lines, want) {
+		t.Errorf("got %q, want %q", lines, want)
+	}
+	if strings.HasPrefix(newVersionInfo().Wazero, "unknown") {
+		t.Error("got unknown wazero version")
+	}
+
+	out.Reset()
+	if code := runFormat("prettier", []string{"--version", "--json"}, false, "", &out); code != 0 {
+		t.Fatalf("json: got exit code %d", code)
+	}
+	var info versionInfo
+	if err := json.Unmarshal(out.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Prettier != wasm.PrettierVersion || info.GoPrettier != "(devel)" || !slices.Equal(info.Plugins, wasm.Plugins) || info.Go != runtime.Version() {
+		t.Errorf("got %+v", info)
+	}
+}
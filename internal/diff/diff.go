@@ -0,0 +1,306 @@
+// Package diff computes line-based differences between the original and
+// formatted contents of a file.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind is the kind of change a Line represents.
+type Kind byte
+
+const (
+	// Equal is a line present in both inputs.
+	Equal Kind = iota
+	// Delete is a line only present in the original input.
+	Delete
+	// Insert is a line only present in the new input.
+	Insert
+)
+
+// Line is a single line of a diff, including its trailing newline if any.
+type Line struct {
+	Kind Kind
+	Text string
+}
+
+// Hunk is a contiguous group of changes along with surrounding context.
+type Hunk struct {
+	// FromLine is the 1-based line in the original input where the hunk starts.
+	FromLine int
+	// FromCount is the number of lines of the original input in the hunk.
+	FromCount int
+	// ToLine is the 1-based line in the new input where the hunk starts.
+	ToLine int
+	// ToCount is the number of lines of the new input in the hunk.
+	ToCount int
+	Lines   []Line
+}
+
+// Lines returns the line edits transforming a into b.
+func Lines(a, b []byte) []Line {
+	return myers(splitLines(string(a)), splitLines(string(b)))
+}
+
+// Hunks groups the edits transforming a into b into hunks with the given
+// number of lines of context around each change. Nil is returned when a and
+// b are equal.
+func Hunks(a, b []byte, context int) []Hunk {
+	lines := Lines(a, b)
+
+	var hunks []Hunk
+	var cur *Hunk
+	// Index of the last changed line added to cur.
+	lastChange := 0
+	fromLine, toLine := 1, 1
+	for i, l := range lines {
+		if l.Kind != Equal {
+			if cur != nil && i-lastChange > 2*context {
+				hunks = append(hunks, closeHunk(cur, lines, lastChange, context))
+				cur = nil
+			}
+			if cur == nil {
+				start := max(i-context, 0)
+				cur = &Hunk{
+					FromLine: fromLine - (i - start),
+					ToLine:   toLine - (i - start),
+				}
+				cur.Lines = append(cur.Lines, lines[start:i]...)
+			} else {
+				cur.Lines = append(cur.Lines, lines[lastChange+1:i]...)
+			}
+			cur.Lines = append(cur.Lines, l)
+			lastChange = i
+		}
+		if l.Kind != Insert {
+			fromLine++
+		}
+		if l.Kind != Delete {
+			toLine++
+		}
+	}
+	if cur != nil {
+		hunks = append(hunks, closeHunk(cur, lines, lastChange, context))
+	}
+
+	return hunks
+}
+
+func closeHunk(h *Hunk, lines []Line, lastChange int, context int) Hunk {
+	end := min(lastChange+1+context, len(lines))
+	h.Lines = append(h.Lines, lines[lastChange+1:end]...)
+	for _, l := range h.Lines {
+		if l.Kind != Insert {
+			h.FromCount++
+		}
+		if l.Kind != Delete {
+			h.ToCount++
+		}
+	}
+	return *h
+}
+
+// Unified returns a unified diff of a and b, labeled with fromName and
+// toName, or an empty string if they are equal.
+func Unified(fromName string, toName string, a []byte, b []byte) string {
+	hunks := Hunks(a, b, 3)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks {
+		WriteHunk(&sb, h)
+	}
+	return sb.String()
+}
+
+// ANSI escape codes used by Colorize.
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+// Colorize returns the unified diff d with ANSI colors for terminals: file
+// headers in bold, hunk headers in cyan, deletions in red and insertions in
+// green.
+func Colorize(d string) string {
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(d, "\n") {
+		if line == "" {
+			continue
+		}
+		text, nl := strings.CutSuffix(line, "\n")
+		color := ""
+		switch {
+		case strings.HasPrefix(text, "--- "), strings.HasPrefix(text, "+++ "):
+			color = colorBold
+		case strings.HasPrefix(text, "@@"):
+			color = colorCyan
+		case strings.HasPrefix(text, "-"):
+			color = colorRed
+		case strings.HasPrefix(text, "+"):
+			color = colorGreen
+		}
+		if color == "" {
+			sb.WriteString(line)
+			continue
+		}
+		sb.WriteString(color)
+		sb.WriteString(text)
+		sb.WriteString(colorReset)
+		if nl {
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// WriteHunk writes h in unified diff format to sb.
+func WriteHunk(sb *strings.Builder, h Hunk) {
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(h.FromLine, h.FromCount), hunkRange(h.ToLine, h.ToCount))
+	for _, l := range h.Lines {
+		switch l.Kind {
+		case Equal:
+			sb.WriteByte(' ')
+		case Delete:
+			sb.WriteByte('-')
+		case Insert:
+			sb.WriteByte('+')
+		}
+		sb.WriteString(l.Text)
+		if !strings.HasSuffix(l.Text, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(start int, count int) string {
+	switch count {
+	case 0:
+		// Unified diffs refer to the line before an empty range.
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	default:
+		return fmt.Sprintf("%d,%d", start, count)
+	}
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// myers implements the algorithm from "An O(ND) Difference Algorithm and Its
+// Variations", recording the furthest reaching paths of each step to
+// backtrack the edit script.
+func myers(a []string, b []string) []Line {
+	n, m := len(a), len(b)
+	maxD := n + m
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+
+	for d := 0; d <= maxD; d++ {
+		// Step d only reads diagonals -d-1 to d+1 of the previous step.
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, d)
+			}
+		}
+	}
+
+	// Unreachable, the loop always finds a path by d == n+m.
+	return nil
+}
+
+func backtrack(trace [][]int, a []string, b []string, d int) []Line {
+	var lines []Line
+	x, y := len(a), len(b)
+	for ; d > 0; d-- {
+		v := trace[d]
+		// trace[d] starts at diagonal -d-1.
+		off := d + 1
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[off+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			lines = append(lines, Line{Kind: Equal, Text: a[x]})
+		}
+		if x == prevX {
+			y--
+			lines = append(lines, Line{Kind: Insert, Text: b[y]})
+		} else {
+			x--
+			lines = append(lines, Line{Kind: Delete, Text: a[x]})
+		}
+	}
+	for x > 0 {
+		x--
+		lines = append(lines, Line{Kind: Equal, Text: a[x]})
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
+
+// Apply applies hunks, which must be a subset of the hunks of a diff
+// against a in their original order, to a. Changes outside of hunks are
+// left as in a.
+func Apply(a []byte, hunks []Hunk) []byte {
+	lines := splitLines(string(a))
+
+	var sb strings.Builder
+	next := 0
+	for _, h := range hunks {
+		for ; next < h.FromLine-1; next++ {
+			sb.WriteString(lines[next])
+		}
+		for _, l := range h.Lines {
+			if l.Kind != Delete {
+				sb.WriteString(l.Text)
+			}
+		}
+		next += h.FromCount
+	}
+	for ; next < len(lines); next++ {
+		sb.WriteString(lines[next])
+	}
+
+	return []byte(sb.String())
+}
@@ -0,0 +1,250 @@
+// Package editorconfig parses .editorconfig files and resolves the properties
+// that apply to files, as described in https://editorconfig.org.
+package editorconfig
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// File is a parsed .editorconfig file.
+type File struct {
+	// Root is set when the file is the top-most one to consider.
+	Root bool
+
+	sections []section
+}
+
+type section struct {
+	glob  glob
+	props map[string]string
+}
+
+// Parse parses the contents of a .editorconfig file. Invalid lines are
+// skipped, matching the behavior of editors.
+func Parse(content []byte) *File {
+	f := &File{}
+
+	var cur *section
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			f.sections = append(f.sections, section{glob: compileGlob(line[1 : len(line)-1]), props: map[string]string{}})
+			cur = &f.sections[len(f.sections)-1]
+			continue
+		}
+
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		k = strings.ToLower(strings.TrimSpace(k))
+		v = strings.TrimSpace(v)
+
+		if cur == nil {
+			// Only root is allowed before the first section.
+			if k == "root" {
+				f.Root = strings.EqualFold(v, "true")
+			}
+			continue
+		}
+		cur.props[k] = strings.ToLower(v)
+	}
+
+	return f
+}
+
+// Properties returns the properties for the file at the slash-separated path
+// relative to the directory of the .editorconfig file, with later sections
+// taking precedence.
+func (f *File) Properties(path string) map[string]string {
+	res := map[string]string{}
+	for _, s := range f.sections {
+		if s.glob.matches(path) {
+			for k, v := range s.props {
+				res[k] = v
+			}
+		}
+	}
+	return res
+}
+
+// glob is a compiled section pattern. * matches any characters except /, **
+// any characters, ? any character except /, [name] and [!name] characters
+// in or not in name, {s1,s2} any of the patterns and {n1..n2} integers from
+// n1 to n2.
+type glob struct {
+	re *regexp.Regexp
+	// ranges are the ranges of integers matched by each group of re.
+	ranges [][2]int
+}
+
+var numRangeRE = regexp.MustCompile(`^([+-]?\d+)\.\.([+-]?\d+)$`)
+
+func compileGlob(pattern string) glob {
+	var g glob
+	prefix := ""
+	switch {
+	case strings.HasPrefix(pattern, "/"):
+		pattern = pattern[1:]
+	case !strings.Contains(pattern, "/"):
+		// Patterns without a separator match files in any directory.
+		prefix = "(?:.*/)?"
+	}
+	re, err := regexp.Compile("^" + prefix + g.translate(pattern) + "$")
+	if err != nil {
+		// Invalid patterns, such as with reversed character ranges, match
+		// nothing.
+		return glob{}
+	}
+	g.re = re
+	return g
+}
+
+// translate returns the regular expression for pattern, adding its integer
+// ranges to g.
+func (g *glob) translate(pattern string) string {
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				c = pattern[i]
+			}
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class, negated := strings.CutPrefix(pattern[i+1:i+1+end], "!")
+			if class == "" || strings.ContainsRune(class, '/') {
+				sb.WriteString(`\[`)
+				continue
+			}
+			sb.WriteByte('[')
+			if negated {
+				sb.WriteByte('^')
+			}
+			for _, r := range class {
+				if strings.ContainsRune(`\[]^`, r) {
+					sb.WriteByte('\\')
+				}
+				sb.WriteRune(r)
+			}
+			sb.WriteByte(']')
+			i += end + 1
+		case '{':
+			end := closingBrace(pattern, i)
+			if end < 0 {
+				sb.WriteString(`\{`)
+				continue
+			}
+			inner := pattern[i+1 : end]
+			i = end
+			if m := numRangeRE.FindStringSubmatch(inner); m != nil {
+				lo, _ := strconv.Atoi(m[1])
+				hi, _ := strconv.Atoi(m[2])
+				g.ranges = append(g.ranges, [2]int{min(lo, hi), max(lo, hi)})
+				sb.WriteString(`([+-]?\d+)`)
+				continue
+			}
+			alts := splitAlternatives(inner)
+			if len(alts) == 1 {
+				// A single pattern in braces is literal.
+				sb.WriteString(regexp.QuoteMeta("{") + g.translate(inner) + regexp.QuoteMeta("}"))
+				continue
+			}
+			sb.WriteString("(?:")
+			for j, alt := range alts {
+				if j > 0 {
+					sb.WriteByte('|')
+				}
+				sb.WriteString(g.translate(alt))
+			}
+			sb.WriteString(")")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+// closingBrace returns the index of the brace closing the one at start of
+// pattern, or -1 if there is none.
+func closingBrace(pattern string, start int) int {
+	depth := 0
+	for i := start; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitAlternatives splits s at the commas outside of nested braces.
+func splitAlternatives(s string) []string {
+	var res []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				res = append(res, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(res, s[start:])
+}
+
+func (g glob) matches(path string) bool {
+	if g.re == nil {
+		return false
+	}
+	m := g.re.FindStringSubmatch(path)
+	if m == nil {
+		return false
+	}
+	for i, r := range g.ranges {
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil || n < r[0] || n > r[1] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package editorconfig
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	f := Parse([]byte(`# comment
+; also a comment
+root = TRUE
+ignored = before sections
+
+[*]
+indent_style = space
+indent_size = 2
+Max_Line_Length = 100
+
+[*.{js,ts}]
+indent_size = 4
+quote_type = Single
+
+[Makefile]
+indent_style = tab
+
+[/root.md]
+indent_size = 8
+
+[lib/**.go]
+indent_style = tab
+
+not a property
+[unterminated
+`))
+
+	if !f.Root {
+		t.Error("got root false, want true")
+	}
+
+	tests := []struct {
+		path string
+		want map[string]string
+	}{
+		{
+			path: "a.md",
+			want: map[string]string{"indent_style": "space", "indent_size": "2", "max_line_length": "100"},
+		},
+		{
+			path: "sub/a.js",
+			want: map[string]string{"indent_style": "space", "indent_size": "4", "max_line_length": "100", "quote_type": "single"},
+		},
+		{
+			path: "a.ts",
+			want: map[string]string{"indent_style": "space", "indent_size": "4", "max_line_length": "100", "quote_type": "single"},
+		},
+		{
+			path: "sub/Makefile",
+			want: map[string]string{"indent_style": "tab", "indent_size": "2", "max_line_length": "100"},
+		},
+		{
+			path: "root.md",
+			want: map[string]string{"indent_style": "space", "indent_size": "8", "max_line_length": "100"},
+		},
+		{
+			path: "sub/root.md",
+			want: map[string]string{"indent_style": "space", "indent_size": "2", "max_line_length": "100"},
+		},
+		{
+			path: "lib/a/b.go",
+			want: map[string]string{"indent_style": "tab", "indent_size": "2", "max_line_length": "100"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			t.Parallel()
+
+			if got := f.Properties(tc.path); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGlob(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{pattern: "*", path: "a.js", want: true},
+		{pattern: "*", path: "sub/a.js", want: true},
+		{pattern: "*.js", path: "sub/dir/a.js", want: true},
+		{pattern: "*.js", path: "a.jsx", want: false},
+		{pattern: "sub/*.js", path: "sub/a.js", want: true},
+		{pattern: "sub/*.js", path: "sub/dir/a.js", want: false},
+		{pattern: "sub/*.js", path: "other/sub/a.js", want: false},
+		{pattern: "sub/**.js", path: "sub/dir/a.js", want: true},
+		{pattern: "sub/**/a.js", path: "sub/dir/deep/a.js", want: true},
+		{pattern: "/a.js", path: "a.js", want: true},
+		{pattern: "/a.js", path: "sub/a.js", want: false},
+		{pattern: "?.js", path: "a.js", want: true},
+		{pattern: "?.js", path: "ab.js", want: false},
+		{pattern: "[ab].js", path: "b.js", want: true},
+		{pattern: "[ab].js", path: "c.js", want: false},
+		{pattern: "[!ab].js", path: "c.js", want: true},
+		{pattern: "[!ab].js", path: "a.js", want: false},
+		{pattern: "[a-c].js", path: "b.js", want: true},
+		{pattern: "*.{js,ts}", path: "a.ts", want: true},
+		{pattern: "*.{js,ts}", path: "a.md", want: false},
+		{pattern: "{a,{b,c}}.js", path: "c.js", want: true},
+		{pattern: "{single}.js", path: "{single}.js", want: true},
+		{pattern: "{single}.js", path: "single.js", want: false},
+		{pattern: "file{1..3}.js", path: "file2.js", want: true},
+		{pattern: "file{1..3}.js", path: "file4.js", want: false},
+		{pattern: "file{-1..1}.js", path: "file-1.js", want: true},
+		{pattern: `\*.js`, path: "*.js", want: true},
+		{pattern: `\*.js`, path: "a.js", want: false},
+		{pattern: "a.js[", path: "a.js[", want: true},
+		{pattern: "[].js", path: "[].js", want: true},
+		{pattern: "[z-a].js", path: "b.js", want: false},
+		{pattern: "a+(b).js", path: "a+(b).js", want: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.pattern+" "+tc.path, func(t *testing.T) {
+			t.Parallel()
+
+			if got := compileGlob(tc.pattern).matches(tc.path); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseNotRoot(t *testing.T) {
+	t.Parallel()
+
+	f := Parse([]byte("[*]\nroot = true\n"))
+	if f.Root {
+		t.Error("got root true for property in a section, want false")
+	}
+	if got := f.Properties("a.md"); got["root"] != "true" {
+		t.Errorf("got %v, want root as a property", got)
+	}
+}
@@ -0,0 +1,167 @@
+// Package gitignore matches paths against patterns in the format of
+// .gitignore files, as described in https://git-scm.com/docs/gitignore.
+package gitignore
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"strings"
+)
+
+// Pattern is a single pattern of an ignore file.
+type Pattern struct {
+	// Source is the name of the ignore file the pattern was read from.
+	Source string
+	// Line is the 1-based line of the pattern in Source.
+	Line int
+	// Text is the pattern as written, without trailing spaces.
+	Text string
+
+	negate   bool
+	dirOnly  bool
+	segments []string
+}
+
+// Negated returns whether the pattern re-includes matching paths, i.e.
+// starts with "!".
+func (p *Pattern) Negated() bool {
+	return p.negate
+}
+
+// Matcher matches paths against patterns. Later patterns take precedence over
+// earlier ones.
+type Matcher struct {
+	patterns []*Pattern
+}
+
+// Add parses the patterns in content, read from the ignore file named source,
+// and adds them to m.
+func (m *Matcher) Add(source string, content []byte) {
+	s := bufio.NewScanner(bytes.NewReader(content))
+	line := 0
+	for s.Scan() {
+		line++
+		if p := parse(s.Text()); p != nil {
+			p.Source = source
+			p.Line = line
+			m.patterns = append(m.patterns, p)
+		}
+	}
+}
+
+func parse(text string) *Pattern {
+	text = trimTrailingSpaces(text)
+	if text == "" || text[0] == '#' {
+		return nil
+	}
+
+	p := &Pattern{Text: text}
+
+	if text[0] == '!' {
+		p.negate = true
+		text = text[1:]
+	}
+	if strings.HasSuffix(text, "/") {
+		p.dirOnly = true
+		text = strings.TrimRight(text, "/")
+	}
+	if text == "" {
+		return nil
+	}
+
+	// A separator at the beginning or middle anchors the pattern to the
+	// directory of the ignore file, otherwise it matches at any level.
+	anchored := strings.Contains(text, "/")
+	text = strings.TrimPrefix(text, "/")
+
+	p.segments = strings.Split(text, "/")
+	if !anchored {
+		p.segments = append([]string{"**"}, p.segments...)
+	}
+	for i, seg := range p.segments {
+		// Go's path.Match uses ^ rather than ! to negate character classes.
+		p.segments[i] = strings.ReplaceAll(seg, "[!", "[^")
+	}
+
+	return p
+}
+
+// trimTrailingSpaces removes trailing spaces that are not escaped with a
+// backslash.
+func trimTrailingSpaces(s string) string {
+	for strings.HasSuffix(s, " ") && !strings.HasSuffix(s, `\ `) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// Match returns the last pattern matching the slash-separated path, relative
+// to the directory of the ignore files, or nil if none match. Parent
+// directories of path are not considered.
+func (m *Matcher) Match(p string, isDir bool) *Pattern {
+	segments := strings.Split(p, "/")
+	for i := len(m.patterns) - 1; i >= 0; i-- {
+		pat := m.patterns[i]
+		if pat.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(pat.segments, segments) {
+			return pat
+		}
+	}
+	return nil
+}
+
+// Ignored returns whether the slash-separated path, relative to the
+// directory of the ignore files, is ignored, either directly or because a
+// parent directory is ignored. The last pattern that matched is returned, if
+// any.
+func (m *Matcher) Ignored(p string, isDir bool) (bool, *Pattern) {
+	segments := strings.Split(p, "/")
+
+	var match *Pattern
+	for i := range segments {
+		last := i == len(segments)-1
+		pat := m.Match(strings.Join(segments[:i+1], "/"), !last || isDir)
+		if pat == nil {
+			continue
+		}
+		match = pat
+		if !last && !pat.negate {
+			// It is not possible to re-include a file if a parent directory
+			// of that file is excluded.
+			return true, pat
+		}
+	}
+
+	return match != nil && !match.negate, match
+}
+
+func matchSegments(pattern []string, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		if len(pattern) == 1 {
+			// A trailing "/**" matches everything inside, but not the
+			// directory itself.
+			return len(segments) > 0
+		}
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
@@ -0,0 +1,78 @@
+package gitignore
+
+import (
+	"testing"
+)
+
+func TestIgnored(t *testing.T) {
+	t.Parallel()
+
+	var m Matcher
+	m.Add(".gitignore", []byte(`# comment
+build/
+/root.js
+a/**/b.js
+**/deep.js
+logs/**
+*.min.js
+!keep.min.js
+doc/frotz
+\#hash.js
+trail.js
+foo/*.txt
+[!x]y.css
+vendor
+!vendor/keep.js
+`))
+
+	// Expectations match the results of git check-ignore.
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+		line    int
+	}{
+		{path: "build", isDir: true, ignored: true, line: 2},
+		{path: "build/x.js", ignored: true, line: 2},
+		{path: "sub/build/y.js", ignored: true, line: 2},
+		{path: "build.js"},
+		{path: "root.js", ignored: true, line: 3},
+		{path: "sub/root.js"},
+		{path: "a/b.js", ignored: true, line: 4},
+		{path: "a/x/b.js", ignored: true, line: 4},
+		{path: "a/x/y/b.js", ignored: true, line: 4},
+		{path: "z/a/x/b.js"},
+		{path: "deep.js", ignored: true, line: 5},
+		{path: "x/y/deep.js", ignored: true, line: 5},
+		{path: "logs", isDir: true},
+		{path: "logs/a.js", ignored: true, line: 6},
+		{path: "logs/x/y.js", ignored: true, line: 6},
+		{path: "x.min.js", ignored: true, line: 7},
+		{path: "sub/keep.min.js", line: 8},
+		{path: "doc/frotz/a.js", ignored: true, line: 9},
+		{path: "sub/doc/frotz/a.js"},
+		{path: "#hash.js", ignored: true, line: 10},
+		{path: "trail.js", ignored: true, line: 11},
+		{path: "foo/a.txt", ignored: true, line: 12},
+		{path: "foo/x/a.txt"},
+		{path: "ay.css", ignored: true, line: 13},
+		{path: "xy.css"},
+		{path: "vendor/keep.js", ignored: true, line: 14},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			ignored, p := m.Ignored(tc.path, tc.isDir)
+			if ignored != tc.ignored {
+				t.Errorf("got ignored %v, want %v", ignored, tc.ignored)
+			}
+			line := 0
+			if p != nil {
+				line = p.Line
+			}
+			if line != tc.line {
+				t.Errorf("got line %d, want %d", line, tc.line)
+			}
+		})
+	}
+}
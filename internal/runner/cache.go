@@ -0,0 +1,156 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/wasilibs/go-prettier/internal/wasm"
+)
+
+// Strategies of RunArgs.CacheStrategy for detecting changed files.
+const (
+	// CacheStrategyContent compares the hash of the contents of files.
+	CacheStrategyContent = "content"
+	// CacheStrategyMetadata compares the size and modification time of files,
+	// which is faster but misses changes that preserve both.
+	CacheStrategyMetadata = "metadata"
+)
+
+// defaultCacheLocation is the path of the cache file relative to the working
+// directory, matching prettier.
+var defaultCacheLocation = filepath.Join("node_modules", ".cache", "prettier", ".prettier-cache")
+
+// formatCache records files known to be formatted with the options they were
+// formatted with, so that later runs can skip them.
+type formatCache struct {
+	path     string
+	strategy string
+
+	mu    sync.Mutex
+	files map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	// Options is the hash of the options of the file and the prettier
+	// version.
+	Options string `json:"options"`
+	// File is the hash or metadata of the file, depending on the strategy.
+	File string `json:"file"`
+}
+
+type cacheFile struct {
+	Strategy string                `json:"strategy"`
+	Files    map[string]cacheEntry `json:"files"`
+}
+
+// CacheLocation returns the path to the cache file of runs with args, which
+// may not exist.
+func CacheLocation(args RunArgs) string {
+	if args.CacheLocation != "" {
+		return args.CacheLocation
+	}
+	return filepath.Join(args.Dir, defaultCacheLocation)
+}
+
+func checkCacheStrategy(strategy string) error {
+	switch strategy {
+	case "", CacheStrategyContent, CacheStrategyMetadata:
+		return nil
+	default:
+		return fmt.Errorf("runner: invalid cache strategy %q, expected %s or %s", strategy, CacheStrategyContent, CacheStrategyMetadata)
+	}
+}
+
+// loadCache reads the cache of args. A missing or unreadable cache file, or
+// one written with a different strategy, starts an empty cache.
+func loadCache(args RunArgs) *formatCache {
+	c := &formatCache{
+		path:     CacheLocation(args),
+		strategy: args.CacheStrategy,
+		files:    map[string]cacheEntry{},
+	}
+	if c.strategy == "" {
+		c.strategy = CacheStrategyContent
+	}
+
+	b, err := os.ReadFile(c.path)
+	if err != nil {
+		return c
+	}
+	var f cacheFile
+	if err := json.Unmarshal(b, &f); err != nil || f.Strategy != c.strategy || f.Files == nil {
+		return c
+	}
+	c.files = f.Files
+	return c
+}
+
+// optionsKey returns the key of formatting with pCfg by the module with
+// wasmDigest, which changes with the options, the version of prettier and its
+// plugins, and the module, such as one passed to WithWasm.
+func optionsKey(wasmDigest [sha256.Size]byte, pCfg map[string]any) string {
+	// Maps are marshaled with sorted keys, so equal options have equal keys.
+	b, err := json.Marshal(pCfg)
+	if err != nil {
+		// Programming bug
+		panic(err)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%x\n%s\n", wasm.PrettierVersion, wasmDigest, strings.Join(wasm.Plugins, ","))
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fileKey returns the key of the file with info fi and content according to
+// the strategy of the cache.
+func (c *formatCache) fileKey(fi fs.FileInfo, content []byte) string {
+	if c.strategy == CacheStrategyMetadata {
+		return fmt.Sprintf("%d-%d", fi.Size(), fi.ModTime().UnixNano())
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// isFormatted returns whether the file at path was formatted with the same
+// options and is unchanged since.
+func (c *formatCache) isFormatted(path string, entry cacheEntry) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.files[path] == entry
+}
+
+// record adds the file at path as formatted. Entries of files that are no
+// longer formatted are left as is, since they don't match the file anymore.
+func (c *formatCache) record(path string, entry cacheEntry) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[path] = entry
+}
+
+// save writes the cache file.
+func (c *formatCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, err := json.Marshal(cacheFile{Strategy: c.strategy, Files: c.files})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, b, 0o644)
+}
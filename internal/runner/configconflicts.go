@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// warnConfigConflicts logs a warning for each config source, other than the
+// used config file at cfgPath with options pCfg, that applies to the working
+// directory but is ignored. Sources in the same directory as cfgPath are always
+// reported, while those in parent directories are only reported if they set
+// options to different values, since prettier does not merge them.
+func warnConfigConflicts(ctx context.Context, fsys fileSystem, cfgPath string, pCfg map[string]any) {
+	for _, name := range ConfigFileNames {
+		for _, p := range fsys.findUp(name) {
+			if p == cfgPath || isPackageFile(p) && !hasPrettierKey(fsys, p) {
+				continue
+			}
+
+			sameDir := filepath.Dir(p) == filepath.Dir(cfgPath)
+
+			b, err := fsys.readFile(p)
+			if err != nil {
+				continue
+			}
+			other, err := parseConfigFile(p, b)
+			if err != nil {
+				continue
+			}
+
+			diffs := diffOptions(pCfg, other)
+			switch {
+			case sameDir:
+				logger(ctx).WarnContext(ctx, fmt.Sprintf(`Multiple config files found in "%s", using "%s" and ignoring "%s".%s`,
+					filepath.Dir(cfgPath), filepath.Base(cfgPath), filepath.Base(p), describeDiffs(diffs)))
+			case len(diffs) > 0:
+				logger(ctx).WarnContext(ctx, fmt.Sprintf(`Config file "%s" is ignored in favor of "%s", which it conflicts with.%s`,
+					p, cfgPath, describeDiffs(diffs)))
+			}
+		}
+	}
+}
+
+// diffOptions returns descriptions of options set in both used and other to
+// different values, sorted by option name.
+func diffOptions(used map[string]any, other map[string]any) []string {
+	var res []string
+	for k, v := range used {
+		if k == "presets" {
+			continue
+		}
+		ov, ok := other[k]
+		if !ok {
+			continue
+		}
+		if fmt.Sprint(v) != fmt.Sprint(ov) {
+			res = append(res, fmt.Sprintf("%s: %v (used) vs %v", k, v, ov))
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func describeDiffs(diffs []string) string {
+	if len(diffs) == 0 {
+		return ""
+	}
+	return " Conflicting options: " + strings.Join(diffs, ", ")
+}
@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// isJSConfigFile returns whether the config file at p can only be loaded by
+// prettier running on Node.
+func isJSConfigFile(p string) bool {
+	return slices.Contains(jsConfigFileNames, path.Base(filepath.ToSlash(p)))
+}
+
+// isPackageFile returns whether the config file at p is a package manifest,
+// which only has prettier options under its "prettier" key.
+func isPackageFile(p string) bool {
+	switch path.Base(filepath.ToSlash(p)) {
+	case "package.json", "package.yaml":
+		return true
+	}
+	return false
+}
+
+// hasPrettierKey returns whether the package manifest at p has a "prettier"
+// key, without which prettier ignores it when searching for config files.
+func hasPrettierKey(fsys fileSystem, p string) bool {
+	b, err := fsys.readFile(p)
+	if err != nil {
+		return false
+	}
+	var pkg map[string]any
+	if err := yaml.Unmarshal(b, &pkg); err != nil {
+		return false
+	}
+	_, ok := pkg["prettier"]
+	return ok
+}
+
+// parseConfigFile parses content as the config file at p, which may be a
+// package manifest or a JSON5 file in addition to the formats understood by
+// ParseConfig.
+func parseConfigFile(p string, content []byte) (map[string]any, error) {
+	switch {
+	case isJSConfigFile(p):
+		return map[string]any{}, fmt.Errorf("%w: JavaScript config files can only be used with --delegate-to-node", ErrConfigInvalid)
+	case isPackageFile(p):
+		var pkg map[string]any
+		if err := yaml.Unmarshal(content, &pkg); err != nil {
+			return map[string]any{}, fmt.Errorf("%w: %w", ErrConfigInvalid, err)
+		}
+		switch v := pkg["prettier"].(type) {
+		case map[string]any:
+			return v, nil
+		case string:
+			return map[string]any{}, fmt.Errorf("%w: shared config %q in the \"prettier\" key can only be used with --delegate-to-node", ErrConfigInvalid, v)
+		default:
+			return map[string]any{}, fmt.Errorf("%w: the \"prettier\" key must be a map of options", ErrConfigInvalid)
+		}
+	case path.Ext(filepath.ToSlash(p)) == ".json5":
+		return ParseConfig(stripJSON5(content))
+	}
+	return ParseConfig(content)
+}
+
+// stripJSON5 removes comments and trailing commas from JSON5 content. The
+// remaining syntax of JSON5, such as unquoted keys and single-quoted strings,
+// is also valid YAML.
+func stripJSON5(content []byte) []byte {
+	noComments := scanJSON5(content, func(rest []byte, res *bytes.Buffer) int {
+		switch {
+		case bytes.HasPrefix(rest, []byte("//")):
+			n := bytes.IndexByte(rest, '\n')
+			if n < 0 {
+				return len(rest)
+			}
+			return n
+		case bytes.HasPrefix(rest, []byte("/*")):
+			n := bytes.Index(rest[2:], []byte("*/"))
+			res.WriteByte(' ')
+			if n < 0 {
+				return len(rest)
+			}
+			return n + 4
+		}
+		return 0
+	})
+	return scanJSON5(noComments, func(rest []byte, _ *bytes.Buffer) int {
+		if rest[0] != ',' {
+			return 0
+		}
+		next := bytes.TrimLeft(rest[1:], " \t\r\n")
+		if len(next) > 0 && (next[0] == '}' || next[0] == ']') {
+			return 1
+		}
+		return 0
+	})
+}
+
+// scanJSON5 copies content outside of strings through skip, which returns the
+// number of bytes at the start of rest to drop, writing any replacement to
+// res.
+func scanJSON5(content []byte, skip func(rest []byte, res *bytes.Buffer) int) []byte {
+	var res bytes.Buffer
+	var quote byte
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case quote != 0:
+			res.WriteByte(c)
+			if c == '\\' && i+1 < len(content) {
+				i++
+				res.WriteByte(content[i])
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			res.WriteByte(c)
+		default:
+			if n := skip(content[i:], &res); n > 0 {
+				i += n - 1
+				continue
+			}
+			res.WriteByte(c)
+		}
+	}
+	return res.Bytes()
+}
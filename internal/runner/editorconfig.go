@@ -0,0 +1,121 @@
+package runner
+
+import (
+	"maps"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/wasilibs/go-prettier/internal/editorconfig"
+)
+
+// editorConfigResolver resolves prettier options for files from .editorconfig
+// files, caching parsed files by directory.
+type editorConfigResolver struct {
+	fsys fileSystem
+
+	mu    sync.Mutex
+	files map[string]*editorconfig.File
+}
+
+func newEditorConfigResolver(fsys fileSystem) *editorConfigResolver {
+	return &editorConfigResolver{
+		fsys:  fsys,
+		files: map[string]*editorconfig.File{},
+	}
+}
+
+// options returns the prettier options for the file at path from the
+// .editorconfig files in its directory and parents, or nil if there are none.
+func (r *editorConfigResolver) options(path string) map[string]any {
+	abs := r.fsys.abs(path)
+
+	type found struct {
+		dir  string
+		file *editorconfig.File
+	}
+	var chain []found
+	for dir := filepath.Dir(abs); ; {
+		if f := r.load(dir); f != nil {
+			chain = append(chain, found{dir: dir, file: f})
+			if f.Root {
+				break
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	props := map[string]string{}
+	// Closer files take precedence.
+	for i := len(chain) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(chain[i].dir, abs)
+		if err != nil {
+			continue
+		}
+		maps.Copy(props, chain[i].file.Properties(filepath.ToSlash(rel)))
+	}
+
+	return editorConfigOptions(props)
+}
+
+func (r *editorConfigResolver) load(dir string) *editorconfig.File {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.files[dir]; ok {
+		return f
+	}
+
+	var f *editorconfig.File
+	if b, err := r.fsys.readFile(filepath.Join(dir, ".editorconfig")); err == nil {
+		f = editorconfig.Parse(b)
+	}
+	r.files[dir] = f
+	return f
+}
+
+// editorConfigOptions maps EditorConfig properties to prettier options, the
+// same as prettier's editorconfig-to-prettier.
+func editorConfigOptions(props map[string]string) map[string]any {
+	opts := map[string]any{}
+
+	switch props["indent_style"] {
+	case "tab":
+		opts["useTabs"] = true
+	case "space":
+		opts["useTabs"] = false
+	}
+
+	size := props["indent_size"]
+	if size == "" || size == "tab" {
+		size = props["tab_width"]
+	}
+	if n, err := strconv.Atoi(size); err == nil {
+		opts["tabWidth"] = n
+	}
+
+	if n, err := strconv.Atoi(props["max_line_length"]); err == nil {
+		opts["printWidth"] = n
+	}
+
+	switch v := props["end_of_line"]; v {
+	case "lf", "crlf", "cr":
+		opts["endOfLine"] = v
+	}
+
+	switch props["quote_type"] {
+	case "single":
+		opts["singleQuote"] = true
+	case "double":
+		opts["singleQuote"] = false
+	}
+
+	if len(opts) == 0 {
+		return nil
+	}
+	return opts
+}
@@ -1,17 +1,15 @@
 package runner
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"io/fs"
-	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/bmatcuk/doublestar/v4"
-	"github.com/denormal/go-gitignore"
 )
 
 // https://github.com/prettier/prettier/blob/main/src/cli/expand-patterns.js
@@ -24,10 +22,36 @@ const (
 	pathTypeGlob
 )
 
-type expandedPath struct {
-	filePath      string
-	ignoreUnknown bool
-	error         string
+// ExpandedPath is a single entry resulting from expanding the patterns of a run.
+type ExpandedPath struct {
+	// FilePath is the path of the file to format.
+	FilePath string
+	// IgnoreUnknown is set for files found by walking a directory, which are
+	// skipped silently when no parser can be inferred for them.
+	IgnoreUnknown bool
+	// Error is set instead of FilePath when a pattern could not be expanded.
+	Error string
+	// Options are the prettier options for the file from .editorconfig
+	// files, which the config file takes precedence over.
+	Options map[string]any
+	// Overrides are the prettier options for the file from RunArgs.Overrides,
+	// which take precedence over the config file.
+	Overrides map[string]any
+}
+
+// Config returns the prettier options to format the file with, given the
+// options from the config file.
+func (p ExpandedPath) Config(pCfg map[string]any) map[string]any {
+	if len(p.Options) == 0 && len(p.Overrides) == 0 {
+		return pCfg
+	}
+	res := maps.Clone(p.Options)
+	if res == nil {
+		res = map[string]any{}
+	}
+	maps.Copy(res, pCfg)
+	maps.Copy(res, p.Overrides)
+	return res
 }
 
 type expandedPattern struct {
@@ -35,46 +59,21 @@ type expandedPattern struct {
 	path     string
 }
 
-func expandPatterns(ctx context.Context, args RunArgs, root string) []expandedPath {
-	var res []expandedPath
+func expandPatterns(ctx context.Context, args RunArgs, fsys fileSystem, root string) []ExpandedPath {
+	var res []ExpandedPath
 
 	var expanded []expandedPattern
 
-	ignoreFile := `
-.git
-.sl
-.svn
-.hg
-`
-	if !args.WithNodeModules {
-		ignoreFile += `
-node_modules
-`
-	}
-
-	for _, p := range args.IgnorePaths {
-		f, err := os.Open(filepath.Join(root, p))
-		if err != nil {
-			continue
-		}
-		defer f.Close()
-
-		s := bufio.NewScanner(f)
-		for s.Scan() {
-			ignoreFile += s.Text() + "\n"
-		}
-	}
-
 	for _, pattern := range args.Patterns {
-		fi, err := os.Lstat(pattern)
+		fi, err := fsys.lstat(pattern)
 		switch {
 		case err == nil:
 			switch {
 			case fi.Mode()&os.ModeSymlink != 0:
 				if args.NoErrorOnUnmatchedPattern {
-					res = append(res, expandedPath{error: fmt.Sprintf(`Explicitly specified pattern "%s" is a symbolic link.`, pattern)})
+					res = append(res, ExpandedPath{Error: fmt.Sprintf(`Explicitly specified pattern "%s" is a symbolic link.`, pattern)})
 				} else {
-					slog.DebugContext(ctx, fmt.Sprintf(`Skipping pattern "%s", as it is a symbolic link.`, pattern))
+					logger(ctx).DebugContext(ctx, fmt.Sprintf(`Skipping pattern "%s", as it is a symbolic link.`, pattern))
 				}
 			case fi.Mode().IsRegular():
 				expanded = append(expanded, expandedPattern{pathType: pathTypeFile, path: pattern})
@@ -82,62 +81,73 @@ node_modules
 				expanded = append(expanded, expandedPattern{pathType: pathTypeDir, path: pattern})
 			}
 		case pattern[0] == '!':
-			ignoreFile += filepath.ToSlash(pattern[1:]) + "\n"
+			// Handled by the ignorer.
 		default:
 			expanded = append(expanded, expandedPattern{pathType: pathTypeGlob, path: pattern})
 		}
 	}
 
-	base, _ := filepath.Abs(root)
-	ignore := gitignore.New(strings.NewReader(ignoreFile), base, nil)
+	ignore := newIgnorer(args, fsys, root)
+	base := ignore.base
 
 	seen := map[string]struct{}{}
 	for _, ep := range expanded {
 		switch ep.pathType {
 		case pathTypeFile:
-			if ignore.Ignore(ep.path) {
+			if ignored, _ := ignore.isIgnored(ep.path, false); ignored {
 				continue
 			}
 
 			if _, ok := seen[ep.path]; !ok {
-				res = append(res, expandedPath{filePath: ep.path})
+				res = append(res, ExpandedPath{FilePath: ep.path})
 				seen[ep.path] = struct{}{}
 			}
 		case pathTypeDir:
-			if err := filepath.Walk(ep.path, func(path string, fi os.FileInfo, err error) error {
+			if err := fsys.walkDir(ep.path, func(path string, d fs.DirEntry, err error) error {
 				if err != nil {
 					return err
 				}
 
-				p, _ := filepath.Abs(path)
+				p := fsys.abs(path)
 				if p == base {
 					return nil
 				}
-				if m := ignore.Absolute(p, fi.IsDir()); m != nil && m.Ignore() {
-					return filepath.SkipDir
+				if ignore.ignoredAbs(p, d.IsDir()) {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					// SkipDir for a file would skip the rest of its directory.
+					return nil
 				}
 
-				if fi.IsDir() {
+				if d.IsDir() {
+					if args.MaxDepth > 0 && pathDepth(ep.path, path) >= args.MaxDepth {
+						return filepath.SkipDir
+					}
 					return nil
 				}
 
 				if _, ok := seen[path]; !ok {
-					res = append(res, expandedPath{filePath: path, ignoreUnknown: true})
+					res = append(res, ExpandedPath{FilePath: path, IgnoreUnknown: true})
 				}
 
 				return nil
 			}); err != nil {
-				res = append(res, expandedPath{error: fmt.Sprintf(`Unable to expand directory: "%s".\n%s`, ep.path, err)})
+				res = append(res, ExpandedPath{Error: fmt.Sprintf(`Unable to expand directory: "%s".\n%s`, ep.path, err)})
 			}
 		case pathTypeGlob:
 			matched := false
-			if err := doublestar.GlobWalk(os.DirFS("."), ep.path, func(path string, d fs.DirEntry) error {
-				p, _ := filepath.Abs(path)
+			if err := doublestar.GlobWalk(fsys.globFS(), ep.path, func(path string, d fs.DirEntry) error {
+				p := fsys.abs(path)
 				if p == base {
 					return nil
 				}
-				if m := ignore.Absolute(p, d.IsDir()); m != nil && m.Ignore() {
-					return filepath.SkipDir
+				if ignore.ignoredAbs(p, d.IsDir()) {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					// SkipDir for a file would skip the rest of its directory.
+					return nil
 				}
 
 				if d.IsDir() {
@@ -146,19 +156,29 @@ node_modules
 
 				matched = true
 				if _, ok := seen[path]; !ok {
-					res = append(res, expandedPath{filePath: path})
+					res = append(res, ExpandedPath{FilePath: path})
 					seen[path] = struct{}{}
 				}
 
 				return nil
 			}, doublestar.WithNoFollow()); err != nil {
-				res = append(res, expandedPath{error: fmt.Sprintf(`Unable to expand glob pattern: "%s".\n%s`, ep.path, err)})
+				res = append(res, ExpandedPath{Error: fmt.Sprintf(`Unable to expand glob pattern: "%s".\n%s`, ep.path, err)})
 			}
 			if !matched && !args.NoErrorOnUnmatchedPattern {
-				res = append(res, expandedPath{error: fmt.Sprintf(`No files matching the pattern were found: "%s".`, ep.path)})
+				res = append(res, ExpandedPath{Error: fmt.Sprintf(`No files matching the pattern were found: "%s".`, ep.path)})
 			}
 		}
 	}
 
 	return res
 }
+
+// pathDepth returns the number of path elements of path below the directory
+// root.
+func pathDepth(root string, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(filepath.ToSlash(rel), "/") + 1
+}
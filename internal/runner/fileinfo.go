@@ -0,0 +1,54 @@
+package runner
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+)
+
+// FileInfo describes how a file is handled by a run, like the getFileInfo
+// function of prettier.
+type FileInfo struct {
+	// Ignored is whether the file is ignored by the ignore files of the run.
+	Ignored bool `json:"ignored"`
+	// InferredParser is the parser the file is formatted with, or empty if
+	// none could be inferred.
+	InferredParser string `json:"inferredParser"`
+}
+
+// FileInfo returns how the file at path is handled by a run with args,
+// without reading or formatting it. The parser set for the file by the
+// resolved config, such as by overrides, takes precedence over the one
+// inferred from its path. Parsers are only inferred for the languages of the
+// embedded prettier, not those of plugins in a module passed to WithWasm.
+func (r *Runner) FileInfo(ctx context.Context, args RunArgs, path string) (FileInfo, error) {
+	pCfg, err := r.ResolveConfig(ctx, args, path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	ignored, _ := r.IsIgnored(args, path)
+	parser, _ := pCfg["parser"].(string)
+	if parser == "" {
+		parser = inferParser(path)
+	}
+	return FileInfo{Ignored: ignored, InferredParser: parser}, nil
+}
+
+// inferParser returns the parser prettier infers for the file at path from
+// supportedLanguages, or empty if there is none.
+func inferParser(path string) string {
+	base := strings.ToLower(filepath.Base(path))
+	for _, l := range supportedLanguages {
+		for _, name := range l.Filenames {
+			if strings.ToLower(name) == base {
+				return l.Parsers[0]
+			}
+		}
+		for _, ext := range l.Extensions {
+			if strings.HasSuffix(base, strings.ToLower(ext)) {
+				return l.Parsers[0]
+			}
+		}
+	}
+	return ""
+}
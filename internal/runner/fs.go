@@ -0,0 +1,165 @@
+package runner
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// fileSystem is the filesystem a run reads patterns, config and ignore files
+// from, and writes formatted files to.
+type fileSystem interface {
+	stat(name string) (fs.FileInfo, error)
+	lstat(name string) (fs.FileInfo, error)
+	readFile(name string) ([]byte, error)
+	writeFile(name string, data []byte, perm fs.FileMode) error
+	walkDir(root string, fn fs.WalkDirFunc) error
+	// globFS is the filesystem glob patterns are matched in.
+	globFS() fs.FS
+	// abs returns a normalized absolute form of name for matching against
+	// ignore files.
+	abs(name string) string
+	// findUp returns the paths to the files with the given name in the
+	// working directory and its parents, closest first.
+	findUp(name string) []string
+}
+
+func newFileSystem(args RunArgs) fileSystem {
+	if args.fsys != nil {
+		return args.fsys
+	}
+	if args.FS != nil {
+		return &virtualFS{fsys: args.FS, write: args.WriteFile}
+	}
+	return osFS{dir: args.Dir, write: args.WriteFile}
+}
+
+// osFS is the OS filesystem with relative paths resolved against dir, or the
+// working directory if it is empty. Files are written through write instead
+// if it is set.
+type osFS struct {
+	dir   string
+	write func(path string, content []byte) error
+}
+
+func (o osFS) stat(name string) (fs.FileInfo, error) {
+	return os.Stat(o.path(name))
+}
+
+func (o osFS) lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(o.path(name))
+}
+
+func (o osFS) readFile(name string) ([]byte, error) {
+	return os.ReadFile(o.path(name))
+}
+
+func (o osFS) writeFile(name string, data []byte, perm fs.FileMode) error {
+	if o.write != nil {
+		return o.write(name, data)
+	}
+	return os.WriteFile(o.path(name), data, perm)
+}
+
+func (o osFS) walkDir(root string, fn fs.WalkDirFunc) error {
+	if o.dir == "" || filepath.IsAbs(root) {
+		return filepath.WalkDir(root, fn)
+	}
+	// Walked paths are passed to fn relative to dir, like root.
+	base := o.path(root)
+	return filepath.WalkDir(base, func(p string, d fs.DirEntry, err error) error {
+		if rel, rErr := filepath.Rel(base, p); rErr == nil {
+			p = filepath.Join(root, rel)
+		}
+		return fn(p, d, err)
+	})
+}
+
+func (o osFS) globFS() fs.FS {
+	return os.DirFS(o.path("."))
+}
+
+func (o osFS) abs(name string) string {
+	p, _ := filepath.Abs(o.path(name))
+	return p
+}
+
+func (o osFS) findUp(name string) []string {
+	return findConfigFiles(o.path("."), name)
+}
+
+// path returns name resolved against dir.
+func (o osFS) path(name string) string {
+	if o.dir == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(o.dir, name)
+}
+
+var errNoWriteFile = errors.New("runner: WriteFile must be set to write files with FS")
+
+// virtualFS reads from a caller-provided fs.FS, which is treated as the
+// working directory, and writes through a caller-provided function.
+type virtualFS struct {
+	fsys  fs.FS
+	write func(path string, content []byte) error
+}
+
+func (v *virtualFS) stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(v.fsys, v.clean(name))
+}
+
+func (v *virtualFS) lstat(name string) (fs.FileInfo, error) {
+	// fs.FS has no notion of symbolic links so this is the same as stat.
+	return v.stat(name)
+}
+
+func (v *virtualFS) readFile(name string) ([]byte, error) {
+	return fs.ReadFile(v.fsys, v.clean(name))
+}
+
+func (v *virtualFS) writeFile(name string, data []byte, _ fs.FileMode) error {
+	if v.write == nil {
+		return errNoWriteFile
+	}
+	return v.write(v.clean(name), data)
+}
+
+func (v *virtualFS) walkDir(root string, fn fs.WalkDirFunc) error {
+	return fs.WalkDir(v.fsys, v.clean(root), fn)
+}
+
+func (v *virtualFS) globFS() fs.FS {
+	return v.fsys
+}
+
+func (v *virtualFS) abs(name string) string {
+	// Ignore matching strips the base and a separator from absolute paths,
+	// so the root is represented as an empty string rather than "/".
+	p := v.clean(name)
+	if p == "." {
+		return ""
+	}
+	return "/" + p
+}
+
+func (v *virtualFS) findUp(name string) []string {
+	// The root of the filesystem is the working directory and has no parent.
+	if _, err := fs.Stat(v.fsys, name); err == nil {
+		return []string{name}
+	}
+	return nil
+}
+
+func (v *virtualFS) clean(name string) string {
+	// Paths returned by abs start with a separator, but fs.FS paths are
+	// unrooted.
+	p := strings.TrimPrefix(path.Clean(filepath.ToSlash(name)), "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
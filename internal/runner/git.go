@@ -0,0 +1,143 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var errGitUnsupportedFS = errors.New("runner: git can only be used with the OS filesystem")
+
+// gitOutput runs git with args in dir, or the working directory if it is
+// empty, returning its standard output.
+func gitOutput(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	return gitInput(ctx, dir, nil, args...)
+}
+
+// gitInput runs git like gitOutput with stdin as its standard input.
+func gitInput(ctx context.Context, dir string, stdin []byte, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("runner: failed to run git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("runner: failed to run git %s: %w", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// gitRoot returns the absolute path of the root of the repository of dir, or
+// the working directory if it is empty. Unlike rev-parse --show-toplevel,
+// symlinks are not resolved, so that paths under it match the absolute paths
+// of files in a checkout reached through a symlink.
+func gitRoot(ctx context.Context, dir string) (string, error) {
+	cdup, err := gitOutput(ctx, dir, "rev-parse", "--show-cdup")
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(abs, strings.TrimSpace(string(cdup))), nil
+}
+
+// gitFiles runs a git command in dir listing NUL-separated paths relative to
+// the root of the repository, returning them as a set of absolute paths.
+func gitFiles(ctx context.Context, dir string, args ...string) (map[string]struct{}, error) {
+	root, err := gitRoot(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := gitOutput(ctx, dir, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]struct{}{}
+	for _, p := range strings.Split(string(out), "\x00") {
+		if p != "" {
+			res[filepath.Join(root, filepath.FromSlash(p))] = struct{}{}
+		}
+	}
+	return res, nil
+}
+
+// filterGitFiles returns the paths that are in files, a set of absolute paths.
+// Paths with errors are always kept.
+func filterGitFiles(fsys fileSystem, paths []ExpandedPath, files map[string]struct{}) []ExpandedPath {
+	var res []ExpandedPath
+	for _, p := range paths {
+		if p.Error != "" {
+			res = append(res, p)
+			continue
+		}
+		if _, ok := files[fsys.abs(p.FilePath)]; ok {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// gitDirtyFiles returns the absolute paths of files with uncommitted changes in
+// the repository of dir, including untracked files that are not ignored.
+func gitDirtyFiles(ctx context.Context, dir string) (map[string]struct{}, error) {
+	return gitFilesChangedFrom(ctx, dir, "HEAD")
+}
+
+// gitChangedFiles returns the absolute paths of files changed since the merge
+// base of ref and HEAD in the repository of dir, including uncommitted changes
+// and untracked files that are not ignored, such as the files of a pull
+// request.
+func gitChangedFiles(ctx context.Context, dir string, ref string) (map[string]struct{}, error) {
+	base, err := gitOutput(ctx, dir, "merge-base", ref, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	return gitFilesChangedFrom(ctx, dir, strings.TrimSpace(string(base)))
+}
+
+// gitFilesChangedFrom returns the absolute paths of files in the working tree
+// of the repository of dir that differ from commit, or are untracked and not
+// ignored.
+func gitFilesChangedFrom(ctx context.Context, dir string, commit string) (map[string]struct{}, error) {
+	changed, err := gitFiles(ctx, dir, "diff", commit, "--name-only", "-z")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := gitFiles(ctx, dir, "ls-files", "-z", "--full-name", "--others", "--exclude-standard", ":/")
+	if err != nil {
+		return nil, err
+	}
+	for p := range untracked {
+		changed[p] = struct{}{}
+	}
+	return changed, nil
+}
+
+// dirtyFirst moves the paths that are in dirty, a set of absolute paths, to
+// the front, otherwise keeping the order of paths.
+func dirtyFirst(fsys fileSystem, paths []ExpandedPath, dirty map[string]struct{}) []ExpandedPath {
+	res := make([]ExpandedPath, 0, len(paths))
+	var rest []ExpandedPath
+	for _, p := range paths {
+		if _, ok := dirty[fsys.abs(p.FilePath)]; ok && p.Error == "" {
+			res = append(res, p)
+		} else {
+			rest = append(rest, p)
+		}
+	}
+	return append(res, rest...)
+}
@@ -0,0 +1,199 @@
+package runner
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/wasilibs/go-prettier/internal/gitignore"
+)
+
+// ignorer decides whether paths are ignored by the default ignores, ignore
+// files and negated patterns of a run.
+type ignorer struct {
+	fsys    fileSystem
+	root    string
+	base    string
+	matcher gitignore.Matcher
+
+	// nestedNames are the names of ignore files that also apply in
+	// subdirectories of the root, relative to their own directory.
+	nestedNames []string
+	// nested caches the matchers of ignore files in subdirectories, keyed by
+	// their slash-separated paths relative to the root, nil if there are none.
+	nested map[string]*gitignore.Matcher
+}
+
+// Names of ignore sources that are not files.
+const (
+	ignoreSourceDefaults = "(default)"
+	ignoreSourcePatterns = "(pattern)"
+)
+
+// defaultIgnorePaths are the ignore files used when RunArgs.IgnorePaths is
+// nil, like prettier's CLI. Patterns in .prettierignore take precedence.
+var defaultIgnorePaths = []string{".gitignore", ".prettierignore"}
+
+func newIgnorer(args RunArgs, fsys fileSystem, root string) *ignorer {
+	i := &ignorer{
+		fsys:   fsys,
+		root:   root,
+		base:   fsys.abs(root),
+		nested: map[string]*gitignore.Matcher{},
+	}
+
+	defaults := `.git
+.sl
+.svn
+.hg
+`
+	if !args.WithNodeModules {
+		defaults += `node_modules
+`
+	}
+	i.matcher.Add(ignoreSourceDefaults, []byte(defaults))
+
+	ignorePaths := args.IgnorePaths
+	if ignorePaths == nil {
+		ignorePaths = defaultIgnorePaths
+		if args.NoGitIgnore {
+			ignorePaths = ignorePaths[1:]
+		}
+	}
+	for _, p := range ignorePaths {
+		b, err := fsys.readFile(filepath.Join(root, p))
+		if err != nil {
+			continue
+		}
+		i.matcher.Add(p, b)
+	}
+	for _, p := range ignorePaths {
+		// Ignore files given by name, such as .prettierignore, are also read
+		// from subdirectories like .gitignore files.
+		if filepath.Base(p) == p {
+			i.nestedNames = append(i.nestedNames, p)
+		}
+	}
+
+	var negated strings.Builder
+	for _, pattern := range args.Patterns {
+		if _, err := fsys.lstat(pattern); err == nil || !strings.HasPrefix(pattern, "!") {
+			continue
+		}
+		negated.WriteString(filepath.ToSlash(pattern[1:]) + "\n")
+	}
+	i.matcher.Add(ignoreSourcePatterns, []byte(negated.String()))
+
+	return i
+}
+
+// rel returns the slash-separated path of the absolute path p relative to
+// the ignore root, or false if p is not inside it.
+func (i *ignorer) rel(p string) (string, bool) {
+	return relPath(i.base, p)
+}
+
+// relPath returns the slash-separated path of the absolute path p relative to
+// the absolute path base, or false if p is not inside it.
+func relPath(base string, p string) (string, bool) {
+	if p == base || !strings.HasPrefix(p, base) {
+		return "", false
+	}
+	rel := p[len(base):]
+	if rel[0] != '/' && rel[0] != filepath.Separator {
+		// A sibling sharing a prefix with the base, e.g. /foo-bar for /foo.
+		return "", false
+	}
+	return filepath.ToSlash(rel[1:]), true
+}
+
+// nestedMatcher returns the matcher of the ignore files in dir, a
+// slash-separated path relative to the root, or nil if there are none.
+func (i *ignorer) nestedMatcher(dir string) *gitignore.Matcher {
+	if m, ok := i.nested[dir]; ok {
+		return m
+	}
+	var m *gitignore.Matcher
+	for _, name := range i.nestedNames {
+		b, err := i.fsys.readFile(filepath.Join(i.root, filepath.FromSlash(dir), name))
+		if err != nil {
+			continue
+		}
+		if m == nil {
+			m = &gitignore.Matcher{}
+		}
+		m.Add(path.Join(dir, name), b)
+	}
+	i.nested[dir] = m
+	return m
+}
+
+// match returns the pattern deciding whether the slash-separated path rel,
+// relative to the root, is ignored, without considering its parent
+// directories. Ignore files in deeper directories take precedence.
+func (i *ignorer) match(rel string, isDir bool) *gitignore.Pattern {
+	if len(i.nestedNames) > 0 {
+		for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+			m := i.nestedMatcher(dir)
+			if m == nil {
+				continue
+			}
+			if pat := m.Match(rel[len(dir)+1:], isDir); pat != nil {
+				return pat
+			}
+		}
+	}
+	return i.matcher.Match(rel, isDir)
+}
+
+// ignoredAbs returns whether the absolute path p is ignored, without
+// considering its parent directories.
+func (i *ignorer) ignoredAbs(p string, isDir bool) bool {
+	rel, ok := i.rel(p)
+	if !ok {
+		return false
+	}
+	m := i.match(rel, isDir)
+	return m != nil && !m.Negated()
+}
+
+// isIgnored returns whether path, or any of its parent directories under the
+// ignore root, is ignored, along with the rule that decided it.
+func (i *ignorer) isIgnored(p string, isDir bool) (bool, string) {
+	rel, ok := i.rel(i.fsys.abs(p))
+	if !ok {
+		return false, ""
+	}
+
+	segments := strings.Split(rel, "/")
+	var m *gitignore.Pattern
+	for n := range segments {
+		last := n == len(segments)-1
+		pat := i.match(strings.Join(segments[:n+1], "/"), !last || isDir)
+		if pat == nil {
+			continue
+		}
+		m = pat
+		if !last && !pat.Negated() {
+			// It is not possible to re-include a file if a parent directory
+			// of that file is excluded.
+			break
+		}
+	}
+	if m == nil {
+		return false, ""
+	}
+	return !m.Negated(), describeIgnoreRule(m)
+}
+
+func describeIgnoreRule(m *gitignore.Pattern) string {
+	switch m.Source {
+	case ignoreSourceDefaults:
+		return m.Text + " " + ignoreSourceDefaults
+	case ignoreSourcePatterns:
+		return "!" + m.Text + " " + ignoreSourcePatterns
+	default:
+		return fmt.Sprintf("%s:%d: %s", m.Source, m.Line, m.Text)
+	}
+}
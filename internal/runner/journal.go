@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+const journalHeader = "# go-prettier journal, delete to restart the run from scratch"
+
+// journal records the files a run has completed, so that an interrupted run
+// can be resumed.
+type journal struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// openJournal opens the journal at path for appending, returning the files
+// already recorded in it if resume is set. Otherwise, the journal is
+// truncated.
+func openJournal(path string, resume bool) (*journal, map[string]struct{}, error) {
+	done := map[string]struct{}{}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		b, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, fmt.Errorf("runner: failed to read journal: %w", err)
+		}
+		s := bufio.NewScanner(bytes.NewReader(b))
+		for s.Scan() {
+			if line := s.Text(); line != "" && line != journalHeader {
+				done[line] = struct{}{}
+			}
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("runner: failed to open journal: %w", err)
+	}
+	if len(done) == 0 {
+		if _, err := fmt.Fprintln(f, journalHeader); err != nil {
+			_ = f.Close()
+			return nil, nil, fmt.Errorf("runner: failed to write journal: %w", err)
+		}
+	}
+
+	return &journal{f: f}, done, nil
+}
+
+// record adds path to the journal. Each path is written with a single write
+// so that it is either fully recorded or not at all if the process dies.
+func (j *journal) record(path string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	_, err := j.f.WriteString(path + "\n")
+	return err
+}
+
+// finish closes the journal, removing it if the run completed.
+func (j *journal) finish(completed bool) error {
+	if err := j.f.Close(); err != nil {
+		return err
+	}
+	if completed {
+		return os.Remove(j.f.Name())
+	}
+	return nil
+}
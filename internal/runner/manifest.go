@@ -0,0 +1,69 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/wasilibs/go-prettier/internal/wasm"
+)
+
+// manifest records the hash of the formatted contents of each file of a run,
+// to verify that formatting is the same in other environments.
+type manifest struct {
+	mu     sync.Mutex
+	hashes map[string]string
+}
+
+func newManifest(args RunArgs) *manifest {
+	if args.Manifest == nil {
+		return nil
+	}
+	return &manifest{hashes: map[string]string{}}
+}
+
+// record adds the formatted contents of the file at path.
+func (m *manifest) record(path string, formatted []byte) {
+	if m == nil {
+		return
+	}
+	sum := sha256.Sum256(formatted)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Slash-separated so that manifests are the same on all platforms.
+	m.hashes[filepath.ToSlash(path)] = hex.EncodeToString(sum[:])
+}
+
+type manifestFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// write writes the manifest as JSON to w, with files sorted by path.
+func (m *manifest) write(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	files := make([]manifestFile, 0, len(m.hashes))
+	for p, h := range m.hashes {
+		files = append(files, manifestFile{Path: p, SHA256: h})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		PrettierVersion string         `json:"prettierVersion"`
+		Files           []manifestFile `json:"files"`
+	}{
+		PrettierVersion: wasm.PrettierVersion,
+		Files:           files,
+	})
+}
@@ -0,0 +1,168 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// jsConfigFileNames are the names of config files that can only be loaded by
+// prettier running on Node.
+var jsConfigFileNames = []string{
+	".prettierrc.js", ".prettierrc.cjs", ".prettierrc.mjs",
+	"prettier.config.js", "prettier.config.cjs", "prettier.config.mjs",
+}
+
+// nodePrettier formats files by executing prettier installed with npm.
+type nodePrettier struct {
+	path   string
+	dir    string
+	config string
+	// all is set when every file should be formatted with Node, because
+	// the config can't be applied by the embedded prettier.
+	all bool
+}
+
+// newNodePrettier returns the prettier installed in the closest node_modules,
+// or ErrNodeNotFound if there is none.
+func newNodePrettier(ctx context.Context, args RunArgs, fsys fileSystem, pCfg map[string]any) (*nodePrettier, error) {
+	if _, ok := fsys.(osFS); !ok {
+		return nil, errors.New("runner: delegating to prettier on Node is not supported when formatting an FS")
+	}
+
+	path, err := findNodePrettier(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &nodePrettier{path: path, dir: args.Dir}
+	if args.Config != "" && !isRemoteConfig(args.Config) {
+		n.config = args.Config
+	}
+
+	if _, ok := pCfg["plugins"]; ok {
+		logger(ctx).DebugContext(ctx, "Config uses plugins, delegating all files to prettier on Node.")
+		n.all = true
+	}
+	if p := resolveConfigPath(args, fsys); isJSConfigFile(p) {
+		logger(ctx).DebugContext(ctx, fmt.Sprintf(`Found JavaScript config file "%s", delegating all files to prettier on Node.`, p))
+		n.all = true
+	}
+
+	return n, nil
+}
+
+// format formats src with prettier on Node, passing pCfg, the options
+// resolved for filePath, as flags so they take precedence over the config
+// prettier on Node finds itself.
+// FindNodePrettier returns the path of the prettier executable installed in
+// the closest node_modules of args.Dir, or ErrNodeNotFound if there is none.
+func FindNodePrettier(args RunArgs) (string, error) {
+	return findNodePrettier(newFileSystem(args))
+}
+
+func findNodePrettier(fsys fileSystem) (string, error) {
+	bin := "prettier"
+	if runtime.GOOS == "windows" {
+		bin = "prettier.cmd"
+	}
+	found := fsys.findUp(filepath.Join("node_modules", ".bin", bin))
+	if len(found) == 0 {
+		return "", ErrNodeNotFound
+	}
+	return found[0], nil
+}
+
+func (n *nodePrettier) format(ctx context.Context, filePath string, src []byte, pCfg map[string]any) ([]byte, error) {
+	args := []string{"--stdin-filepath", filePath}
+	if n.config != "" {
+		args = append(args, "--config", n.config)
+	}
+	optArgs, err := NodeOptionFlags(pCfg)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, optArgs...)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, n.path, args...)
+	cmd.Dir = n.dir
+	cmd.Stdin = bytes.NewReader(src)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		switch {
+		case strings.Contains(msg, "No parser could be inferred"):
+			return nil, ErrUnknownParser
+		case msg != "":
+			return nil, &EngineError{ExitCode: cmd.ProcessState.ExitCode(), Stderr: msg, err: err}
+		default:
+			return nil, fmt.Errorf("runner: failed to run prettier on Node: %w", err)
+		}
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// NodeOptionFlags returns the flags of the prettier CLI setting the options
+// pCfg, or an error if an option can't be expressed as a flag.
+func NodeOptionFlags(pCfg map[string]any) ([]string, error) {
+	keys := make([]string, 0, len(pCfg))
+	for k := range pCfg {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	var args []string
+	for _, k := range keys {
+		flag := "--" + kebabCase(k)
+		switch v := pCfg[k].(type) {
+		case nil:
+		case bool:
+			if !v {
+				flag = "--no-" + kebabCase(k)
+			}
+			args = append(args, flag)
+		case string:
+			args = append(args, flag+"="+v)
+		case int, int64, uint64, float64:
+			args = append(args, fmt.Sprintf("%s=%v", flag, v))
+		case []any:
+			if k != "plugins" {
+				return nil, fmt.Errorf("runner: option %q can't be passed to prettier on Node", k)
+			}
+			for _, p := range v {
+				p, ok := p.(string)
+				if !ok {
+					return nil, fmt.Errorf("runner: plugin %v can't be passed to prettier on Node", p)
+				}
+				args = append(args, "--plugin="+p)
+			}
+		default:
+			return nil, fmt.Errorf("runner: option %q can't be passed to prettier on Node", k)
+		}
+	}
+	return args, nil
+}
+
+// kebabCase returns the name of the flag of the prettier CLI for option.
+func kebabCase(option string) string {
+	var sb strings.Builder
+	for _, c := range option {
+		if unicode.IsUpper(c) {
+			sb.WriteByte('-')
+			c = unicode.ToLower(c)
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
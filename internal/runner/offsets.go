@@ -0,0 +1,107 @@
+package runner
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/wasilibs/go-prettier/internal/diff"
+)
+
+// utf16Offset converts the byte offset off in s to an offset in UTF-16 code
+// units, as used by JavaScript strings.
+func utf16Offset(s []byte, off int) int {
+	res := 0
+	for i := 0; i < off; {
+		r, n := utf8.DecodeRune(s[i:])
+		i += n
+		res += utf16Len(r)
+	}
+	return res
+}
+
+// utf16Len returns the number of UTF-16 code units encoding r.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// mapCursor returns the byte offset in out corresponding to the byte offset
+// cursor in src, which formats to out. The cursor keeps its position in
+// unchanged lines. In changed lines, it keeps its position relative to the
+// non-whitespace characters of the change, since formatting mostly changes
+// whitespace.
+func mapCursor(src []byte, out []byte, cursor int) int {
+	srcOff, outOff := 0, 0
+	lines := diff.Lines(src, out)
+	for i := 0; i < len(lines); {
+		if l := lines[i]; l.Kind == diff.Equal {
+			if cursor < srcOff+len(l.Text) {
+				return outOff + cursor - srcOff
+			}
+			srcOff += len(l.Text)
+			outOff += len(l.Text)
+			i++
+			continue
+		}
+
+		// The lines changed up to the next unchanged line.
+		var from, to []byte
+		for ; i < len(lines) && lines[i].Kind != diff.Equal; i++ {
+			if lines[i].Kind == diff.Delete {
+				from = append(from, lines[i].Text...)
+			} else {
+				to = append(to, lines[i].Text...)
+			}
+		}
+		if cursor < srcOff+len(from) {
+			return outOff + mapChangeCursor(from, to, cursor-srcOff)
+		}
+		srcOff += len(from)
+		outOff += len(to)
+	}
+	return outOff
+}
+
+// mapChangeCursor maps cursor in from to to like mapCursor, keeping it before
+// the same non-whitespace character, or after the one preceding it if there
+// is whitespace at the cursor.
+func mapChangeCursor(from []byte, to []byte, cursor int) int {
+	n := 0
+	for i := 0; i < cursor; {
+		r, size := utf8.DecodeRune(from[i:])
+		i += size
+		if !unicode.IsSpace(r) {
+			n++
+		}
+	}
+	if r, _ := utf8.DecodeRune(from[cursor:]); !unicode.IsSpace(r) {
+		if start, _, ok := nonSpace(to, n); ok {
+			return start
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	_, end, _ := nonSpace(to, n-1)
+	return end
+}
+
+// nonSpace returns the byte range of the non-whitespace character at index n
+// in b, or the end of the last one and false if there are fewer.
+func nonSpace(b []byte, n int) (int, int, bool) {
+	last := 0
+	for i := 0; i < len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+		if !unicode.IsSpace(r) {
+			if n == 0 {
+				return i, i + size, true
+			}
+			n--
+			last = i + size
+		}
+		i += size
+	}
+	return last, last, false
+}
@@ -0,0 +1,126 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Options are prettier options, see https://prettier.io/docs/en/options.
+// Zero values are unset, leaving the option at its default. Pointers are used
+// for booleans to distinguish false from unset.
+type Options struct {
+	// PrintWidth is the line length that the printer will wrap on.
+	PrintWidth int `json:"printWidth,omitempty"`
+	// TabWidth is the number of spaces per indentation level.
+	TabWidth int `json:"tabWidth,omitempty"`
+	// UseTabs indents lines with tabs instead of spaces.
+	UseTabs *bool `json:"useTabs,omitempty"`
+	// Semi prints semicolons at the ends of statements.
+	Semi *bool `json:"semi,omitempty"`
+	// SingleQuote uses single quotes instead of double quotes.
+	SingleQuote *bool `json:"singleQuote,omitempty"`
+	// QuoteProps is when to quote object properties: as-needed, consistent
+	// or preserve.
+	QuoteProps string `json:"quoteProps,omitempty"`
+	// JSXSingleQuote uses single quotes instead of double quotes in JSX.
+	JSXSingleQuote *bool `json:"jsxSingleQuote,omitempty"`
+	// TrailingComma is where to print trailing commas: all, es5 or none.
+	TrailingComma string `json:"trailingComma,omitempty"`
+	// BracketSpacing prints spaces between brackets in object literals.
+	BracketSpacing *bool `json:"bracketSpacing,omitempty"`
+	// BracketSameLine puts the > of a multi-line element at the end of the
+	// last line instead of on its own line.
+	BracketSameLine *bool `json:"bracketSameLine,omitempty"`
+	// ArrowParens is whether to include parentheses around a sole arrow
+	// function parameter: always or avoid.
+	ArrowParens string `json:"arrowParens,omitempty"`
+	// Parser is the parser to use instead of inferring it from the file path.
+	Parser string `json:"parser,omitempty"`
+	// ProseWrap is how to wrap prose in markdown: always, never or preserve.
+	ProseWrap string `json:"proseWrap,omitempty"`
+	// HTMLWhitespaceSensitivity is the whitespace sensitivity of HTML: css,
+	// strict or ignore.
+	HTMLWhitespaceSensitivity string `json:"htmlWhitespaceSensitivity,omitempty"`
+	// VueIndentScriptAndStyle indents code inside script and style tags in
+	// Vue files.
+	VueIndentScriptAndStyle *bool `json:"vueIndentScriptAndStyle,omitempty"`
+	// EndOfLine is the line ending to use: lf, crlf, cr or auto.
+	EndOfLine string `json:"endOfLine,omitempty"`
+	// EmbeddedLanguageFormatting is whether to format quoted code embedded in
+	// the file: auto or off.
+	EmbeddedLanguageFormatting string `json:"embeddedLanguageFormatting,omitempty"`
+	// SingleAttributePerLine puts each attribute in HTML, Vue and JSX on its
+	// own line.
+	SingleAttributePerLine *bool `json:"singleAttributePerLine,omitempty"`
+	// ExperimentalTernaries formats nested ternaries with the experimental
+	// style.
+	ExperimentalTernaries *bool `json:"experimentalTernaries,omitempty"`
+
+	// Extra are options without a field, keyed by their names in config
+	// files. Fields take precedence over them.
+	Extra map[string]any `json:"-"`
+}
+
+// Parsers are the parsers available to Options.Parser, from the plugins
+// included in the prettier module.
+var Parsers = []string{
+	"acorn", "angular", "babel", "babel-flow", "babel-ts", "css", "glimmer", "graphql", "html",
+	"json", "json-stringify", "json5", "jsonc", "less", "lwc", "markdown", "mdx", "meriyah",
+	"scss", "typescript", "vue", "yaml",
+}
+
+// Validate returns an error if an option is set to an invalid value.
+func (o Options) Validate() error {
+	if o.PrintWidth < 0 {
+		return fmt.Errorf("runner: invalid printWidth %d, expected a positive number", o.PrintWidth)
+	}
+	if o.TabWidth < 0 {
+		return fmt.Errorf("runner: invalid tabWidth %d, expected a positive number", o.TabWidth)
+	}
+
+	choices := []struct {
+		name    string
+		value   string
+		allowed []string
+	}{
+		{"quoteProps", o.QuoteProps, []string{"as-needed", "consistent", "preserve"}},
+		{"trailingComma", o.TrailingComma, []string{"all", "es5", "none"}},
+		{"arrowParens", o.ArrowParens, []string{"always", "avoid"}},
+		{"parser", o.Parser, Parsers},
+		{"proseWrap", o.ProseWrap, []string{"always", "never", "preserve"}},
+		{"htmlWhitespaceSensitivity", o.HTMLWhitespaceSensitivity, []string{"css", "strict", "ignore"}},
+		{"endOfLine", o.EndOfLine, []string{"lf", "crlf", "cr", "auto"}},
+		{"embeddedLanguageFormatting", o.EmbeddedLanguageFormatting, []string{"auto", "off"}},
+	}
+	for _, c := range choices {
+		if c.value != "" && !slices.Contains(c.allowed, c.value) {
+			return fmt.Errorf("runner: invalid %s %q, expected one of %s", c.name, c.value, strings.Join(c.allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// Map returns the options keyed by their names in config files, as accepted
+// by Runner.Format and RunArgs.Options.
+func (o Options) Map() map[string]any {
+	b, err := json.Marshal(o)
+	if err != nil {
+		// Programming bug
+		panic(err)
+	}
+	fields := map[string]any{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		// Programming bug
+		panic(err)
+	}
+
+	res := maps.Clone(o.Extra)
+	if res == nil {
+		res = map[string]any{}
+	}
+	maps.Copy(res, fields)
+	return res
+}
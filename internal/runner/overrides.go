@@ -0,0 +1,163 @@
+package runner
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/wasilibs/go-prettier/internal/gitignore"
+)
+
+// OptionsOverride sets prettier options for files matching a pattern.
+type OptionsOverride struct {
+	// Pattern is a gitignore-style pattern matched against paths relative to
+	// the working directory, such as "docs/" or "*.special".
+	Pattern string
+	// Options are prettier options for matching files, taking precedence
+	// over the config file and RunArgs.Options.
+	Options map[string]any
+}
+
+// configOverride is an entry of the overrides section of a config file,
+// setting options for files matching globs relative to the config file.
+type configOverride struct {
+	files        []string
+	excludeFiles []string
+	options      map[string]any
+}
+
+// splitConfigOverrides removes the overrides section from pCfg, returning its
+// entries. Options of files with nonstandard extensions, such as the parser
+// to use, are commonly set in it.
+func splitConfigOverrides(pCfg map[string]any) (map[string]any, []configOverride, error) {
+	section, ok := pCfg["overrides"]
+	if !ok {
+		return pCfg, nil, nil
+	}
+	pCfg = maps.Clone(pCfg)
+	delete(pCfg, "overrides")
+
+	entries, ok := section.([]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: overrides must be a list", ErrConfigInvalid)
+	}
+	res := make([]configOverride, 0, len(entries))
+	for i, e := range entries {
+		entry, ok := e.(map[string]any)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: override %d must be a map", ErrConfigInvalid, i)
+		}
+		var o configOverride
+		var err error
+		if o.files, err = globList(entry["files"]); err != nil || len(o.files) == 0 {
+			return nil, nil, fmt.Errorf("%w: files of override %d must be a glob or list of globs", ErrConfigInvalid, i)
+		}
+		if o.excludeFiles, err = globList(entry["excludeFiles"]); err != nil {
+			return nil, nil, fmt.Errorf("%w: excludeFiles of override %d must be a glob or list of globs", ErrConfigInvalid, i)
+		}
+		if opts, ok := entry["options"]; ok {
+			if o.options, ok = opts.(map[string]any); !ok {
+				return nil, nil, fmt.Errorf("%w: options of override %d must be a map", ErrConfigInvalid, i)
+			}
+		}
+		res = append(res, o)
+	}
+	return pCfg, res, nil
+}
+
+// globList returns v, a glob or list of globs in a config file, as a list.
+func globList(v any) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []any:
+		res := make([]string, 0, len(v))
+		for _, g := range v {
+			s, ok := g.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid glob %v", g)
+			}
+			res = append(res, s)
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("invalid globs %v", v)
+	}
+}
+
+// optionsOverride is an override with its patterns relative to base.
+type optionsOverride struct {
+	base    string
+	matcher gitignore.Matcher
+	options map[string]any
+}
+
+func (o *optionsOverride) matches(fsys fileSystem, path string) bool {
+	rel, ok := relPath(o.base, fsys.abs(path))
+	if !ok {
+		return false
+	}
+	matched, _ := o.matcher.Ignored(rel, false)
+	return matched
+}
+
+// optionsOverrides resolves the options of the overrides section of the
+// config file and RunArgs.Overrides for files.
+type optionsOverrides struct {
+	fsys   fileSystem
+	config []optionsOverride
+	// options are RunArgs.Options, which take precedence over the config
+	// file including its overrides.
+	options map[string]any
+	args    []optionsOverride
+}
+
+func newOptionsOverrides(args RunArgs, fsys fileSystem, cfgRoot string, cfgOverrides []configOverride) *optionsOverrides {
+	o := &optionsOverrides{
+		fsys:    fsys,
+		options: args.Options,
+	}
+	for _, override := range cfgOverrides {
+		patterns := strings.Join(override.files, "\n")
+		for _, exclude := range override.excludeFiles {
+			patterns += "\n!" + exclude
+		}
+		c := optionsOverride{base: fsys.abs(cfgRoot), options: override.options}
+		c.matcher.Add("", []byte(patterns))
+		o.config = append(o.config, c)
+	}
+	for _, override := range args.Overrides {
+		a := optionsOverride{base: fsys.abs("."), options: override.Options}
+		a.matcher.Add("", []byte(override.Pattern))
+		o.args = append(o.args, a)
+	}
+	return o
+}
+
+// optionsFor returns the options of the overrides matching path, with later
+// overrides taking precedence, or nil if none match.
+func (o *optionsOverrides) optionsFor(path string) map[string]any {
+	var res map[string]any
+	for i := range o.config {
+		if o.config[i].matches(o.fsys, path) {
+			if res == nil {
+				res = map[string]any{}
+			}
+			maps.Copy(res, o.config[i].options)
+		}
+	}
+	if res != nil {
+		maps.Copy(res, o.options)
+	}
+	for i := range o.args {
+		if o.args[i].matches(o.fsys, path) {
+			if res == nil {
+				res = map[string]any{}
+			}
+			maps.Copy(res, o.args[i].options)
+		}
+	}
+	return res
+}
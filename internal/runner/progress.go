@@ -0,0 +1,18 @@
+package runner
+
+// Progress receives the progress of a run, for example to render a progress
+// bar. Slow methods slow down the run.
+type Progress interface {
+	// Discovered is called once the patterns of the run are expanded, with
+	// the number of files that will be processed.
+	Discovered(total int)
+	// Started is called when formatting of the file at path starts.
+	Started(path string)
+	// Completed is called when the file at path was processed, with its
+	// outcome.
+	Completed(path string, status FileStatus)
+	// Failed is called instead of Completed when the file at path failed the
+	// check or could not be processed. Files that could not be processed
+	// because the run was stopped are neither completed nor failed.
+	Failed(path string, err error)
+}
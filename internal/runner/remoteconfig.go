@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const remoteConfigTimeout = 30 * time.Second
+
+// maxRemoteConfigSize is the maximum size of a remote config file, far larger
+// than any real config, so a misbehaving server can't exhaust memory.
+const maxRemoteConfigSize = 1 << 20
+
+// remoteConfigClient fetches remote config files, replaced in tests.
+var remoteConfigClient = http.DefaultClient
+
+var errIntegrityMismatch = errors.New("runner: config does not match integrity")
+
+func isRemoteConfig(path string) bool {
+	return strings.HasPrefix(path, "https://")
+}
+
+// fetchRemoteConfig downloads the config file at url. When cacheDir is not
+// empty, the last downloaded copy is stored there, revalidated with its
+// ETag, and used if the server can't be reached.
+func fetchRemoteConfig(ctx context.Context, url string, cacheDir string) ([]byte, error) {
+	var cachePath string
+	var cached []byte
+	var etag string
+	if cacheDir != "" {
+		key := sha256.Sum256([]byte(url))
+		cachePath = filepath.Join(cacheDir, hex.EncodeToString(key[:]))
+		if b, err := os.ReadFile(cachePath); err == nil {
+			cached = b
+			if e, err := os.ReadFile(cachePath + ".etag"); err == nil {
+				etag = string(e)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, remoteConfigTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("runner: invalid config URL: %w", err)
+	}
+	if cached != nil && etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	res, err := remoteConfigClient.Do(req)
+	if err != nil {
+		if cached != nil {
+			logger(ctx).WarnContext(ctx, fmt.Sprintf(`Unable to fetch config file "%s", using cached copy`, url))
+			logger(ctx).WarnContext(ctx, err.Error())
+			return cached, nil
+		}
+		return nil, fmt.Errorf("runner: failed to fetch config: %w", err)
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotModified && cached != nil:
+		return cached, nil
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("runner: failed to fetch config: unexpected status %s", res.Status)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, maxRemoteConfigSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("runner: failed to fetch config: %w", err)
+	}
+	if len(b) > maxRemoteConfigSize {
+		return nil, fmt.Errorf("runner: failed to fetch config: larger than %d bytes", maxRemoteConfigSize)
+	}
+
+	if cachePath != "" {
+		// Caching is best effort, a failure only means fetching again next time.
+		if err := os.MkdirAll(cacheDir, 0o755); err == nil {
+			_ = os.WriteFile(cachePath, b, 0o644)
+			_ = os.WriteFile(cachePath+".etag", []byte(res.Header.Get("ETag")), 0o644)
+		}
+	}
+
+	return b, nil
+}
+
+// remoteConfigCacheDir returns the directory remote config files are cached
+// in, or an empty string if there is no user cache directory.
+func remoteConfigCacheDir() string {
+	uc, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(uc, "com.github.wasilibs", "go-prettier", "config")
+}
+
+// checkIntegrity verifies content against a Subresource Integrity string such
+// as "sha256-<base64 digest>".
+func checkIntegrity(content []byte, integrity string) error {
+	alg, want, ok := strings.Cut(integrity, "-")
+	if !ok {
+		return fmt.Errorf("runner: invalid integrity %q, expected <algorithm>-<base64 digest>", integrity)
+	}
+
+	var h hash.Hash
+	switch alg {
+	case "sha256":
+		h = sha256.New()
+	case "sha384":
+		h = sha512.New384()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("runner: unsupported integrity algorithm %q, expected sha256, sha384 or sha512", alg)
+	}
+
+	wantSum, err := base64.StdEncoding.DecodeString(want)
+	if err != nil {
+		return fmt.Errorf("runner: invalid integrity digest: %w", err)
+	}
+
+	h.Write(content)
+	if got := h.Sum(nil); !bytes.Equal(got, wantSum) {
+		return fmt.Errorf("%w: got %s-%s", errIntegrityMismatch, alg, base64.StdEncoding.EncodeToString(got))
+	}
+	return nil
+}
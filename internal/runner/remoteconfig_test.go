@@ -0,0 +1,107 @@
+package runner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// Tests replace remoteConfigClient, so they are not parallel.
+
+func TestRemoteConfigIntegrity(t *testing.T) {
+	config := `{"semi": false}`
+	srv := newRemoteConfigServer(t, config)
+
+	sum := sha256.Sum256([]byte(config))
+	integrity := "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+
+	ctx := context.WithValue(context.Background(), loggerKey{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	fsys := newFileSystem(RunArgs{FS: fstest.MapFS{}})
+
+	t.Run("match", func(t *testing.T) {
+		pCfg, err := loadConfigFile(ctx, fsys, srv.URL+"/.prettierrc.json", RunArgs{ConfigIntegrity: integrity})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if semi, ok := pCfg["semi"]; !ok || semi != false {
+			t.Errorf("got config %v, want semi false", pCfg)
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		other := sha256.Sum256([]byte(`{"semi": true}`))
+		_, err := loadConfigFile(ctx, fsys, srv.URL+"/.prettierrc.json", RunArgs{
+			ConfigIntegrity: "sha256-" + base64.StdEncoding.EncodeToString(other[:]),
+		})
+		if !errors.Is(err, errIntegrityMismatch) {
+			t.Errorf("got error %v, want errIntegrityMismatch", err)
+		}
+	})
+}
+
+func TestRemoteConfigTooLarge(t *testing.T) {
+	srv := newRemoteConfigServer(t, `{"semi": false, "x": "`+strings.Repeat("x", maxRemoteConfigSize)+`"}`)
+
+	_, err := fetchRemoteConfig(context.Background(), srv.URL+"/.prettierrc.json", "")
+	if err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("got error %v, want too large", err)
+	}
+}
+
+func TestRemoteConfigCache(t *testing.T) {
+	config := `{"semi": false}`
+	srv := newRemoteConfigServer(t, config)
+	cacheDir := t.TempDir()
+	url := srv.URL + "/.prettierrc.json"
+
+	for i := 0; i < 2; i++ {
+		b, err := fetchRemoteConfig(context.Background(), url, cacheDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != config {
+			t.Errorf("got %q, want %q", b, config)
+		}
+	}
+
+	// The cached copy is used when the server can't be reached.
+	srv.Close()
+	ctx := context.WithValue(context.Background(), loggerKey{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	b, err := fetchRemoteConfig(ctx, url, cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != config {
+		t.Errorf("got %q, want %q", b, config)
+	}
+}
+
+// newRemoteConfigServer serves config with an ETag, replacing
+// remoteConfigClient with a client of the server for the test.
+func newRemoteConfigServer(t *testing.T, config string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = io.WriteString(w, config)
+	}))
+	t.Cleanup(srv.Close)
+
+	prev := remoteConfigClient
+	remoteConfigClient = srv.Client()
+	t.Cleanup(func() { remoteConfigClient = prev })
+
+	return srv
+}
@@ -0,0 +1,335 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wasilibs/go-prettier/internal/diff"
+)
+
+// Report formats supported by RunArgs.ReportFormat.
+const (
+	ReportFormatJSON  = "json"
+	ReportFormatJUnit = "junit"
+	ReportFormatSARIF = "sarif"
+)
+
+// ReportFormatForPath returns the report format inferred from the extension of
+// path, or an empty string if it is not recognized.
+func ReportFormatForPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ReportFormatJSON
+	case ".xml":
+		return ReportFormatJUnit
+	case ".sarif":
+		return ReportFormatSARIF
+	default:
+		return ""
+	}
+}
+
+// FileStatus is the outcome of processing a single file.
+type FileStatus string
+
+const (
+	// StatusFormatted means the file was already formatted.
+	StatusFormatted FileStatus = "formatted"
+	// StatusChanged means formatting changed the file.
+	StatusChanged FileStatus = "changed"
+	// StatusUnformatted means the file failed a check.
+	StatusUnformatted FileStatus = "unformatted"
+	// StatusSkipped means no parser could be inferred for the file, it is
+	// larger than RunArgs.MaxFileSize, or its changes were declined by
+	// RunArgs.Review.
+	StatusSkipped FileStatus = "skipped"
+	// StatusError means the file could not be read, formatted or written.
+	StatusError FileStatus = "error"
+)
+
+// FileResult is the outcome of processing a file in a run.
+type FileResult struct {
+	// Path is the path of the file, as expanded from the patterns of the run.
+	Path string `json:"path"`
+	// Status is the outcome of processing the file.
+	Status FileStatus `json:"status"`
+	// Message describes the reason for StatusSkipped and StatusError, such as
+	// the syntax error reported by prettier.
+	Message string `json:"message,omitempty"`
+	// Line and Column are the 1-based location of the syntax error, for files
+	// that failed to parse.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+	// Changes are the ranges of lines formatting changes, for
+	// StatusUnformatted.
+	Changes []LineRange `json:"changes,omitempty"`
+	// Diff is the unified diff of the changes formatting would make, for
+	// StatusUnformatted when RunArgs.Diff is set.
+	Diff string `json:"-"`
+	// Duration is the time taken to process the file.
+	Duration time.Duration `json:"-"`
+	// Err is the error for StatusError.
+	Err error `json:"-"`
+}
+
+// MarshalJSON encodes Duration in milliseconds.
+func (r FileResult) MarshalJSON() ([]byte, error) {
+	// fileResult doesn't have the methods of FileResult, so it is marshaled
+	// with the default encoding.
+	type fileResult FileResult
+	return json.Marshal(struct {
+		fileResult
+		DurationMS float64 `json:"durationMs,omitempty"`
+	}{
+		fileResult: fileResult(r),
+		DurationMS: float64(r.Duration) / float64(time.Millisecond),
+	})
+}
+
+// LineRange is a range of lines of a file, with 1-based lines. Lines only
+// inserted by formatting are in the range of the line before them.
+type LineRange struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine"`
+}
+
+// changedLines returns the ranges of lines of in that differ from out.
+func changedLines(in []byte, out []byte) []LineRange {
+	numLines := bytes.Count(in, []byte("\n"))
+	if len(in) > 0 && in[len(in)-1] != '\n' {
+		numLines++
+	}
+	numLines = max(numLines, 1)
+	var res []LineRange
+	for _, h := range diff.Hunks(in, out, 0) {
+		start, end := h.FromLine, h.FromLine+h.FromCount-1
+		if h.FromCount == 0 {
+			// Lines are inserted before FromLine.
+			start = min(max(h.FromLine-1, 1), numLines)
+			end = start
+		}
+		if n := len(res); n > 0 && res[n-1].EndLine >= start-1 {
+			// Adjacent changes are reported as one range.
+			res[n-1].EndLine = max(res[n-1].EndLine, end)
+			continue
+		}
+		res = append(res, LineRange{StartLine: start, EndLine: end})
+	}
+	return res
+}
+
+// RunResult is the outcome of a run.
+type RunResult struct {
+	// Files are the results of the processed files, in the order of the
+	// expanded patterns. Files not processed because the run stopped early
+	// are not included.
+	Files []FileResult
+}
+
+func checkReportFormat(format string) error {
+	switch format {
+	case ReportFormatJSON, ReportFormatJUnit, ReportFormatSARIF:
+		return nil
+	default:
+		return fmt.Errorf("runner: unknown report format %q, expected json, junit or sarif", format)
+	}
+}
+
+func writeReport(w io.Writer, format string, results []FileResult) error {
+	switch format {
+	case ReportFormatJSON:
+		return writeJSONReport(w, results)
+	case ReportFormatJUnit:
+		return writeJUnitReport(w, results)
+	case ReportFormatSARIF:
+		return writeSARIFReport(w, results)
+	default:
+		return checkReportFormat(format)
+	}
+}
+
+func writeJSONReport(w io.Writer, results []FileResult) error {
+	summary := map[FileStatus]int{}
+	for _, r := range results {
+		summary[r.Status]++
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Files   []FileResult       `json:"files"`
+		Summary map[FileStatus]int `json:"summary"`
+	}{
+		Files:   results,
+		Summary: summary,
+	})
+}
+
+type junitTestSuite struct {
+	XMLName  xml.Name        `xml:"testsuite"`
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+func writeJUnitReport(w io.Writer, results []FileResult) error {
+	suite := junitTestSuite{Name: "prettier"}
+	for _, r := range results {
+		tc := junitTestCase{Name: r.Path, ClassName: "prettier"}
+		switch r.Status {
+		case StatusUnformatted:
+			suite.Failures++
+			tc.Failure = &junitMessage{Message: "File is not formatted with Prettier"}
+		case StatusError:
+			suite.Errors++
+			tc.Error = &junitMessage{Message: r.Message}
+		case StatusSkipped:
+			suite.Skipped++
+			tc.Skipped = &junitMessage{Message: r.Message}
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+func writeSARIFReport(w io.Writer, results []FileResult) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "prettier",
+			InformationURI: "https://github.com/wasilibs/go-prettier",
+			Rules: []sarifRule{
+				{ID: string(StatusUnformatted), ShortDescription: sarifMessage{Text: "File is not formatted with Prettier"}},
+				{ID: string(StatusError), ShortDescription: sarifMessage{Text: "File could not be formatted"}},
+			},
+		}},
+		Results: []sarifResult{},
+	}
+
+	for _, r := range results {
+		result := func(msg string, region *sarifRegion) sarifResult {
+			return sarifResult{
+				RuleID:  string(r.Status),
+				Level:   "error",
+				Message: sarifMessage{Text: msg},
+				Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(r.Path)},
+					Region:           region,
+				}}},
+			}
+		}
+		switch r.Status {
+		case StatusUnformatted:
+			if len(r.Changes) == 0 {
+				run.Results = append(run.Results, result("File is not formatted with Prettier. Run Prettier to fix.", nil))
+				continue
+			}
+			// A result for each change, so they are annotated on the lines
+			// that need formatting.
+			for _, c := range r.Changes {
+				run.Results = append(run.Results, result("Code is not formatted with Prettier. Run Prettier to fix.", &sarifRegion{StartLine: c.StartLine, EndLine: c.EndLine}))
+			}
+		case StatusError:
+			var region *sarifRegion
+			if r.Line > 0 {
+				region = &sarifRegion{StartLine: r.Line, StartColumn: r.Column}
+			}
+			run.Results = append(run.Results, result(r.Message, region))
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	})
+}
@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wasilibs/go-prettier/internal/wasm"
+)
+
+// captureRepro writes the files needed to reproduce a failure to format the
+// file at path into a directory in dir at the path of the file with a .repro
+// suffix. The suffix keeps the directories of different files apart, since
+// the files written into them don't have it.
+func captureRepro(dir string, path string, in []byte, pCfg map[string]any, formatErr error) error {
+	reproDir := filepath.Join(dir, reproPath(path)+".repro")
+	if err := os.MkdirAll(reproDir, 0o755); err != nil {
+		return err
+	}
+
+	opts := maps.Clone(pCfg)
+	opts["filepath"] = path
+	optsJSON, err := json.MarshalIndent(opts, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	stderr := formatErr.Error()
+	var ee *EngineError
+	if errors.As(formatErr, &ee) {
+		stderr = ee.Stderr
+	}
+
+	files := map[string][]byte{
+		"input" + filepath.Ext(path): in,
+		"options.json":               append(optsJSON, '\n'),
+		"version.txt":                []byte(fmt.Sprintf("prettier %s\n", wasm.PrettierVersion)),
+		"stderr.txt":                 []byte(stderr + "\n"),
+	}
+	for f, content := range files {
+		if err := os.WriteFile(filepath.Join(reproDir, f), content, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reproPath returns path as a relative path to mirror within the directory of
+// captured reproductions, keeping paths outside the working directory, such
+// as absolute paths, within it too.
+func reproPath(path string) string {
+	path = strings.TrimPrefix(filepath.Clean(path), filepath.VolumeName(path))
+	var parts []string
+	for _, p := range strings.Split(filepath.ToSlash(path), "/") {
+		switch p {
+		case "", ".":
+		case "..":
+			parts = append(parts, "__")
+		default:
+			parts = append(parts, p)
+		}
+	}
+	return filepath.Join(parts...)
+}
@@ -0,0 +1,25 @@
+package runner
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+)
+
+// residentSetSize returns the resident set size of the process, or 0 if it
+// can't be read.
+func residentSetSize() uint64 {
+	b, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0
+	}
+	fields := bytes.Fields(b)
+	if len(fields) < 2 {
+		return 0
+	}
+	pages, err := strconv.ParseUint(string(fields[1]), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return pages * uint64(os.Getpagesize())
+}
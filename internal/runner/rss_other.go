@@ -0,0 +1,9 @@
+//go:build !linux
+
+package runner
+
+// residentSetSize returns 0 where the resident set size is not readable, in
+// which case only the memory obtained by the Go runtime is considered.
+func residentSetSize() uint64 {
+	return 0
+}
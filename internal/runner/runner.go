@@ -4,14 +4,23 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
 	"log/slog"
 	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/tetratelabs/wazero"
@@ -20,216 +29,1551 @@ import (
 	"golang.org/x/sync/errgroup"
 	"gopkg.in/yaml.v3"
 
-	"github.com/wasilibs/go-prettier/internal/wasm"
+	"github.com/wasilibs/go-prettier/internal/diff"
 )
 
+// ErrUnknownParser is returned by Runner.Format when no parser could be
+// inferred for the file.
+var ErrUnknownParser = errors.New("runner: no parser could be inferred")
+
+// EngineError is returned by Runner.Format when prettier fails to format a
+// file, for example due to a syntax error.
+type EngineError struct {
+	// ExitCode is the exit code of prettier.
+	ExitCode int
+	// Stderr is the message prettier printed to stderr.
+	Stderr string
+
+	err error
+}
+
+func (e *EngineError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("runner: failed to run prettier: exit code %d", e.ExitCode)
+	}
+	return "runner: failed to run prettier: " + e.Stderr
+}
+
+func (e *EngineError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether the error is ErrParse, for syntax errors in the file.
+func (e *EngineError) Is(target error) bool {
+	if target != ErrParse {
+		return false
+	}
+	first, _, _ := strings.Cut(e.Stderr, "\n")
+	return parseErrorLocation.MatchString(first)
+}
+
+// parseErrorLocation matches the location prettier appends to the messages of
+// syntax errors, such as "Unexpected token (1:10)", capturing the line and
+// column.
+var parseErrorLocation = regexp.MustCompile(`\((\d+):(\d+)\)$`)
+
+// location returns the line and column of a syntax error, or zeros if the
+// error is not a syntax error.
+func (e *EngineError) location() (line int, column int) {
+	first, _, _ := strings.Cut(e.Stderr, "\n")
+	m := parseErrorLocation.FindStringSubmatch(first)
+	if m == nil {
+		return 0, 0
+	}
+	line, _ = strconv.Atoi(m[1])
+	column, _ = strconv.Atoi(m[2])
+	return line, column
+}
+
+// exitCodeUnknownParser is the exit code of the prettier module when no
+// parser could be inferred for the file.
+const exitCodeUnknownParser = 10
+
+// ConfigFileNames are the names of config files that are searched for in each
+// directory, in order of precedence, matching prettier. Package manifests are
+// only used if they have a "prettier" key, and JavaScript config files can
+// only be used when delegating to prettier on Node.
+var ConfigFileNames = []string{
+	"package.json", "package.yaml",
+	".prettierrc", ".prettierrc.json", ".prettierrc.yaml", ".prettierrc.yml", ".prettierrc.json5",
+	".prettierrc.js", ".prettierrc.mjs", ".prettierrc.cjs",
+	"prettier.config.js", "prettier.config.mjs", "prettier.config.cjs",
+	".prettierrc.toml",
+}
+
 var (
-	errCheckFailed       = errors.New("check failed")
-	errInvalidConfigFile = errors.New("invalid config file")
+	// ErrCheckFailed is returned by Runner.Run when a file is not formatted
+	// with RunArgs.Check.
+	ErrCheckFailed = errors.New("runner: check failed")
+	// ErrConfigInvalid is returned when a config file can't be parsed or
+	// can't be used, such as a JavaScript config file.
+	ErrConfigInvalid = errors.New("runner: invalid config file")
+	// ErrUnreadable is returned when a file or config file can't be read.
+	ErrUnreadable = errors.New("runner: unable to read file")
+	// ErrParse is matched by an EngineError when prettier fails to parse a
+	// file due to a syntax error.
+	ErrParse = errors.New("runner: failed to parse file")
+	// ErrNodeNotFound is returned by Runner.Run with RunArgs.DelegateToNode
+	// when there is no prettier installed in node_modules.
+	ErrNodeNotFound = errors.New("runner: no prettier found in node_modules to delegate to")
 )
 
-func NewRunner() *Runner {
-	ctx := context.Background()
+// errFileTooLarge is returned by format for files skipped because of
+// MaxFileSize, which don't fail the run.
+var errFileTooLarge = errors.New("file is too large")
 
-	rtCfg := wazero.NewRuntimeConfig()
-	uc, err := os.UserCacheDir()
-	if err == nil {
-		cache, err := wazero.NewCompilationCacheWithDir(filepath.Join(uc, "com.github.wasilibs"))
-		if err == nil {
-			rtCfg = rtCfg.WithCompilationCache(cache)
-		}
+// errReviewDeclined is returned by format for files whose changes were
+// declined by Review, which don't fail the run.
+var errReviewDeclined = errors.New("changes were declined")
+
+func NewRunner(opts ...Option) *Runner {
+	return newCachedRunner(newRunnerOptions(opts))
+}
+
+// NewDeterministicRunner returns a Runner that produces identical output for
+// identical inputs on any machine. Prettier sees a fixed clock and random
+// source, and the compiled module is not cached on the filesystem.
+func NewDeterministicRunner(opts ...Option) *Runner {
+	return newRunner(wazero.NewRuntimeConfig(), false, true, newRunnerOptions(opts))
+}
+
+// newCachedRunner returns a Runner caching the compiled module.
+func newCachedRunner(o runnerOptions) *Runner {
+	rtCfg, shared := newRuntimeConfig(o.cacheDir)
+	return newRunner(rtCfg, shared, false, o)
+}
+
+func newRunner(rtCfg wazero.RuntimeConfig, sharedCache bool, deterministic bool, o runnerOptions) *Runner {
+	r := &Runner{
+		rtCfg:         rtCfg,
+		wasm:          o.wasm,
+		wasmDigest:    sha256.Sum256(o.wasm),
+		sharedCache:   sharedCache,
+		deterministic: deterministic,
+		defaultConfig: o.defaultConfig,
+		concurrency:   o.concurrency,
+		logger:        o.logger,
+		err:           o.err,
 	}
+	if r.err != nil {
+		return r
+	}
+	r.rt, r.compiled, r.err = r.compile(rtCfg, false)
+	return r
+}
+
+// sharedCompiled counts the runners using each module compiled with the
+// compilation cache shared by runners. Closing a compiled module evicts it
+// from the cache, so it is only closed by the last runner using it.
+var sharedCompiled = struct {
+	mu   sync.Mutex
+	refs map[sharedCompiledKey]int
+}{refs: map[sharedCompiledKey]int{}}
+
+type sharedCompiledKey struct {
+	wasmDigest  [sha256.Size]byte
+	cancellable bool
+}
+
+// compile compiles the module of r with rtCfg, which closes the module when
+// the context of its execution is done if cancellable is set.
+func (r *Runner) compile(rtCfg wazero.RuntimeConfig, cancellable bool) (wazero.Runtime, wazero.CompiledModule, error) {
+	if !r.sharedCache {
+		return compileModule(rtCfg, r.wasm)
+	}
+
+	// Held while compiling so the module is not closed by another runner
+	// after it is found in the cache.
+	sharedCompiled.mu.Lock()
+	defer sharedCompiled.mu.Unlock()
+	rt, compiled, err := compileModule(rtCfg, r.wasm)
+	if err != nil {
+		return nil, nil, err
+	}
+	sharedCompiled.refs[sharedCompiledKey{wasmDigest: r.wasmDigest, cancellable: cancellable}]++
+	return rt, compiled, nil
+}
+
+// closeCompiled closes compiled, the module of r returned by compile, unless
+// other runners still use it.
+func (r *Runner) closeCompiled(ctx context.Context, compiled wazero.CompiledModule, cancellable bool) error {
+	if !r.sharedCache {
+		return compiled.Close(ctx)
+	}
+
+	sharedCompiled.mu.Lock()
+	defer sharedCompiled.mu.Unlock()
+	key := sharedCompiledKey{wasmDigest: r.wasmDigest, cancellable: cancellable}
+	sharedCompiled.refs[key]--
+	if sharedCompiled.refs[key] > 0 {
+		return nil
+	}
+	delete(sharedCompiled.refs, key)
+	return compiled.Close(ctx)
+}
+
+func compileModule(rtCfg wazero.RuntimeConfig, bin []byte) (wazero.Runtime, wazero.CompiledModule, error) {
+	ctx := context.Background()
+
 	rt := wazero.NewRuntimeWithConfig(ctx, rtCfg)
 
 	wasi_snapshot_preview1.MustInstantiate(ctx, rt)
 
-	compiled, err := rt.CompileModule(ctx, wasm.Prettier)
+	compiled, err := rt.CompileModule(ctx, bin)
 	if err != nil {
-		// Programming bug
-		panic(err)
+		_ = rt.Close(ctx)
+		return nil, nil, fmt.Errorf("runner: failed to compile prettier: %w", err)
 	}
 
-	return &Runner{
-		compiled: compiled,
-		rt:       rt,
-	}
+	return rt, compiled, nil
 }
 
+// Runner formats files with prettier. It is safe for concurrent use.
+//
+// Formatting is interrupted when the context passed to a method is done.
+// Checking for cancellation slows down prettier significantly, so it is only
+// done for contexts that can be cancelled, unlike context.Background.
 type Runner struct {
 	compiled wazero.CompiledModule
 	rt       wazero.Runtime
+	rtCfg    wazero.RuntimeConfig
+	wasm     []byte
+	// wasmDigest is the SHA-256 digest of wasm.
+	wasmDigest [sha256.Size]byte
+	// sharedCache is set when modules are compiled with the compilation
+	// cache shared by runners.
+	sharedCache bool
+	closeOnce   sync.Once
+
+	// Compiled on first use with a context that can be cancelled.
+	cancellableOnce     sync.Once
+	cancellableCompiled wazero.CompiledModule
+	cancellableRT       wazero.Runtime
+
+	deterministic bool
+	defaultConfig map[string]any
+	concurrency   int
+	logger        *slog.Logger
+	// err is the error creating the Runner, such as when the module passed
+	// to WithWasm can't be compiled or the config passed to
+	// WithDefaultConfig can't be parsed, returned by its methods that need
+	// it.
+	err error
 }
 
+// RunArgs are the arguments for a single run, mirroring the flags of the
+// prettier CLI.
 type RunArgs struct {
-	Patterns                  []string
-	Config                    string
-	NoConfig                  bool
-	Check                     bool
-	IgnorePaths               []string
-	Write                     bool
-	WithNodeModules           bool
+	// Patterns are the files, directories and globs to format.
+	Patterns []string
+	// Dir, if set, is the directory patterns, config files and ignore files
+	// are resolved against instead of the working directory, and that paths
+	// of formatted files are relative to. Paths of other outputs, such as
+	// Journal, are still relative to the working directory.
+	Dir string
+	// Config is the path to the config file to use instead of searching for one.
+	// An https:// URL fetches the config file, caching it for use when the
+	// server can't be reached.
+	Config string
+	// Options are prettier options that take precedence over the config file,
+	// such as embeddedLanguageFormatting.
+	Options map[string]any
+	// Overrides set prettier options for files matching patterns, on top of
+	// the config file and Options. Later overrides take precedence.
+	Overrides []OptionsOverride
+	// Presets are the names of presets in the presets section of the config
+	// file to apply, in order, on top of its top-level options.
+	Presets []string
+	// ConfigIntegrity is a Subresource Integrity string such as
+	// "sha256-<base64 digest>" that the config file must match.
+	ConfigIntegrity string
+	// ConfigExpandEnv replaces ${VAR} and ${VAR:-default} in the config file
+	// with the value of the environment variable VAR.
+	ConfigExpandEnv bool
+	// NoConfig disables searching for a config file.
+	NoConfig bool
+	// NoEditorConfig disables applying options from .editorconfig files.
+	NoEditorConfig bool
+	// Check reports whether files are formatted instead of printing them.
+	Check bool
+	// IgnorePaths are paths to files with gitignore-style patterns of files to
+	// ignore, relative to the directory of the config file or the working
+	// directory if there is none. Files given by name, such as .prettierignore,
+	// are also read from subdirectories and apply relative to them. If nil,
+	// .gitignore and .prettierignore are used. An empty slice disables ignore
+	// files.
+	IgnorePaths []string
+	// NoGitIgnore doesn't use .gitignore files when IgnorePaths is nil, so
+	// files ignored by git are still processed.
+	NoGitIgnore bool
+	// Write formats files in place.
+	Write bool
+	// OutDir, if set, is a directory formatted files are written to instead
+	// of in place, at their paths relative to the working directory. All
+	// processed files are written, including those already formatted. Like
+	// Journal, it is relative to the working directory even with Dir.
+	OutDir string
+	// Cache skips files that a previous run with Cache found to be formatted,
+	// if they and their options are unchanged. It is only used with Write,
+	// Check or DryRun, since other runs print all files.
+	Cache bool
+	// CacheLocation is the path to the cache file, defaulting to
+	// node_modules/.cache/prettier/.prettier-cache in the working directory.
+	CacheLocation string
+	// CacheStrategy is how changed files are detected, CacheStrategyContent
+	// by default or CacheStrategyMetadata.
+	CacheStrategy string
+	// DryRun prints the paths of files that formatting would change to Stdout
+	// instead of writing or printing their formatted contents, even with
+	// Write. The files have StatusChanged in the result of the run.
+	DryRun bool
+	// WithNodeModules formats files inside node_modules directories.
+	WithNodeModules bool
+	// NoErrorOnUnmatchedPattern prevents errors when a pattern matches no files.
 	NoErrorOnUnmatchedPattern bool
+	// UnknownParser is the severity of files no parser could be inferred for,
+	// one of UnknownParserIgnore, UnknownParserWarn or UnknownParserError.
+	// By default, files found by walking a directory are ignored and others
+	// warned about.
+	UnknownParser string
+	// UnknownParserOverrides set the severity of files no parser could be
+	// inferred for by pattern. Later overrides take precedence.
+	UnknownParserOverrides []UnknownParserOverride
+	// DelegateToNode formats files with prettier installed in node_modules
+	// when the embedded prettier can't handle them. All files are delegated
+	// if the config uses plugins or is a JavaScript file, and otherwise only
+	// files no parser could be inferred for. Delegated files are formatted
+	// with the same resolved options, passed as flags, and fail if an option
+	// has no flag. The run fails with ErrNodeNotFound if prettier is not
+	// installed.
+	DelegateToNode bool
+	// Journal, if set, is the path to a file that files are recorded in as
+	// they are processed. It is removed once all files have been processed
+	// successfully.
+	Journal string
+	// Resume skips files recorded in Journal by a previous, interrupted run.
+	Resume bool
+	// CaptureReproDir, if set, is a directory to write the input, options,
+	// prettier version and error of each file that fails to format to, for
+	// attaching to bug reports. Each file gets a directory at its path with
+	// a .repro suffix, such as a/b.js.repro for a/b.js.
+	CaptureReproDir string
+	// GitOnly restricts the run to files tracked by git in the repository
+	// of the working directory.
+	GitOnly bool
+	// Staged restricts the run to files staged in git, which are read from
+	// the index rather than the working tree, for use in pre-commit hooks.
+	// With Write, formatted files are written to the index, and to the
+	// working tree unless it has unstaged changes to them. WriteFile and
+	// Cache are not used.
+	Staged bool
+	// ChangedSince, if set, restricts the run to files changed in git since
+	// the merge base of the given ref and HEAD, including uncommitted and
+	// untracked files, such as "origin/main" for the files of a pull request.
+	ChangedSince string
+	// DirtyFirst processes files with uncommitted changes in git before other
+	// files, so that problems in recently changed files are reported first.
+	DirtyFirst bool
+	// MaxDepth, if positive, limits how deep directories in Patterns are
+	// walked. A MaxDepth of 1 only includes files directly in the directory.
+	MaxDepth int
+	// ShardIndex and ShardCount, if ShardCount is positive, restrict the run
+	// to the ShardIndex-th of ShardCount disjoint subsets of the files, with
+	// ShardIndex starting at 1. Files are assigned to shards by their path,
+	// so runs of all shards together process every file exactly once.
+	ShardIndex int
+	ShardCount int
+	// MaxFailures, if positive, stops the run once this many files have
+	// failed a check or could not be formatted.
+	MaxFailures int
+	// Timeout, if positive, limits the duration of the whole run. Formatting
+	// in progress is cancelled when it elapses and the files that were not
+	// processed are logged.
+	Timeout time.Duration
+	// FileTimeout, if positive, limits the duration of formatting each file.
+	// Files that take longer fail with an error, and the run continues with
+	// the other files.
+	FileTimeout time.Duration
+	// Concurrency, if positive, is the maximum number of files formatted
+	// concurrently by the run instead of the one set by WithConcurrency.
+	// Each file formatted concurrently uses a separate instance of prettier
+	// with its own memory.
+	Concurrency int
+	// MemoryLimit, if positive, is the number of bytes of memory the process
+	// should stay under. Fewer files are formatted concurrently while memory
+	// usage approaches it, trading speed for not running out of memory.
+	MemoryLimit uint64
+	// MaxFileSize, if positive, is the size in bytes above which files are
+	// skipped with a warning instead of formatted, such as for minified
+	// bundles, which would otherwise be loaded whole into prettier's memory.
+	MaxFileSize uint64
+	// RangeStart and RangeEnd, if either is positive, restrict formatting of
+	// each file to the statements overlapping the byte range between them.
+	// A RangeEnd of zero extends the range to the end of the file.
+	RangeStart int
+	RangeEnd   int
+
+	// FS, if set, is used instead of the OS filesystem to read files, config
+	// files and ignore files, with its root as the working directory.
+	// Patterns are slash-separated paths relative to the root. Byte maps can
+	// be used with testing/fstest.MapFS. Since the files of the run are not
+	// on the OS filesystem, it can't be used with Cache, OutDir or
+	// CaptureReproDir, which write files alongside them.
+	FS fs.FS
+	// WriteFile, if set, writes the formatted contents of the file at path
+	// with Write instead of the file being written in place, for example to
+	// collect results in memory or write them to other storage. path is as
+	// reported in the results of the run. It is called concurrently for
+	// different files. It is required to use Write with FS.
+	WriteFile func(path string, content []byte) error
+	// Report, if set, receives a machine-readable report of the processed
+	// files in ReportFormat once the run completes.
+	Report io.Writer
+	// ReportFormat is the format of Report, one of ReportFormatJSON,
+	// ReportFormatJUnit or ReportFormatSARIF.
+	ReportFormat string
+	// Manifest, if set, receives a JSON manifest of the SHA-256 hash of the
+	// formatted contents of each processed file once the run completes, for
+	// verifying that formatting is the same in other environments.
+	Manifest io.Writer
+
+	// Diff prints a unified diff of the changes formatting would make to each
+	// file that fails Check to Stdout, in addition to logging its path. It has
+	// no effect with NulSeparated.
+	Diff bool
+	// Color highlights output with ANSI escape codes, for terminals: the
+	// output of Diff, the paths of files that fail Check and the summary of
+	// the check.
+	Color bool
+
+	// NulSeparated prints the paths of files that fail Check to Stdout, each
+	// followed by a NUL byte, instead of logging them, for consumption by
+	// tools such as xargs -0. No other output is printed to Stdout.
+	NulSeparated bool
+
+	// Stdout receives formatted files and check summaries, defaulting to
+	// os.Stdout. Diagnostics are logged with the logger of the Runner.
+	Stdout io.Writer
+	// OnFileResult, if set, is called as each file completes, with the error
+	// for StatusSkipped and StatusError. Calls are not concurrent, so it does
+	// not need to be safe for concurrent use, but a slow callback slows down
+	// the run.
+	OnFileResult func(path string, status FileStatus, err error)
+	// Progress, if set, is notified of the progress of the run. Its methods
+	// are not called concurrently with each other or OnFileResult.
+	Progress Progress
+	// Review, if set, is called with the original and formatted contents of
+	// each file formatting changes before it is written with Write or
+	// OutDir, returning the contents to write instead, or nil to leave the
+	// file unchanged, for example to confirm changes interactively. Calls
+	// are not concurrent, in the order files finish formatting.
+	Review func(path string, in []byte, out []byte) []byte
+
+	// onResult is called like OnFileResult with the full result, for
+	// RunStream.
+	onResult func(res FileResult)
+	// fsys, if set, is the filesystem of the run instead of the one selected
+	// by FS, for Staged.
+	fsys fileSystem
 }
 
-func (r *Runner) Run(ctx context.Context, args RunArgs) error {
-	var cfgPath string
+// Run formats the files matching the patterns of args, logging the outcome
+// of each file. The result is returned whenever files were processed, even if
+// err is non-nil, such as when a check failed.
+func (r *Runner) Run(ctx context.Context, args RunArgs) (*RunResult, error) {
+	ctx = r.withLogger(ctx)
 
-	pCfg := map[string]any{}
+	if args.Staged {
+		return r.runStaged(ctx, args)
+	}
 
-	switch {
-	case args.Config != "":
-		cfg, err := loadConfigFile(ctx, args.Config)
+	pCfg, paths, err := r.Expand(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.runPaths(ctx, args, pCfg, paths)
+}
+
+// RunStream runs like Run in the background, sending the result of each file
+// to the returned channel as it completes, for example to display results of
+// long runs as they stream in. The channel is closed once the run completes,
+// after which wait returns the result of the run like Run. The channel must
+// be drained for the run to progress.
+func (r *Runner) RunStream(ctx context.Context, args RunArgs) (results <-chan FileResult, wait func() (*RunResult, error)) {
+	ch := make(chan FileResult)
+	args.onResult = func(res FileResult) {
+		ch <- res
+	}
+
+	var res *RunResult
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(ch)
+		res, err = r.Run(ctx, args)
+	}()
+
+	return ch, func() (*RunResult, error) {
+		<-done
+		return res, err
+	}
+}
+
+// RunFiles formats files like Run, but without expanding patterns or
+// applying ignore files, for callers that already know the files to format
+// such as build tools and git hooks. Patterns and the options selecting files
+// of args, such as GitOnly, are ignored.
+func (r *Runner) RunFiles(ctx context.Context, files []string, args RunArgs) (*RunResult, error) {
+	ctx = r.withLogger(ctx)
+
+	fsys := newFileSystem(args)
+
+	pCfg, cfgPath, cfgOverrides, err := r.loadConfig(ctx, args, fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]ExpandedPath, len(files))
+	for i, f := range files {
+		paths[i] = ExpandedPath{FilePath: f}
+	}
+	annotatePaths(args, fsys, paths, configRoot(cfgPath), cfgOverrides)
+
+	return r.runPaths(ctx, args, pCfg, paths)
+}
+
+// runPaths formats the paths expanded for args with the options pCfg.
+func (r *Runner) runPaths(ctx context.Context, args RunArgs, pCfg map[string]any, paths []ExpandedPath) (*RunResult, error) {
+	if err := checkCacheStrategy(args.CacheStrategy); err != nil {
+		logger(ctx).ErrorContext(ctx, err.Error())
+		return nil, err
+	}
+	if err := checkFSArgs(args); err != nil {
+		logger(ctx).ErrorContext(ctx, err.Error())
+		return nil, err
+	}
+	if args.Report != nil {
+		if err := checkReportFormat(args.ReportFormat); err != nil {
+			logger(ctx).ErrorContext(ctx, err.Error())
+			return nil, err
+		}
+	}
+
+	fsys := newFileSystem(args)
+	stdout := args.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+
+	unknownParser, err := newUnknownParserPolicy(args, fsys)
+	if err != nil {
+		logger(ctx).ErrorContext(ctx, err.Error())
+		return nil, err
+	}
+
+	rs := &runState{
+		args:          args,
+		fsys:          fsys,
+		stdout:        stdout,
+		pCfg:          pCfg,
+		unknownParser: unknownParser,
+		manifest:      newManifest(args),
+	}
+	if args.DelegateToNode {
+		rs.node, err = newNodePrettier(ctx, args, fsys, pCfg)
 		if err != nil {
-			return err
+			logger(ctx).ErrorContext(ctx, err.Error())
+			return nil, err
 		}
-		cfgPath = args.Config
-		pCfg = cfg
-	case args.NoConfig:
-		// Do nothing
-	default:
-		for _, name := range []string{".prettierrc", ".prettierrc.json", ".prettierrc.yaml", ".prettierrc.yml", ".prettierrc.toml"} {
-			if p := findConfigFile(name); p != "" {
-				cfg, err := loadConfigFile(ctx, p)
-				if err != nil {
-					return err
+	}
+	if args.Cache && (args.Write || args.Check || args.DryRun) && args.OutDir == "" && !args.Staged {
+		rs.cache = loadCache(args)
+	}
+
+	if args.Check && !args.NulSeparated {
+		fmt.Fprintln(stdout, "Checking formatting...")
+	}
+
+	if args.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, args.Timeout)
+		defer cancel()
+	}
+
+	var numCheckFailed atomic.Uint32
+	var numFailures atomic.Uint32
+	var aborted atomic.Bool
+
+	// Cancelled to stop formatting remaining files once MaxFailures is reached.
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	failed := func() {
+		if n := numFailures.Add(1); args.MaxFailures > 0 && n >= uint32(args.MaxFailures) {
+			stop()
+		}
+	}
+
+	var jr *journal
+	if args.Journal != "" {
+		j, done, err := openJournal(args.Journal, args.Resume)
+		if err != nil {
+			logger(ctx).ErrorContext(ctx, err.Error())
+			return nil, err
+		}
+		jr = j
+		if len(done) > 0 {
+			remaining := make([]ExpandedPath, 0, len(paths))
+			for _, p := range paths {
+				if _, ok := done[p.FilePath]; !ok || p.Error != "" {
+					remaining = append(remaining, p)
 				}
-				cfgPath = p
-				pCfg = cfg
-				break
 			}
+			logger(ctx).InfoContext(ctx, fmt.Sprintf("Resuming run, skipping %d files already processed.", len(paths)-len(remaining)))
+			paths = remaining
 		}
 	}
 
-	paths := expandPatterns(ctx, args, filepath.Dir(cfgPath))
+	results := make([]FileResult, len(paths))
 
-	if args.Check {
-		fmt.Println("Checking formatting...")
+	var notifyMu sync.Mutex
+	notify := func(res FileResult) {
+		if res.Status == "" {
+			return
+		}
+		notifyMu.Lock()
+		defer notifyMu.Unlock()
+		if args.OnFileResult != nil {
+			args.OnFileResult(res.Path, res.Status, res.Err)
+		}
+		if args.onResult != nil {
+			args.onResult(res)
+		}
 	}
+	progress := func(update func(p Progress)) {
+		if args.Progress == nil {
+			return
+		}
+		notifyMu.Lock()
+		defer notifyMu.Unlock()
+		update(args.Progress)
+	}
+	progress(func(p Progress) { p.Discovered(len(paths)) })
+	processed := make([]bool, len(paths))
 
-	var numCheckFailed atomic.Uint32
+	throttle := newMemoryThrottle(args.MemoryLimit)
 
+	concurrency := r.concurrency
+	if args.Concurrency > 0 {
+		concurrency = args.Concurrency
+	}
 	var g errgroup.Group
-	for _, p := range paths {
+	g.SetLimit(concurrency)
+	for i, p := range paths {
+		if runCtx.Err() != nil {
+			aborted.Store(true)
+			break
+		}
 		g.Go(func() error {
-			if p.error != "" {
-				slog.ErrorContext(ctx, p.error)
-				return errors.New(p.error)
+			if runCtx.Err() != nil {
+				aborted.Store(true)
+				return nil
+			}
+			results[i].Path = p.FilePath
+			if p.Error != "" {
+				processed[i] = true
+				logger(ctx).ErrorContext(ctx, p.Error)
+				results[i].Status = StatusError
+				results[i].Message = p.Error
+				results[i].Err = errors.New(p.Error)
+				notify(results[i])
+				progress(func(pr Progress) { pr.Failed(p.FilePath, results[i].Err) })
+				failed()
+				return errors.New(p.Error)
+			}
+			if !throttle.acquire(runCtx) {
+				aborted.Store(true)
+				return nil
+			}
+			progress(func(pr Progress) { pr.Started(p.FilePath) })
+			start := time.Now()
+			status, err := r.format(ctx, rs, p, &results[i])
+			results[i].Duration = time.Since(start)
+			throttle.release()
+			var skipMessage string
+			if errors.Is(err, errFileTooLarge) || errors.Is(err, errReviewDeclined) {
+				skipMessage, err = err.Error(), nil
+			}
+			if err != nil && ctx.Err() != nil {
+				// Interrupted by cancellation or the timeout, logged with
+				// the other files that were not processed.
+				aborted.Store(true)
+				return nil
 			}
-			err := r.format(ctx, p, maps.Clone(pCfg), args.Check, args.Write)
-			if err == errCheckFailed {
+			processed[i] = true
+			progress(func(pr Progress) {
+				if err != nil {
+					pr.Failed(p.FilePath, err)
+				} else {
+					pr.Completed(p.FilePath, status)
+				}
+			})
+			if err != nil {
+				failed()
+			} else if jr != nil {
+				if jErr := jr.record(p.FilePath); jErr != nil {
+					logger(ctx).WarnContext(ctx, fmt.Sprintf("Unable to write journal: %v", jErr))
+				}
+			}
+			results[i].Status = status
+			switch {
+			case skipMessage != "":
+				results[i].Message = skipMessage
+			case status == StatusSkipped && unknownParser.severityOf(p) == UnknownParserIgnore:
+				results[i].Status = ""
+			case status == StatusSkipped, errors.Is(err, ErrUnknownParser):
+				results[i].Message = "No parser could be inferred"
+				results[i].Err = err
+			case err == ErrCheckFailed:
 				numCheckFailed.Add(1)
+			case err != nil:
+				results[i].Err = err
+				var ee *EngineError
+				if errors.As(err, &ee) {
+					results[i].Message = ee.Stderr
+					results[i].Line, results[i].Column = ee.location()
+				} else {
+					results[i].Message = err.Error()
+				}
 			}
+			notify(results[i])
 			return err
 		})
 	}
-	err := g.Wait()
+	err = g.Wait()
+
+	rs.warnings.flush(ctx)
+
+	if jr != nil {
+		if jErr := jr.finish(err == nil && !aborted.Load()); jErr != nil {
+			logger(ctx).WarnContext(ctx, fmt.Sprintf("Unable to finish journal: %v", jErr))
+		}
+	}
+
+	if aborted.Load() && ctx.Err() == nil {
+		logger(ctx).ErrorContext(ctx, fmt.Sprintf("Stopped after %d failures, remaining files were not processed.", args.MaxFailures))
+	}
+
+	if args.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		numUnprocessed := 0
+		for i, p := range paths {
+			if !processed[i] {
+				numUnprocessed++
+				logger(ctx).ErrorContext(ctx, fmt.Sprintf("%s: not processed before the timeout", p.FilePath))
+			}
+		}
+		tErr := fmt.Errorf("runner: %d files were not processed: %w", numUnprocessed, ctx.Err())
+		logger(ctx).ErrorContext(ctx, fmt.Sprintf("Timed out after %v, %d files were not processed.", args.Timeout, numUnprocessed))
+		err = errors.Join(err, tErr)
+	} else if ctx.Err() != nil {
+		err = errors.Join(err, fmt.Errorf("runner: run interrupted: %w", ctx.Err()))
+	}
 
 	if args.Check {
 		if n := numCheckFailed.Load(); n > 0 {
-			slog.Warn(fmt.Sprintf("Code style issues found in %d files. Run Prettier to fix.", n))
+			logger(ctx).WarnContext(ctx, colorize(args.Color, colorYellow, fmt.Sprintf("Code style issues found in %d files. Run Prettier to fix.", n)))
+		} else if !args.NulSeparated {
+			fmt.Fprintln(stdout, colorize(args.Color, colorGreen, "All matched files use Prettier code style!"))
+		}
+	}
+
+	res := &RunResult{}
+	for _, fr := range results {
+		// Files are left without a status if they were not processed or
+		// should not be reported.
+		if fr.Status != "" {
+			res.Files = append(res.Files, fr)
+		}
+	}
+
+	if rs.cache != nil {
+		if cErr := rs.cache.save(); cErr != nil {
+			// Only makes the next run slower.
+			logger(ctx).WarnContext(ctx, fmt.Sprintf("Unable to write cache: %v", cErr))
+		}
+	}
+
+	if rs.manifest != nil {
+		if mErr := rs.manifest.write(args.Manifest); mErr != nil {
+			logger(ctx).ErrorContext(ctx, fmt.Sprintf("Unable to write manifest: %v", mErr))
+			err = errors.Join(err, mErr)
+		}
+	}
+
+	if args.Report != nil {
+		if rErr := writeReport(args.Report, args.ReportFormat, res.Files); rErr != nil {
+			logger(ctx).ErrorContext(ctx, fmt.Sprintf("Unable to write report: %v", rErr))
+			return res, errors.Join(err, rErr)
+		}
+	}
+
+	return res, err
+}
+
+// Expand loads the prettier configuration for args and expands its patterns
+// into the paths to format, without formatting anything.
+func (r *Runner) Expand(ctx context.Context, args RunArgs) (map[string]any, []ExpandedPath, error) {
+	ctx = r.withLogger(ctx)
+
+	fsys := newFileSystem(args)
+
+	pCfg, cfgPath, cfgOverrides, err := r.loadConfig(ctx, args, fsys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	paths := expandPatterns(ctx, args, fsys, configRoot(cfgPath))
+	logger(ctx).DebugContext(ctx, fmt.Sprintf("Expanded %d patterns to %d files.", len(args.Patterns), len(paths)))
+
+	if args.GitOnly {
+		if _, ok := fsys.(osFS); !ok {
+			logger(ctx).ErrorContext(ctx, errGitUnsupportedFS.Error())
+			return nil, nil, errGitUnsupportedFS
+		}
+		tracked, err := gitFiles(ctx, args.Dir, "ls-files", "-z", "--full-name", ":/")
+		if err != nil {
+			logger(ctx).ErrorContext(ctx, err.Error())
+			return nil, nil, err
+		}
+		paths = filterGitFiles(fsys, paths, tracked)
+	}
+
+	if args.ChangedSince != "" {
+		if _, ok := fsys.(osFS); !ok {
+			logger(ctx).ErrorContext(ctx, errGitUnsupportedFS.Error())
+			return nil, nil, errGitUnsupportedFS
+		}
+		changed, err := gitChangedFiles(ctx, args.Dir, args.ChangedSince)
+		if err != nil {
+			logger(ctx).ErrorContext(ctx, err.Error())
+			return nil, nil, err
+		}
+		paths = filterGitFiles(fsys, paths, changed)
+	}
+
+	if args.ShardCount > 0 {
+		if args.ShardIndex < 1 || args.ShardIndex > args.ShardCount {
+			err := fmt.Errorf("runner: invalid shard %d/%d", args.ShardIndex, args.ShardCount)
+			logger(ctx).ErrorContext(ctx, err.Error())
+			return nil, nil, err
+		}
+		paths = shardPaths(paths, args.ShardIndex, args.ShardCount)
+	}
+
+	if args.DirtyFirst {
+		if _, ok := fsys.(osFS); !ok {
+			logger(ctx).ErrorContext(ctx, errGitUnsupportedFS.Error())
+			return nil, nil, errGitUnsupportedFS
+		}
+		dirty, err := gitDirtyFiles(ctx, args.Dir)
+		if err != nil {
+			// The order is only an optimization, so the run can continue.
+			logger(ctx).WarnContext(ctx, fmt.Sprintf("Unable to find changed files: %v", err))
 		} else {
-			fmt.Println("All matched files use Prettier code style!")
+			paths = dirtyFirst(fsys, paths, dirty)
 		}
 	}
 
-	return err
+	annotatePaths(args, fsys, paths, configRoot(cfgPath), cfgOverrides)
+
+	return pCfg, paths, nil
 }
 
-func (r *Runner) format(ctx context.Context, path expandedPath, pCfg map[string]any, check bool, write bool) error {
-	pCfg["filepath"] = path.filePath
-	pCfgBytes, err := json.Marshal(pCfg)
+// annotatePaths sets the options specific to each of paths, from .editorconfig
+// files and overrides.
+func annotatePaths(args RunArgs, fsys fileSystem, paths []ExpandedPath, cfgRoot string, cfgOverrides []configOverride) {
+	if !args.NoEditorConfig {
+		ec := newEditorConfigResolver(fsys)
+		for i, p := range paths {
+			if p.Error == "" {
+				paths[i].Options = ec.options(p.FilePath)
+			}
+		}
+	}
+
+	if len(args.Overrides) > 0 || len(cfgOverrides) > 0 {
+		o := newOptionsOverrides(args, fsys, cfgRoot, cfgOverrides)
+		for i, p := range paths {
+			if p.Error == "" {
+				paths[i].Overrides = o.optionsFor(p.FilePath)
+			}
+		}
+	}
+}
+
+// loadConfig returns the prettier options of args before those specific to
+// files, from the config file, the default config of the Runner, Presets and
+// Options, along with the path to the config file and its overrides section.
+func (r *Runner) loadConfig(ctx context.Context, args RunArgs, fsys fileSystem) (map[string]any, string, []configOverride, error) {
+	if r.err != nil {
+		logger(ctx).ErrorContext(ctx, r.err.Error())
+		return nil, "", nil, r.err
+	}
+
+	pCfg := map[string]any{}
+
+	cfgPath := resolveConfigPath(args, fsys)
+	if cfgPath != "" {
+		logger(ctx).DebugContext(ctx, fmt.Sprintf(`Using config file "%s".`, cfgPath))
+	} else {
+		logger(ctx).DebugContext(ctx, "No config file found.")
+	}
+	switch {
+	case cfgPath != "" && isJSConfigFile(cfgPath) && args.DelegateToNode:
+		// Applied by prettier on Node, which all files are delegated to.
+	case cfgPath != "":
+		cfg, err := loadConfigFile(ctx, fsys, cfgPath, args)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		pCfg = cfg
+		if args.Config == "" {
+			warnConfigConflicts(ctx, fsys, cfgPath, pCfg)
+		}
+	case r.defaultConfig != nil && !args.NoConfig:
+		pCfg = maps.Clone(r.defaultConfig)
+	}
+
+	pCfg, cfgOverrides, err := splitConfigOverrides(pCfg)
 	if err != nil {
-		// Programming bug
-		panic(err)
+		logger(ctx).ErrorContext(ctx, err.Error())
+		return nil, "", nil, err
+	}
+
+	pCfg, err = ApplyPresets(pCfg, args.Presets)
+	if err != nil {
+		logger(ctx).ErrorContext(ctx, err.Error())
+		return nil, "", nil, err
+	}
+	if len(args.Options) > 0 {
+		pCfg = maps.Clone(pCfg)
+		maps.Copy(pCfg, args.Options)
+	}
+	return pCfg, cfgPath, cfgOverrides, nil
+}
+
+// ResolveConfig returns the prettier options a run with args formats the file
+// at path with, combining the config file, Options, .editorconfig files and
+// Overrides. The file does not need to exist.
+func (r *Runner) ResolveConfig(ctx context.Context, args RunArgs, path string) (map[string]any, error) {
+	ctx = r.withLogger(ctx)
+
+	fsys := newFileSystem(args)
+
+	pCfg, cfgPath, cfgOverrides, err := r.loadConfig(ctx, args, fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []ExpandedPath{{FilePath: path}}
+	annotatePaths(args, fsys, paths, configRoot(cfgPath), cfgOverrides)
+	return paths[0].Config(pCfg), nil
+}
+
+// shardPaths returns the paths assigned to the given shard.
+func shardPaths(paths []ExpandedPath, index int, count int) []ExpandedPath {
+	var res []ExpandedPath
+	for _, p := range paths {
+		key := p.FilePath
+		if p.Error != "" {
+			key = p.Error
+		}
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(filepath.ToSlash(key)))
+		if int(h.Sum32()%uint32(count)) == index-1 {
+			res = append(res, p)
+		}
 	}
+	return res
+}
+
+// ResolveConfigFile returns the path to the config file a run with args uses,
+// or an empty string if there is none. Like prettier, the config file in the
+// closest directory is used, but it is searched for from the working
+// directory and applies to all files of the run.
+func (r *Runner) ResolveConfigFile(args RunArgs) string {
+	return resolveConfigPath(args, newFileSystem(args))
+}
 
+// IsIgnored returns whether path would be ignored by a run with args, due to
+// the default ignores, ignore files, node_modules policy or negated patterns.
+// The rule that matched path, if any, is also returned, in the form
+// "<ignore file>:<line>: <pattern>". A matching rule does not mean the path is
+// ignored, since it may be a negated pattern such as "!keep.js".
+func (r *Runner) IsIgnored(args RunArgs, path string) (bool, string) {
+	fsys := newFileSystem(args)
+	ignore := newIgnorer(args, fsys, configRoot(resolveConfigPath(args, fsys)))
+
+	isDir := false
+	if fi, err := fsys.stat(path); err == nil {
+		isDir = fi.IsDir()
+	}
+	return ignore.isIgnored(path, isDir)
+}
+
+// Prewarm formats a small file and discards the result, so that the cost of
+// the first instantiation of the prettier module is paid up front rather than
+// on the first call to Format or Run. The module itself is already compiled
+// when the Runner is created, and any error compiling it is returned.
+func (r *Runner) Prewarm(ctx context.Context) error {
+	_, err := r.Format(ctx, "prewarm.js", []byte("a\n"), map[string]any{})
+	return err
+}
+
+// Format formats src as the contents of filePath using the prettier
+// configuration pCfg. filePath is only used to infer the parser and does
+// not need to exist. ErrUnknownParser is returned if no parser could be
+// inferred for filePath. Errors reported by prettier, such as syntax errors,
+// are returned rather than printed.
+func (r *Runner) Format(ctx context.Context, filePath string, src []byte, pCfg map[string]any) ([]byte, error) {
 	var out bytes.Buffer
+	if err := r.run(ctx, filePath, bytes.NewReader(src), &out, pCfg); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
 
-	fi, err := os.Stat(path.filePath)
+// FormatReader formats the contents of filePath read from src, writing the
+// result to dst, like Format. Nothing is written to dst if formatting fails.
+func (r *Runner) FormatReader(ctx context.Context, filePath string, src io.Reader, dst io.Writer, pCfg map[string]any) error {
+	return r.run(ctx, filePath, src, dst, pCfg)
+}
+
+// FormatAll formats the contents of each file in files like Format,
+// concurrently, returning the formatted contents keyed by the same paths. If
+// any file fails to format, the first error is returned along with its path.
+func (r *Runner) FormatAll(ctx context.Context, files map[string][]byte, pCfg map[string]any) (map[string][]byte, error) {
+	var mu sync.Mutex
+	res := make(map[string][]byte, len(files))
+
+	// Not cancelled on the first error, since formatting with a context that
+	// can be cancelled is slower.
+	var g errgroup.Group
+	g.SetLimit(r.concurrency)
+	for path, src := range files {
+		g.Go(func() error {
+			out, err := r.Format(ctx, path, src, pCfg)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			res[path] = out
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Diff formats src as the contents of filePath like Format, returning a
+// unified diff from src to the formatted output. The diff is empty if src is
+// already formatted.
+func (r *Runner) Diff(ctx context.Context, filePath string, src []byte, pCfg map[string]any) (string, error) {
+	out, err := r.Format(ctx, filePath, src, pCfg)
 	if err != nil {
-		slog.WarnContext(ctx, fmt.Sprintf(`Unable to read file "%s"`, path.filePath))
-		slog.WarnContext(ctx, err.Error())
-		return err
+		return "", err
+	}
+	return diff.Unified(filePath, filePath, src, out), nil
+}
+
+// FormatWithCursor formats src like Format, also returning the position in the
+// formatted output corresponding to cursorOffset, a byte offset in src. This
+// allows editors to keep the cursor in place when formatting.
+func (r *Runner) FormatWithCursor(ctx context.Context, filePath string, src []byte, cursorOffset int, pCfg map[string]any) ([]byte, int, error) {
+	if cursorOffset < 0 || cursorOffset > len(src) {
+		return nil, 0, fmt.Errorf("runner: cursor offset %d out of range", cursorOffset)
 	}
 
-	in, err := os.ReadFile(path.filePath)
+	out, err := r.Format(ctx, filePath, src, pCfg)
 	if err != nil {
-		slog.WarnContext(ctx, fmt.Sprintf(`Unable to read file "%s"`, path.filePath))
-		slog.WarnContext(ctx, err.Error())
-		return err
+		return nil, 0, err
+	}
+	return out, mapCursor(src, out, cursorOffset), nil
+}
+
+// FormatRange formats src like Format, but only the statements overlapping
+// the byte range from rangeStart to rangeEnd, leaving the rest of src as is.
+func (r *Runner) FormatRange(ctx context.Context, filePath string, src []byte, rangeStart int, rangeEnd int, pCfg map[string]any) ([]byte, error) {
+	if rangeStart < 0 || rangeEnd < rangeStart || rangeEnd > len(src) {
+		return nil, fmt.Errorf("runner: range %d-%d out of range", rangeStart, rangeEnd)
+	}
+	return r.Format(ctx, filePath, src, withRange(pCfg, src, rangeStart, rangeEnd))
+}
+
+// withRange returns pCfg with the range options of prettier set to the byte
+// range from start to end of src.
+func withRange(pCfg map[string]any, src []byte, start int, end int) map[string]any {
+	pCfg = maps.Clone(pCfg)
+	if pCfg == nil {
+		pCfg = map[string]any{}
+	}
+	pCfg["rangeStart"] = utf16Offset(src, start)
+	pCfg["rangeEnd"] = utf16Offset(src, end)
+	return pCfg
+}
+
+// run executes the prettier module with src as its input. Output is only
+// written to dst once prettier succeeds.
+func (r *Runner) run(ctx context.Context, filePath string, src io.Reader, dst io.Writer, pCfg map[string]any) error {
+	if r.err != nil {
+		return r.err
 	}
 
+	pCfg = maps.Clone(pCfg)
+	if pCfg == nil {
+		pCfg = map[string]any{}
+	}
+	pCfg["filepath"] = filePath
+	pCfgBytes, err := json.Marshal(pCfg)
+	if err != nil {
+		// Programming bug
+		panic(err)
+	}
+
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+
 	mCfg := wazero.NewModuleConfig().
-		WithStderr(os.Stderr).
-		WithSysNanosleep().
-		WithSysNanotime().
-		WithSysWalltime().
-		WithRandSource(rand.Reader).
+		WithStderr(&stderr).
 		WithArgs("prettier", string(pCfgBytes)).
-		WithStdin(bytes.NewReader(in)).
+		WithStdin(src).
 		WithStdout(&out)
+	if !r.deterministic {
+		// wazero defaults to a fake clock and seeded random source, which is
+		// what deterministic runners use.
+		mCfg = mCfg.
+			WithSysNanosleep().
+			WithSysNanotime().
+			WithSysWalltime().
+			WithRandSource(rand.Reader)
+	}
 
-	_, err = r.rt.InstantiateModule(ctx, r.compiled, mCfg)
+	rt, compiled := r.rt, r.compiled
+	if ctx.Done() != nil {
+		rt, compiled = r.cancellableModule()
+		if rt == nil {
+			// Only when the Runner was closed before compiling it.
+			return errors.New("runner: runner is closed")
+		}
+	}
+
+	_, err = rt.InstantiateModule(ctx, compiled, mCfg)
 	if err != nil {
-		if se, ok := err.(*sys.ExitError); ok {
-			if se.ExitCode() == 10 {
-				if !path.ignoreUnknown {
-					slog.WarnContext(ctx, fmt.Sprintf(`No parser could be inferred for file "%s".`, path.filePath))
-				}
-				return nil
+		if ctx.Err() != nil {
+			return fmt.Errorf("runner: formatting interrupted: %w", ctx.Err())
+		}
+		var se *sys.ExitError
+		if errors.As(err, &se) {
+			if se.ExitCode() == exitCodeUnknownParser {
+				return ErrUnknownParser
 			}
+			return &EngineError{ExitCode: int(se.ExitCode()), Stderr: strings.TrimSpace(stderr.String()), err: err}
 		}
 		return fmt.Errorf("runner: failed to run prettier: %w", err)
 	}
 
-	if write {
-		if err := os.WriteFile(path.filePath, out.Bytes(), fi.Mode()); err != nil {
-			return fmt.Errorf("runner: failed to write file: %w", err)
+	if _, err := out.WriteTo(dst); err != nil {
+		return fmt.Errorf("runner: failed to write output: %w", err)
+	}
+	return nil
+}
+
+// Close releases the resources of the Runner, including the memory of the
+// compiled prettier module. The Runner must not be used after Close.
+func (r *Runner) Close(ctx context.Context) error {
+	var err error
+	r.closeOnce.Do(func() {
+		// Prevents compiling the cancellable module after closing.
+		r.cancellableOnce.Do(func() {})
+		if r.err != nil {
+			return
+		}
+
+		err = errors.Join(r.rt.Close(ctx), r.closeCompiled(ctx, r.compiled, false))
+		if r.cancellableRT != nil {
+			err = errors.Join(err, r.cancellableRT.Close(ctx), r.closeCompiled(ctx, r.cancellableCompiled, true))
+		}
+	})
+	return err
+}
+
+// cancellableModule returns the prettier module compiled to close when the
+// context of its execution is done.
+func (r *Runner) cancellableModule() (wazero.Runtime, wazero.CompiledModule) {
+	r.cancellableOnce.Do(func() {
+		var err error
+		r.cancellableRT, r.cancellableCompiled, err = r.compile(r.rtCfg.WithCloseOnContextDone(true), true)
+		// The module was already compiled by newRunner.
+		if err != nil {
+			panic(err)
+		}
+	})
+	return r.cancellableRT, r.cancellableCompiled
+}
+
+// runState is the state shared by the files of a single run.
+type runState struct {
+	args          RunArgs
+	fsys          fileSystem
+	stdout        io.Writer
+	pCfg          map[string]any
+	unknownParser *unknownParserPolicy
+	node          *nodePrettier
+	warnings      warningAggregator
+	manifest      *manifest
+	cache         *formatCache
+	// reviewMu serializes calls to Review.
+	reviewMu sync.Mutex
+}
+
+// format processes the file at path, setting the details of the outcome
+// beyond its status, such as the changes of an unformatted file, in res.
+func (r *Runner) format(ctx context.Context, rs *runState, path ExpandedPath, res *FileResult) (FileStatus, error) {
+	fsys := rs.fsys
+	check, write, dryRun := rs.args.Check, rs.args.Write, rs.args.DryRun
+
+	fi, err := fsys.stat(path.FilePath)
+	if err != nil {
+		logger(ctx).WarnContext(ctx, fmt.Sprintf(`Unable to read file "%s"`, path.FilePath))
+		logger(ctx).WarnContext(ctx, err.Error())
+		return StatusError, fmt.Errorf("%w: %w", ErrUnreadable, err)
+	}
+
+	if maxSize := rs.args.MaxFileSize; maxSize > 0 && uint64(fi.Size()) > maxSize {
+		logger(ctx).WarnContext(ctx, fmt.Sprintf(`Skipping "%s", its size of %d bytes is over the maximum of %d bytes.`, path.FilePath, fi.Size(), maxSize))
+		return StatusSkipped, fmt.Errorf("%w: %d bytes is over the maximum of %d bytes", errFileTooLarge, fi.Size(), maxSize)
+	}
+
+	in, err := fsys.readFile(path.FilePath)
+	if err != nil {
+		logger(ctx).WarnContext(ctx, fmt.Sprintf(`Unable to read file "%s"`, path.FilePath))
+		logger(ctx).WarnContext(ctx, err.Error())
+		return StatusError, fmt.Errorf("%w: %w", ErrUnreadable, err)
+	}
+
+	pCfg := path.Config(rs.pCfg)
+
+	var cached cacheEntry
+	if rs.cache != nil {
+		cached = cacheEntry{Options: optionsKey(r.wasmDigest, pCfg), File: rs.cache.fileKey(fi, in)}
+		if rs.cache.isFormatted(fsys.abs(path.FilePath), cached) {
+			rs.manifest.record(path.FilePath, in)
+			return StatusFormatted, nil
+		}
+	}
+
+	fileCtx := ctx
+	if rs.args.FileTimeout > 0 {
+		var cancel context.CancelFunc
+		fileCtx, cancel = context.WithTimeout(ctx, rs.args.FileTimeout)
+		defer cancel()
+	}
+
+	var out []byte
+	if rs.node != nil && rs.node.all {
+		out, err = rs.node.format(fileCtx, path.FilePath, in, pCfg)
+	} else {
+		if rs.args.RangeStart > 0 || rs.args.RangeEnd > 0 {
+			start, end := min(rs.args.RangeStart, len(in)), rs.args.RangeEnd
+			if end <= 0 || end > len(in) {
+				end = len(in)
+			}
+			pCfg = withRange(pCfg, in, start, max(start, end))
+		}
+		out, err = r.Format(fileCtx, path.FilePath, in, pCfg)
+		if errors.Is(err, ErrUnknownParser) && rs.node != nil {
+			// Possibly handled by a plugin only available to prettier on Node.
+			out, err = rs.node.format(fileCtx, path.FilePath, in, pCfg)
+		}
+	}
+	if err != nil && fileCtx.Err() != nil && ctx.Err() == nil {
+		logger(ctx).ErrorContext(ctx, fmt.Sprintf("%s: timed out after %v", path.FilePath, rs.args.FileTimeout))
+		return StatusError, fmt.Errorf("runner: formatting timed out after %v: %w", rs.args.FileTimeout, fileCtx.Err())
+	}
+	if err != nil {
+		if errors.Is(err, ErrUnknownParser) {
+			switch rs.unknownParser.severityOf(path) {
+			case UnknownParserError:
+				logger(ctx).ErrorContext(ctx, fmt.Sprintf(warnNoParser.single, path.FilePath))
+				return StatusError, err
+			case UnknownParserWarn:
+				rs.warnings.add(ctx, warnNoParser, path.FilePath)
+			}
+			return StatusSkipped, nil
+		}
+		var ee *EngineError
+		if errors.As(err, &ee) {
+			logger(ctx).ErrorContext(ctx, fmt.Sprintf("%s: %s", path.FilePath, ee.Stderr))
+		}
+		if dir := rs.args.CaptureReproDir; dir != "" {
+			if rErr := captureRepro(dir, path.FilePath, in, pCfg, err); rErr != nil {
+				logger(ctx).WarnContext(ctx, fmt.Sprintf(`Unable to capture reproduction for "%s": %v`, path.FilePath, rErr))
+			}
+		}
+		return StatusError, err
+	}
+
+	rs.manifest.record(path.FilePath, out)
+
+	// The cache only records files that are fully formatted.
+	reviewed := false
+	if rs.args.Review != nil && (write || rs.args.OutDir != "") && !dryRun && !bytes.Equal(in, out) {
+		rs.reviewMu.Lock()
+		accepted := rs.args.Review(path.FilePath, in, out)
+		rs.reviewMu.Unlock()
+		if accepted == nil || bytes.Equal(accepted, in) {
+			return StatusSkipped, errReviewDeclined
 		}
-	} else if !check {
-		fmt.Print(out.String())
+		reviewed = !bytes.Equal(accepted, out)
+		out = accepted
 	}
 
-	if check && !bytes.Equal(in, out.Bytes()) {
-		slog.Warn(path.filePath)
-		return errCheckFailed
+	switch {
+	case dryRun:
+	case rs.args.OutDir != "":
+		if err := writeOutFile(fsys, rs.args.OutDir, path.FilePath, out, fi.Mode()); err != nil {
+			return StatusError, fmt.Errorf("runner: failed to write file: %w", err)
+		}
+	case write:
+		if err := fsys.writeFile(path.FilePath, out, fi.Mode()); err != nil {
+			return StatusError, fmt.Errorf("runner: failed to write file: %w", err)
+		}
+		if rs.cache != nil && rs.args.WriteFile == nil && !reviewed {
+			// Formatted in place, so the written file is formatted.
+			if wfi, err := fsys.stat(path.FilePath); err == nil {
+				rs.cache.record(fsys.abs(path.FilePath), cacheEntry{Options: cached.Options, File: rs.cache.fileKey(wfi, out)})
+			}
+		}
+	case !check:
+		fmt.Fprint(rs.stdout, string(out))
+	}
+
+	if bytes.Equal(in, out) {
+		rs.cache.record(fsys.abs(path.FilePath), cached)
+		return StatusFormatted, nil
+	}
+
+	if check {
+		if rs.args.NulSeparated {
+			_, _ = io.WriteString(rs.stdout, path.FilePath+"\x00")
+		} else {
+			logger(ctx).WarnContext(ctx, colorize(rs.args.Color, colorYellow, path.FilePath))
+			if rs.args.Diff {
+				res.Diff = diff.Unified(path.FilePath, path.FilePath, in, out)
+				d := res.Diff
+				if rs.args.Color {
+					d = diff.Colorize(d)
+				}
+				_, _ = io.WriteString(rs.stdout, d)
+			}
+		}
+		res.Changes = changedLines(in, out)
+		return StatusUnformatted, ErrCheckFailed
 	}
 
+	if dryRun {
+		fmt.Fprintln(rs.stdout, path.FilePath)
+	}
+	return StatusChanged, nil
+}
+
+// checkFSArgs returns an error if args combine FS with options that write to
+// the OS filesystem.
+func checkFSArgs(args RunArgs) error {
+	if args.FS == nil {
+		return nil
+	}
+	var opts []string
+	if args.Cache {
+		opts = append(opts, "Cache")
+	}
+	if args.OutDir != "" {
+		opts = append(opts, "OutDir")
+	}
+	if args.CaptureReproDir != "" {
+		opts = append(opts, "CaptureReproDir")
+	}
+	if len(opts) > 0 {
+		return fmt.Errorf("runner: %s can't be used with FS since they write to the OS filesystem", strings.Join(opts, ", "))
+	}
 	return nil
 }
 
-func findConfigFile(name string) string {
-	dir, err := filepath.Abs(".")
-	if err != nil {
+// writeOutFile writes the formatted contents of the file at name to its path
+// relative to the working directory within outDir.
+func writeOutFile(fsys fileSystem, outDir string, name string, data []byte, perm fs.FileMode) error {
+	// Absolute forms of paths in FS are slash-separated and rooted at "/".
+	rel, err := filepath.Rel(filepath.FromSlash(fsys.abs(".")+"/"), filepath.FromSlash(fsys.abs(name)))
+	if err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("%s is outside of the working directory", name)
+	}
+	p := filepath.Join(outDir, rel)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, perm.Perm())
+}
+
+// resolveConfigPath returns the path to the config file for args, or an empty
+// string if there is none.
+func resolveConfigPath(args RunArgs, fsys fileSystem) string {
+	switch {
+	case args.Config != "":
+		return args.Config
+	case args.NoConfig:
 		return ""
 	}
 
+	// The config file in the closest directory is used, regardless of its name.
+	res := ""
+	for _, name := range ConfigFileNames {
+		for _, p := range fsys.findUp(name) {
+			if isPackageFile(p) && !hasPrettierKey(fsys, p) {
+				continue
+			}
+			if res == "" || len(filepath.Dir(p)) > len(filepath.Dir(res)) {
+				res = p
+			}
+			break
+		}
+	}
+	return res
+}
+
+// configRoot returns the directory ignore files are resolved against for the
+// config file at cfgPath. Remote config files use the working directory.
+func configRoot(cfgPath string) string {
+	if isRemoteConfig(cfgPath) {
+		return "."
+	}
+	return filepath.Dir(cfgPath)
+}
+
+func findConfigFiles(start string, name string) []string {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return nil
+	}
+
+	var res []string
 	for {
 		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
-			return filepath.Join(dir, name)
+			res = append(res, filepath.Join(dir, name))
 		}
 
 		parent := filepath.Dir(dir)
 		if parent == dir || parent == "" {
-			return ""
+			return res
 		}
 
 		dir = parent
 	}
 }
 
-func loadConfigFile(ctx context.Context, path string) (map[string]any, error) {
-	res := map[string]any{}
+func loadConfigFile(ctx context.Context, fsys fileSystem, path string, args RunArgs) (map[string]any, error) {
+	var pCfgBytes []byte
+	var err error
+	if isRemoteConfig(path) {
+		cacheDir := ""
+		if _, ok := fsys.(osFS); ok {
+			cacheDir = remoteConfigCacheDir()
+		}
+		pCfgBytes, err = fetchRemoteConfig(ctx, path, cacheDir)
+	} else {
+		pCfgBytes, err = fsys.readFile(path)
+	}
+	if err != nil {
+		logger(ctx).WarnContext(ctx, fmt.Sprintf(`Unable to read config file "%s"`, path))
+		logger(ctx).WarnContext(ctx, err.Error())
+		return map[string]any{}, fmt.Errorf("%w: %w", ErrUnreadable, err)
+	}
+
+	if args.ConfigIntegrity != "" {
+		if err := checkIntegrity(pCfgBytes, args.ConfigIntegrity); err != nil {
+			logger(ctx).WarnContext(ctx, fmt.Sprintf(`Invalid config file "%s"`, path))
+			logger(ctx).WarnContext(ctx, err.Error())
+			return map[string]any{}, err
+		}
+	}
 
-	pCfgBytes, err := os.ReadFile(path)
+	if args.ConfigExpandEnv {
+		pCfgBytes = expandEnv(pCfgBytes)
+	}
+
+	res, err := parseConfigFile(path, pCfgBytes)
 	if err != nil {
-		slog.WarnContext(ctx, fmt.Sprintf(`Unable to read config file "%s"`, path))
-		slog.WarnContext(ctx, err.Error())
-		return res, err
+		logger(ctx).WarnContext(ctx, fmt.Sprintf(`Invalid config file "%s"`, path))
+		logger(ctx).WarnContext(ctx, err.Error())
+	}
+	return res, err
+}
+
+// ApplyPresets returns pCfg with the options of the named presets from its
+// "presets" section applied in order. The presets section itself is removed
+// since it is not a prettier option.
+func ApplyPresets(pCfg map[string]any, names []string) (map[string]any, error) {
+	presets, ok := pCfg["presets"]
+	if !ok && len(names) == 0 {
+		return pCfg, nil
+	}
+
+	presetsMap, _ := presets.(map[string]any)
+	if presets != nil && presetsMap == nil {
+		return nil, fmt.Errorf("%w: presets must be a map of preset names to options", ErrConfigInvalid)
+	}
+
+	res := maps.Clone(pCfg)
+	delete(res, "presets")
+	for _, name := range names {
+		preset, ok := presetsMap[name]
+		if !ok {
+			return nil, fmt.Errorf("runner: unknown preset %q", name)
+		}
+		opts, ok := preset.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: preset %q must be a map of options", ErrConfigInvalid, name)
+		}
+		maps.Copy(res, opts)
 	}
+	return res, nil
+}
+
+var envVarRE = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandEnv replaces ${VAR} in content with the value of the environment
+// variable VAR, or with default for ${VAR:-default} if it is unset or empty.
+// Unlike os.Expand, $VAR is left as is since it is common in values such as
+// regular expressions.
+func expandEnv(content []byte) []byte {
+	return envVarRE.ReplaceAllFunc(content, func(m []byte) []byte {
+		sm := envVarRE.FindSubmatch(m)
+		if v := os.Getenv(string(sm[1])); v != "" {
+			return []byte(v)
+		}
+		return sm[2]
+	})
+}
+
+// ParseConfig parses the contents of a JSON, YAML or TOML config file.
+func ParseConfig(pCfgBytes []byte) (map[string]any, error) {
+	res := map[string]any{}
 
 	// YAML is superset of JSON so it should be fine to only use YAML to parse.
-	err = yaml.Unmarshal(pCfgBytes, &res)
+	err := yaml.Unmarshal(pCfgBytes, &res)
 	if err == nil {
 		return res, nil
 	}
@@ -238,8 +1582,21 @@ func loadConfigFile(ctx context.Context, path string) (map[string]any, error) {
 		return res, nil
 	}
 
-	slog.WarnContext(ctx, fmt.Sprintf(`Invalid config file "%s"`, path))
 	// JSON / YAML are more common so use it's error rather than TOML's
-	slog.WarnContext(ctx, err.Error())
-	return res, errInvalidConfigFile
+	return res, fmt.Errorf("%w: %w", ErrConfigInvalid, err)
+}
+
+// ANSI escape codes used when Color is set.
+const (
+	colorReset  = "\x1b[0m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+)
+
+// colorize returns s in color if enabled.
+func colorize(enabled bool, color string, s string) string {
+	if !enabled {
+		return s
+	}
+	return color + s + colorReset
 }
@@ -0,0 +1,111 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+
+	"github.com/wasilibs/go-prettier/internal/wasm"
+)
+
+// Option configures a Runner.
+type Option func(*runnerOptions)
+
+type runnerOptions struct {
+	concurrency   int
+	cacheDir      string
+	logger        *slog.Logger
+	wasm          []byte
+	defaultConfig map[string]any
+	// err is an invalid option, returned by the Runner.
+	err error
+}
+
+func newRunnerOptions(opts []Option) runnerOptions {
+	o := runnerOptions{concurrency: runtime.NumCPU(), wasm: wasm.Prettier}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithConcurrency sets the maximum number of files formatted concurrently by
+// Run, which defaults to the number of CPUs.
+func WithConcurrency(n int) Option {
+	return func(o *runnerOptions) {
+		if n > 0 {
+			o.concurrency = n
+		}
+	}
+}
+
+// WithCompilationCacheDir sets the directory to cache the compiled prettier
+// module in, instead of a directory in the user cache directory. It has no
+// effect on deterministic runners, which don't use a cache, or when the
+// interpreter is used.
+func WithCompilationCacheDir(dir string) Option {
+	return func(o *runnerOptions) {
+		o.cacheDir = dir
+	}
+}
+
+// WithLogger sets the logger diagnostics are logged to, instead of the
+// default slog logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *runnerOptions) {
+		o.logger = logger
+	}
+}
+
+// WithStderr logs diagnostics as text to w, a shorthand for WithLogger with a
+// slog.TextHandler.
+func WithStderr(w io.Writer) Option {
+	return WithLogger(slog.New(slog.NewTextHandler(w, nil)))
+}
+
+// WithWasm sets the prettier module to run instead of the embedded one, such
+// as a build of a different version of prettier or with additional plugins.
+// It must be built from buildtools/wasm of the same version of this package,
+// since the runner depends on its arguments and exit codes. If the module
+// can't be compiled, methods of the Runner that format or resolve config
+// return the error, which Prewarm can check for up front.
+func WithWasm(bin []byte) Option {
+	return func(o *runnerOptions) {
+		o.wasm = bin
+	}
+}
+
+// WithDefaultConfig sets config, the contents of a JSON, YAML or TOML config
+// file, to use when no config file is found for a run. If config can't be
+// parsed, methods of the Runner that format or resolve config return the
+// error.
+func WithDefaultConfig(config []byte) Option {
+	return func(o *runnerOptions) {
+		pCfg, err := ParseConfig(config)
+		if err != nil {
+			o.err = fmt.Errorf("runner: invalid default config: %w", err)
+			return
+		}
+		o.defaultConfig = pCfg
+	}
+}
+
+type loggerKey struct{}
+
+// withLogger returns ctx with the logger of the runner, if it has one.
+func (r *Runner) withLogger(ctx context.Context) context.Context {
+	if r.logger == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, loggerKey{}, r.logger)
+}
+
+// logger returns the logger for ctx, falling back to the default logger.
+func logger(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
@@ -0,0 +1,57 @@
+//go:build !js
+
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// memoryCache is shared by all runners in the process when the compilation
+// cache can't be stored on disk, so the module is only compiled once. A
+// shared directory such as the temp directory is not used instead, since
+// other users could place compiled code there.
+var memoryCache = sync.OnceValue(wazero.NewCompilationCache)
+
+// newRuntimeConfig returns the runtime config caching compilation in
+// cacheDir, or the user cache directory if it is empty, and whether it uses
+// the memoryCache shared by runners instead.
+func newRuntimeConfig(cacheDir string) (wazero.RuntimeConfig, bool) {
+	rtCfg := wazero.NewRuntimeConfig()
+	if cache, ok := dirCache(cacheDir); ok {
+		return rtCfg.WithCompilationCache(cache), false
+	}
+	return rtCfg.WithCompilationCache(memoryCache()), true
+}
+
+// dirCache returns a compilation cache in dir, or the user cache directory if
+// it is empty, if it is writable.
+func dirCache(dir string) (wazero.CompilationCache, bool) {
+	if dir == "" {
+		uc, err := os.UserCacheDir()
+		if err != nil {
+			return nil, false
+		}
+		dir = filepath.Join(uc, "com.github.wasilibs")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, false
+	}
+	// Compiling fails if the compiled module can't be written to the cache,
+	// so check the directory is writable to fall back to the memory cache.
+	f, err := os.CreateTemp(dir, "probe")
+	if err != nil {
+		return nil, false
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+
+	cache, err := wazero.NewCompilationCacheWithDir(dir)
+	if err != nil {
+		return nil, false
+	}
+	return cache, true
+}
@@ -0,0 +1,13 @@
+package runner
+
+import (
+	"github.com/tetratelabs/wazero"
+)
+
+// newRuntimeConfig returns the interpreter, the only engine available when
+// running in a JS host. Compilation results of the interpreter are not
+// cached so there is no need to access a cache directory, which may not even
+// exist in a browser.
+func newRuntimeConfig(string) (wazero.RuntimeConfig, bool) {
+	return wazero.NewRuntimeConfigInterpreter(), false
+}
@@ -0,0 +1,190 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// stagedFile is a file staged in the git index.
+type stagedFile struct {
+	// path is the slash-separated path relative to the root of the
+	// repository.
+	path string
+	mode string
+	hash string
+
+	// content is the staged content, once read.
+	content []byte
+}
+
+// stagedFS is the OS filesystem, except that files staged in git are read
+// from and written to the index. Written files are also written to the
+// working tree if it has no unstaged changes to them.
+type stagedFS struct {
+	osFS
+	ctx  context.Context
+	root string
+
+	mu sync.Mutex
+	// files are keyed by absolute path.
+	files map[string]*stagedFile
+}
+
+// newStagedFS returns the filesystem of the staged files of the repository of
+// args.Dir.
+func newStagedFS(ctx context.Context, args RunArgs) (*stagedFS, error) {
+	root, err := gitRoot(ctx, args.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries are ":<old mode> <new mode> <old hash> <new hash> <status>"
+	// followed by the path, each terminated by NUL.
+	out, err := gitOutput(ctx, args.Dir, "diff", "--cached", "--raw", "-z", "--no-abbrev", "--no-renames", "--diff-filter=ACM")
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]*stagedFile{}
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		meta := strings.Fields(fields[i])
+		if len(meta) < 4 {
+			return nil, fmt.Errorf("runner: unexpected output from git diff: %q", fields[i])
+		}
+		p := fields[i+1]
+		files[filepath.Join(root, filepath.FromSlash(p))] = &stagedFile{path: p, mode: meta[1], hash: meta[3]}
+	}
+
+	return &stagedFS{
+		osFS:  osFS{dir: args.Dir},
+		ctx:   ctx,
+		root:  root,
+		files: files,
+	}, nil
+}
+
+// staged returns the absolute paths of the staged files.
+func (s *stagedFS) staged() map[string]struct{} {
+	res := make(map[string]struct{}, len(s.files))
+	for p := range s.files {
+		res[p] = struct{}{}
+	}
+	return res
+}
+
+func (s *stagedFS) file(name string) (*stagedFile, bool) {
+	f, ok := s.files[s.abs(name)]
+	return f, ok
+}
+
+// stagedContent returns the staged content of f.
+func (s *stagedFS) stagedContent(f *stagedFile) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f.content == nil {
+		b, err := gitOutput(s.ctx, s.root, "cat-file", "blob", f.hash)
+		if err != nil {
+			return nil, err
+		}
+		f.content = b
+	}
+	return f.content, nil
+}
+
+func (s *stagedFS) stat(name string) (fs.FileInfo, error) {
+	fi, err := s.osFS.stat(name)
+	f, ok := s.file(name)
+	if !ok {
+		return fi, err
+	}
+	if err != nil {
+		// Deleted from the working tree after staging.
+		return nil, err
+	}
+	b, err := s.stagedContent(f)
+	if err != nil {
+		return nil, err
+	}
+	return stagedFileInfo{FileInfo: fi, size: int64(len(b))}, nil
+}
+
+func (s *stagedFS) readFile(name string) ([]byte, error) {
+	f, ok := s.file(name)
+	if !ok {
+		return s.osFS.readFile(name)
+	}
+	return s.stagedContent(f)
+}
+
+func (s *stagedFS) writeFile(name string, data []byte, perm fs.FileMode) error {
+	f, ok := s.file(name)
+	if !ok {
+		return s.osFS.writeFile(name, data, perm)
+	}
+	staged, err := s.stagedContent(f)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Concurrent updates of the index fail on its lock file, so they are
+	// serialized by mu.
+	hash, err := gitInput(s.ctx, s.root, data, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return err
+	}
+	cacheInfo := fmt.Sprintf("%s,%s,%s", f.mode, strings.TrimSpace(string(hash)), f.path)
+	if _, err := gitOutput(s.ctx, s.root, "update-index", "--cacheinfo", cacheInfo); err != nil {
+		return err
+	}
+
+	// Unstaged changes are left as is, to be formatted when staged.
+	if worktree, err := s.osFS.readFile(name); err == nil && bytes.Equal(worktree, staged) {
+		return os.WriteFile(s.path(name), data, perm.Perm())
+	}
+	return nil
+}
+
+// stagedFileInfo is the info of a file in the working tree with the size of
+// its staged content.
+type stagedFileInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i stagedFileInfo) Size() int64 {
+	return i.size
+}
+
+// runStaged runs on the files matching args that are staged in git, reading
+// them from the index and writing them to it.
+func (r *Runner) runStaged(ctx context.Context, args RunArgs) (*RunResult, error) {
+	if args.FS != nil {
+		logger(ctx).ErrorContext(ctx, errGitUnsupportedFS.Error())
+		return nil, errGitUnsupportedFS
+	}
+
+	sfs, err := newStagedFS(ctx, args)
+	if err != nil {
+		logger(ctx).ErrorContext(ctx, err.Error())
+		return nil, err
+	}
+
+	pCfg, paths, err := r.Expand(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	paths = filterGitFiles(sfs, paths, sfs.staged())
+
+	args.fsys = sfs
+	return r.runPaths(ctx, args, pCfg, paths)
+}
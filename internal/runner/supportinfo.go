@@ -0,0 +1,329 @@
+package runner
+
+import (
+	"context"
+	"slices"
+)
+
+// SupportInfo describes the languages and options supported by the embedded
+// prettier, like the getSupportInfo function of prettier.
+type SupportInfo struct {
+	// Languages are the languages that can be formatted.
+	Languages []LanguageInfo `json:"languages"`
+	// Options are the options accepted by prettier.
+	Options []OptionInfo `json:"options"`
+}
+
+// LanguageInfo describes a language supported by prettier.
+type LanguageInfo struct {
+	// Name is the name of the language, such as "TypeScript".
+	Name string `json:"name"`
+	// Parsers are the parsers of the language, with the one inferred for its
+	// files first.
+	Parsers []string `json:"parsers"`
+	// Extensions are the file extensions of the language, including the
+	// leading dot.
+	Extensions []string `json:"extensions,omitempty"`
+	// Filenames are names of files of the language regardless of extension,
+	// such as "Jakefile".
+	Filenames []string `json:"filenames,omitempty"`
+	// VSCodeLanguageIDs are the identifiers of the language in VS Code.
+	VSCodeLanguageIDs []string `json:"vscodeLanguageIds,omitempty"`
+}
+
+// OptionInfo describes an option of prettier.
+type OptionInfo struct {
+	// Name is the name of the option in config files, such as "tabWidth".
+	Name string `json:"name"`
+	// Type is the type of the option: boolean, int, choice, path or string.
+	Type string `json:"type"`
+	// Category groups options in documentation, such as "Global" or
+	// "JavaScript".
+	Category string `json:"category"`
+	// Description is a short description of the option.
+	Description string `json:"description,omitempty"`
+	// Default is the default value of the option.
+	Default any `json:"default,omitempty"`
+	// Choices are the allowed values of choice options.
+	Choices []OptionChoice `json:"choices,omitempty"`
+}
+
+// OptionChoice is an allowed value of a choice option.
+type OptionChoice struct {
+	// Value is the value of the option.
+	Value any `json:"value"`
+	// Description is a short description of the value.
+	Description string `json:"description,omitempty"`
+}
+
+// SupportInfo returns the languages and options supported by the embedded
+// prettier, for building file filters or validating configuration. They are
+// a table of the embedded build rather than queried from the module, so for a
+// module passed to WithWasm, languages and options added by its plugins are
+// not included.
+func (r *Runner) SupportInfo(context.Context) (*SupportInfo, error) {
+	info := &SupportInfo{
+		Languages: make([]LanguageInfo, len(supportedLanguages)),
+		Options:   make([]OptionInfo, len(supportedOptions)),
+	}
+	// Copied so callers can't modify the tables.
+	for i, l := range supportedLanguages {
+		l.Parsers = slices.Clone(l.Parsers)
+		l.Extensions = slices.Clone(l.Extensions)
+		l.Filenames = slices.Clone(l.Filenames)
+		l.VSCodeLanguageIDs = slices.Clone(l.VSCodeLanguageIDs)
+		info.Languages[i] = l
+	}
+	for i, o := range supportedOptions {
+		o.Choices = slices.Clone(o.Choices)
+		info.Options[i] = o
+	}
+	return info, nil
+}
+
+// supportedLanguages are the languages of the plugins in the prettier module,
+// in the order prettier infers parsers from them, with the parsers that
+// aren't included removed. They must match the languages returned by
+// getSupportInfo with the plugins imported by buildtools/wasm/prettier.ts.
+var supportedLanguages = []LanguageInfo{
+	{
+		Name:    "JavaScript",
+		Parsers: []string{"babel", "acorn", "meriyah"},
+		Extensions: []string{
+			".js", "._js", ".bones", ".cjs", ".es", ".es6", ".frag", ".gs", ".jake", ".javascript", ".jsb", ".jscad",
+			".jsfl", ".jslib", ".jsm", ".jspre", ".jss", ".mjs", ".njs", ".pac", ".sjs", ".ssjs", ".xsjs", ".xsjslib",
+			".wxs",
+		},
+		Filenames:         []string{"Jakefile"},
+		VSCodeLanguageIDs: []string{"javascript", "mongo"},
+	},
+	{
+		Name:              "Flow",
+		Parsers:           []string{"babel-flow"},
+		Extensions:        []string{".js.flow"},
+		VSCodeLanguageIDs: []string{"javascript"},
+	},
+	{
+		Name:              "JSX",
+		Parsers:           []string{"babel", "babel-flow", "babel-ts", "typescript", "meriyah"},
+		Extensions:        []string{".jsx"},
+		VSCodeLanguageIDs: []string{"javascriptreact"},
+	},
+	{
+		Name:              "TypeScript",
+		Parsers:           []string{"typescript", "babel-ts"},
+		Extensions:        []string{".ts", ".cts", ".mts"},
+		VSCodeLanguageIDs: []string{"typescript"},
+	},
+	{
+		Name:              "TSX",
+		Parsers:           []string{"typescript", "babel-ts"},
+		Extensions:        []string{".tsx"},
+		VSCodeLanguageIDs: []string{"typescriptreact"},
+	},
+	{
+		Name:              "JSON.stringify",
+		Parsers:           []string{"json-stringify"},
+		Extensions:        []string{".importmap"},
+		Filenames:         []string{"package.json", "package-lock.json", "composer.json"},
+		VSCodeLanguageIDs: []string{"json"},
+	},
+	{
+		Name:    "JSON",
+		Parsers: []string{"json"},
+		Extensions: []string{
+			".json", ".4DForm", ".4DProject", ".avsc", ".geojson", ".gltf", ".har", ".ice", ".JSON-tmLanguage",
+			".mcmeta", ".tfstate", ".tfstate.backup", ".topojson", ".webapp", ".webmanifest", ".yy", ".yyp",
+		},
+		Filenames: []string{
+			".all-contributorsrc", ".arcconfig", ".auto-changelog", ".c8rc", ".htmlhintrc", ".imgbotconfig", ".nycrc",
+			".tern-config", ".tern-project", ".watchmanconfig", "Pipfile.lock", "composer.lock", "flake.lock",
+			"mcmod.info", ".babelrc", ".jscsrc", ".jshintrc", ".jslintrc", ".swcrc",
+		},
+		VSCodeLanguageIDs: []string{"json"},
+	},
+	{
+		Name:    "JSON with Comments",
+		Parsers: []string{"jsonc"},
+		Extensions: []string{
+			".jsonc", ".code-snippets", ".code-workspace", ".sublime-build", ".sublime-commands",
+			".sublime-completions", ".sublime-keymap", ".sublime-macro", ".sublime-menu", ".sublime-mousemap",
+			".sublime-project", ".sublime-settings", ".sublime-theme", ".sublime-workspace", ".sublime_metrics",
+			".sublime_session",
+		},
+		VSCodeLanguageIDs: []string{"jsonc"},
+	},
+	{
+		Name:              "JSON5",
+		Parsers:           []string{"json5"},
+		Extensions:        []string{".json5"},
+		VSCodeLanguageIDs: []string{"json5"},
+	},
+	{
+		Name:              "Handlebars",
+		Parsers:           []string{"glimmer"},
+		Extensions:        []string{".handlebars", ".hbs"},
+		VSCodeLanguageIDs: []string{"handlebars"},
+	},
+	{
+		Name:              "Angular",
+		Parsers:           []string{"angular"},
+		Extensions:        []string{".component.html"},
+		VSCodeLanguageIDs: []string{"html"},
+	},
+	{
+		Name:              "HTML",
+		Parsers:           []string{"html"},
+		Extensions:        []string{".html", ".hta", ".htm", ".html.hl", ".inc", ".xht", ".xhtml", ".mjml"},
+		VSCodeLanguageIDs: []string{"html"},
+	},
+	{
+		Name:              "Lightning Web Components",
+		Parsers:           []string{"lwc"},
+		VSCodeLanguageIDs: []string{"html"},
+	},
+	{
+		Name:              "Vue",
+		Parsers:           []string{"vue"},
+		Extensions:        []string{".vue"},
+		VSCodeLanguageIDs: []string{"vue"},
+	},
+	{
+		Name:              "GraphQL",
+		Parsers:           []string{"graphql"},
+		Extensions:        []string{".graphql", ".gql", ".graphqls"},
+		VSCodeLanguageIDs: []string{"graphql"},
+	},
+	{
+		Name:    "Markdown",
+		Parsers: []string{"markdown"},
+		Extensions: []string{
+			".md", ".livemd", ".markdown", ".mdown", ".mdwn", ".mkd", ".mkdn", ".mkdown", ".ronn", ".scd", ".workbook",
+		},
+		Filenames:         []string{"contents.lr", "README"},
+		VSCodeLanguageIDs: []string{"markdown"},
+	},
+	{
+		Name:              "MDX",
+		Parsers:           []string{"mdx"},
+		Extensions:        []string{".mdx"},
+		VSCodeLanguageIDs: []string{"mdx"},
+	},
+	{
+		Name:              "CSS",
+		Parsers:           []string{"css"},
+		Extensions:        []string{".css", ".wxss"},
+		VSCodeLanguageIDs: []string{"css"},
+	},
+	{
+		Name:              "PostCSS",
+		Parsers:           []string{"css"},
+		Extensions:        []string{".pcss", ".postcss"},
+		VSCodeLanguageIDs: []string{"postcss"},
+	},
+	{
+		Name:              "Less",
+		Parsers:           []string{"less"},
+		Extensions:        []string{".less"},
+		VSCodeLanguageIDs: []string{"less"},
+	},
+	{
+		Name:              "SCSS",
+		Parsers:           []string{"scss"},
+		Extensions:        []string{".scss"},
+		VSCodeLanguageIDs: []string{"scss"},
+	},
+	{
+		Name:    "YAML",
+		Parsers: []string{"yaml"},
+		Extensions: []string{
+			".yml", ".mir", ".reek", ".rviz", ".sublime-syntax", ".syntax", ".yaml", ".yaml-tmlanguage", ".yaml.sed",
+			".yml.mysql",
+		},
+		Filenames: []string{
+			".clang-format", ".clang-tidy", ".gemrc", "CITATION.cff", "glide.lock", ".prettierrc", ".stylelintrc",
+			".lintstagedrc",
+		},
+		VSCodeLanguageIDs: []string{"ansible", "home-assistant", "yaml"},
+	},
+}
+
+// supportedOptions are the options of prettier with a field in Options, as
+// returned by getSupportInfo.
+var supportedOptions = []OptionInfo{
+	{Name: "printWidth", Type: "int", Category: "Global", Description: "The line length where Prettier will try wrap.", Default: 80},
+	{Name: "tabWidth", Type: "int", Category: "Global", Description: "Number of spaces per indentation level.", Default: 2},
+	{Name: "useTabs", Type: "boolean", Category: "Global", Description: "Indent with tabs instead of spaces.", Default: false},
+	{Name: "semi", Type: "boolean", Category: "JavaScript", Description: "Print semicolons.", Default: true},
+	{Name: "singleQuote", Type: "boolean", Category: "Common", Description: "Use single quotes instead of double quotes.", Default: false},
+	{
+		Name: "quoteProps", Type: "choice", Category: "JavaScript", Description: "Change when properties in objects are quoted.", Default: "as-needed",
+		Choices: []OptionChoice{
+			{Value: "as-needed", Description: "Only add quotes around object properties where required."},
+			{Value: "consistent", Description: "If at least one property in an object requires quotes, quote all properties."},
+			{Value: "preserve", Description: "Respect the input use of quotes in object properties."},
+		},
+	},
+	{Name: "jsxSingleQuote", Type: "boolean", Category: "JavaScript", Description: "Use single quotes in JSX.", Default: false},
+	{
+		Name: "trailingComma", Type: "choice", Category: "JavaScript", Description: "Print trailing commas wherever possible when multi-line.", Default: "all",
+		Choices: []OptionChoice{
+			{Value: "all", Description: "Trailing commas wherever possible (including function arguments)."},
+			{Value: "es5", Description: "Trailing commas where valid in ES5 (objects, arrays, etc.)"},
+			{Value: "none", Description: "No trailing commas."},
+		},
+	},
+	{Name: "bracketSpacing", Type: "boolean", Category: "Common", Description: "Print spaces between brackets.", Default: true},
+	{Name: "bracketSameLine", Type: "boolean", Category: "Common", Description: "Put > of opening tags on the last line instead of on a new line.", Default: false},
+	{
+		Name: "arrowParens", Type: "choice", Category: "JavaScript", Description: "Include parentheses around a sole arrow function parameter.", Default: "always",
+		Choices: []OptionChoice{
+			{Value: "always", Description: "Always include parens. Example: `(x) => x`"},
+			{Value: "avoid", Description: "Omit parens when possible. Example: `x => x`"},
+		},
+	},
+	{Name: "parser", Type: "choice", Category: "Global", Description: "Which parser to use.", Choices: parserChoices()},
+	{
+		Name: "proseWrap", Type: "choice", Category: "Common", Description: "How to wrap prose.", Default: "preserve",
+		Choices: []OptionChoice{
+			{Value: "always", Description: "Wrap prose if it exceeds the print width."},
+			{Value: "never", Description: "Do not wrap prose."},
+			{Value: "preserve", Description: "Wrap prose as-is."},
+		},
+	},
+	{
+		Name: "htmlWhitespaceSensitivity", Type: "choice", Category: "HTML", Description: "How to handle whitespaces in HTML.", Default: "css",
+		Choices: []OptionChoice{
+			{Value: "css", Description: "Respect the default value of CSS display property."},
+			{Value: "strict", Description: "Whitespaces are considered sensitive."},
+			{Value: "ignore", Description: "Whitespaces are considered insensitive."},
+		},
+	},
+	{Name: "vueIndentScriptAndStyle", Type: "boolean", Category: "HTML", Description: "Indent script and style tags in Vue files.", Default: false},
+	{
+		Name: "endOfLine", Type: "choice", Category: "Global", Description: "Which end of line characters to apply.", Default: "lf",
+		Choices: []OptionChoice{
+			{Value: "lf", Description: "Line Feed only (\\n), common on Linux and macOS as well as inside git repos"},
+			{Value: "crlf", Description: "Carriage Return + Line Feed characters (\\r\\n), common on Windows"},
+			{Value: "cr", Description: "Carriage Return character only (\\r), used very rarely"},
+			{Value: "auto", Description: "Maintain existing\n(mixed values within one file are normalised by looking at what's used after the first line)"},
+		},
+	},
+	{
+		Name: "embeddedLanguageFormatting", Type: "choice", Category: "Global", Description: "Control how Prettier formats quoted code embedded in the file.", Default: "auto",
+		Choices: []OptionChoice{
+			{Value: "auto", Description: "Format embedded code if Prettier can automatically identify it."},
+			{Value: "off", Description: "Never automatically format embedded code."},
+		},
+	},
+	{Name: "singleAttributePerLine", Type: "boolean", Category: "Common", Description: "Enforce single attribute per line in HTML, Vue and JSX.", Default: false},
+	{Name: "experimentalTernaries", Type: "boolean", Category: "JavaScript", Description: "Use curious ternaries, with the question mark after the condition.", Default: false},
+}
+
+func parserChoices() []OptionChoice {
+	choices := make([]OptionChoice, len(Parsers))
+	for i, p := range Parsers {
+		choices[i] = OptionChoice{Value: p}
+	}
+	return choices
+}
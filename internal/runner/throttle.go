@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"context"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// memoryThrottle reduces the number of files formatted concurrently while the
+// memory used by the process approaches a limit. At least one file is always
+// formatted so that the run makes progress.
+type memoryThrottle struct {
+	limit uint64
+	// usage returns the memory used by the process, memoryUsage except in
+	// tests.
+	usage func() uint64
+
+	mu     sync.Mutex
+	active int
+}
+
+func newMemoryThrottle(limit uint64) *memoryThrottle {
+	if limit == 0 {
+		return nil
+	}
+	return &memoryThrottle{limit: limit, usage: memoryUsage}
+}
+
+// acquire waits until a file can be formatted, returning false if ctx is done
+// first.
+func (t *memoryThrottle) acquire(ctx context.Context) bool {
+	if t == nil {
+		return true
+	}
+
+	logged := false
+	for {
+		t.mu.Lock()
+		if t.active == 0 || t.usage() < t.limit/10*9 {
+			t.active++
+			t.mu.Unlock()
+			return true
+		}
+		active := t.active
+		t.mu.Unlock()
+
+		if !logged {
+			logger(ctx).DebugContext(ctx, "Memory usage is close to the limit, waiting for other files to finish formatting.", "active", active)
+			logged = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (t *memoryThrottle) release() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.active--
+	t.mu.Unlock()
+}
+
+// memoryUsage returns the larger of the resident set size of the process and
+// the memory held by the Go runtime, which includes the memory of the prettier
+// modules.
+func memoryUsage() uint64 {
+	s := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(s)
+	usage := s[0].Value.Uint64() - s[1].Value.Uint64()
+	if rss := residentSetSize(); rss > usage {
+		usage = rss
+	}
+	return usage
+}
@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryThrottle(t *testing.T) {
+	t.Parallel()
+
+	var usage atomic.Uint64
+	th := newMemoryThrottle(100)
+	th.usage = usage.Load
+
+	t.Run("over limit", func(t *testing.T) {
+		usage.Store(95)
+
+		// Files are formatted one at a time.
+		var active, maxActive atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if !th.acquire(context.Background()) {
+					t.Error("acquire failed")
+					return
+				}
+				n := active.Add(1)
+				for {
+					m := maxActive.Load()
+					if n <= m || maxActive.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				active.Add(-1)
+				th.release()
+			}()
+		}
+		wg.Wait()
+
+		if got := maxActive.Load(); got != 1 {
+			t.Errorf("got %d files formatted concurrently, want 1", got)
+		}
+	})
+
+	t.Run("under limit", func(t *testing.T) {
+		usage.Store(10)
+
+		for i := 0; i < 4; i++ {
+			if !th.acquire(context.Background()) {
+				t.Fatal("acquire failed")
+			}
+		}
+		for i := 0; i < 4; i++ {
+			th.release()
+		}
+	})
+
+	t.Run("waits for usage to drop", func(t *testing.T) {
+		usage.Store(95)
+
+		if !th.acquire(context.Background()) {
+			t.Fatal("acquire failed")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if th.acquire(ctx) {
+			t.Fatal("acquired over the limit")
+		}
+
+		acquired := make(chan bool)
+		go func() {
+			acquired <- th.acquire(context.Background())
+		}()
+		usage.Store(10)
+		if !<-acquired {
+			t.Fatal("acquire failed")
+		}
+
+		th.release()
+		th.release()
+	})
+}
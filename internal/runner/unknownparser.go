@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"fmt"
+
+	"github.com/wasilibs/go-prettier/internal/gitignore"
+)
+
+// Severities of files no parser could be inferred for, used in
+// RunArgs.UnknownParser.
+const (
+	// UnknownParserIgnore skips the file silently.
+	UnknownParserIgnore = "ignore"
+	// UnknownParserWarn skips the file with a warning.
+	UnknownParserWarn = "warn"
+	// UnknownParserError fails the run.
+	UnknownParserError = "error"
+)
+
+// UnknownParserOverride sets the severity of files no parser could be inferred
+// for that match a pattern.
+type UnknownParserOverride struct {
+	// Pattern is a gitignore-style pattern matched against paths relative to
+	// the working directory.
+	Pattern string
+	// Severity is one of UnknownParserIgnore, UnknownParserWarn or
+	// UnknownParserError.
+	Severity string
+}
+
+// unknownParserPolicy resolves the severity of files no parser could be
+// inferred for.
+type unknownParserPolicy struct {
+	severity  string
+	base      string
+	fsys      fileSystem
+	overrides gitignore.Matcher
+}
+
+func newUnknownParserPolicy(args RunArgs, fsys fileSystem) (*unknownParserPolicy, error) {
+	if args.UnknownParser != "" {
+		if err := checkUnknownParserSeverity(args.UnknownParser); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &unknownParserPolicy{
+		severity: args.UnknownParser,
+		base:     fsys.abs("."),
+		fsys:     fsys,
+	}
+	for _, o := range args.UnknownParserOverrides {
+		if err := checkUnknownParserSeverity(o.Severity); err != nil {
+			return nil, err
+		}
+		// The severity is stored as the source of the pattern to retrieve it
+		// on match.
+		p.overrides.Add(o.Severity, []byte(o.Pattern))
+	}
+	return p, nil
+}
+
+func checkUnknownParserSeverity(severity string) error {
+	switch severity {
+	case UnknownParserIgnore, UnknownParserWarn, UnknownParserError:
+		return nil
+	default:
+		return fmt.Errorf("runner: unknown severity %q for files without a parser, expected ignore, warn or error", severity)
+	}
+}
+
+// severityOf returns the severity for path. Without any configured severity,
+// files found by walking a directory are ignored and others warned about.
+func (p *unknownParserPolicy) severityOf(path ExpandedPath) string {
+	if rel, ok := relPath(p.base, p.fsys.abs(path.FilePath)); ok {
+		if _, m := p.overrides.Ignored(rel, false); m != nil {
+			return m.Source
+		}
+	}
+
+	switch {
+	case p.severity != "":
+		return p.severity
+	case path.IgnoreUnknown:
+		return UnknownParserIgnore
+	default:
+		return UnknownParserWarn
+	}
+}
@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// warningSamples is the number of paths included in a summarized warning.
+const warningSamples = 3
+
+// repeatedWarning is a warning that may be logged for many files of a run.
+type repeatedWarning struct {
+	// single is the message for one file, formatted with its path.
+	single string
+	// summary is the message for multiple files, formatted with their
+	// number and sample paths.
+	summary string
+}
+
+var warnNoParser = repeatedWarning{
+	single:  `No parser could be inferred for file "%s".`,
+	summary: "No parser could be inferred for %d files, such as %s.",
+}
+
+// warningAggregator collects repeated warnings during a run to log each of
+// them once at its end, keeping logs of runs over messy trees readable. The
+// individual warnings are logged at debug level as they occur.
+type warningAggregator struct {
+	mu    sync.Mutex
+	paths map[repeatedWarning][]string
+	order []repeatedWarning
+}
+
+func (w *warningAggregator) add(ctx context.Context, warning repeatedWarning, path string) {
+	logger(ctx).DebugContext(ctx, fmt.Sprintf(warning.single, path))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.paths == nil {
+		w.paths = map[repeatedWarning][]string{}
+	}
+	if _, ok := w.paths[warning]; !ok {
+		w.order = append(w.order, warning)
+	}
+	w.paths[warning] = append(w.paths[warning], path)
+}
+
+// flush logs the collected warnings.
+func (w *warningAggregator) flush(ctx context.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, warning := range w.order {
+		paths := w.paths[warning]
+		if len(paths) == 1 {
+			logger(ctx).WarnContext(ctx, fmt.Sprintf(warning.single, paths[0]))
+			continue
+		}
+		// Files are processed concurrently, so sort for stable output.
+		sort.Strings(paths)
+		samples := make([]string, 0, warningSamples)
+		for _, p := range paths[:min(len(paths), warningSamples)] {
+			samples = append(samples, fmt.Sprintf(`"%s"`, p))
+		}
+		logger(ctx).WarnContext(ctx, fmt.Sprintf(warning.summary, len(paths), strings.Join(samples, ", ")))
+	}
+	w.paths = nil
+	w.order = nil
+}
@@ -0,0 +1,162 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// defaultWatchDelay is how long Watch waits for changes to settle if no delay
+// is given.
+const defaultWatchDelay = 100 * time.Millisecond
+
+// fileState is the metadata of a file that changes when it is modified.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watch processes the files matching args like Run, then watches them for
+// changes and processes them again as they change, until ctx is done. Changes
+// are processed once there have been none for delay, so that bursts of
+// changes, such as from switching branches, are processed in a single pass.
+// Files that start matching args, such as new files, are processed when they
+// appear. Failures of a pass are logged like in Run and don't stop watching,
+// but an invalid config file does. The compiled module is reused by all
+// passes, so files are processed without the startup cost of a new run.
+// Watching files of RunArgs.FS is not supported.
+func (r *Runner) Watch(ctx context.Context, args RunArgs, delay time.Duration) error {
+	if args.FS != nil {
+		return errors.New("runner: watching files of FS is not supported")
+	}
+	if delay <= 0 {
+		delay = defaultWatchDelay
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("runner: failed to watch files: %w", err)
+	}
+	defer watcher.Close()
+
+	fsys := newFileSystem(args)
+	root := fsys.abs(".")
+	states := map[string]fileState{}
+	watched := map[string]struct{}{}
+
+	stat := func(path string) (fileState, bool) {
+		fi, err := fsys.stat(path)
+		if err != nil {
+			return fileState{}, false
+		}
+		return fileState{modTime: fi.ModTime(), size: fi.Size()}, true
+	}
+
+	// watch adds a watch of dir. Directories that can't be watched, such as
+	// removed ones, are retried by the next pass matching files in them.
+	watch := func(dir string) {
+		if _, ok := watched[dir]; ok {
+			return
+		}
+		if err := watcher.Add(dir); err == nil {
+			watched[dir] = struct{}{}
+		}
+	}
+
+	// The first pass runs immediately.
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			logger(r.withLogger(ctx)).WarnContext(ctx, fmt.Sprintf("Error watching files: %v", err))
+			continue
+		case ev := <-watcher.Events:
+			switch {
+			case ev.Has(fsnotify.Create):
+				// New directories are watched for files created in them
+				// before the next pass.
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+					watch(ev.Name)
+				}
+			case ev.Has(fsnotify.Remove), ev.Has(fsnotify.Rename):
+				// Watches of removed directories are removed with them.
+				delete(watched, ev.Name)
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(delay)
+			continue
+		case <-timer.C:
+		}
+
+		pCfg, paths, err := r.Expand(ctx, args)
+		if err != nil {
+			return err
+		}
+
+		watch(root)
+		var changed []ExpandedPath
+		seen := make(map[string]struct{}, len(paths))
+		for _, p := range paths {
+			if p.Error != "" {
+				continue
+			}
+			seen[p.FilePath] = struct{}{}
+			watchParents(fsys.abs(p.FilePath), root, watch)
+			st, ok := stat(p.FilePath)
+			if !ok {
+				continue
+			}
+			if prev, ok := states[p.FilePath]; !ok || prev != st {
+				changed = append(changed, p)
+			}
+			states[p.FilePath] = st
+		}
+		for p := range states {
+			if _, ok := seen[p]; !ok {
+				delete(states, p)
+			}
+		}
+
+		if len(changed) > 0 {
+			// Failures are logged by the run and fixed by later changes.
+			_, _ = r.runPaths(r.withLogger(ctx), args, pCfg, changed)
+			// Files written by the run are formatted, so don't process them
+			// again for the write.
+			for _, p := range changed {
+				if st, ok := stat(p.FilePath); ok {
+					states[p.FilePath] = st
+				}
+			}
+		}
+	}
+}
+
+// watchParents calls watch with the directory of path and its parents up to
+// root, so that files created next to matched files and in new directories
+// are noticed.
+func watchParents(path string, root string, watch func(dir string)) {
+	dir := filepath.Dir(path)
+	for {
+		watch(dir)
+		parent := filepath.Dir(dir)
+		if dir == root || parent == dir || !strings.HasPrefix(parent, root) {
+			return
+		}
+		dir = parent
+	}
+}
@@ -4,3 +4,14 @@ import _ "embed"
 
 //go:embed prettier.wasm
 var Prettier []byte
+
+// PrettierVersion is the version of prettier compiled into Prettier. It must
+// match the version in buildtools/wasm/package.json.
+const PrettierVersion = "3.2.5"
+
+// Plugins are the names of the prettier plugins compiled into Prettier. They
+// must match the plugins imported by buildtools/wasm/prettier.ts.
+var Plugins = []string{
+	"acorn", "angular", "babel", "estree", "glimmer", "graphql", "html", "markdown", "meriyah",
+	"postcss", "typescript", "yaml",
+}
@@ -0,0 +1,219 @@
+// Package prettier formats files with prettier, executed with the pure Go Wasm
+// runtime wazero. It provides the same functionality as the prettier command
+// for use within Go programs.
+package prettier
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/wasilibs/go-prettier/internal/runner"
+	"github.com/wasilibs/go-prettier/internal/wasm"
+)
+
+// PrettierVersion is the version of prettier embedded in this package. The
+// included plugins are part of prettier and share its version.
+const PrettierVersion = wasm.PrettierVersion
+
+// Runner formats files with prettier. Creating a Runner compiles the prettier
+// module, so a Runner should be reused for all formatting in a program. It is
+// safe for concurrent use.
+type Runner = runner.Runner
+
+// RunArgs are the arguments to Runner.Run, mirroring the flags of the
+// prettier CLI.
+type RunArgs = runner.RunArgs
+
+// RunResult is the outcome of Runner.Run, with the result of each file.
+type RunResult = runner.RunResult
+
+// FileResult is the outcome of processing a file in Runner.Run.
+type FileResult = runner.FileResult
+
+// FileStatus is the outcome of processing a file.
+type FileStatus = runner.FileStatus
+
+// Outcomes of processing a file.
+const (
+	// StatusFormatted means the file was already formatted.
+	StatusFormatted = runner.StatusFormatted
+	// StatusChanged means formatting changed the file.
+	StatusChanged = runner.StatusChanged
+	// StatusUnformatted means the file failed a check.
+	StatusUnformatted = runner.StatusUnformatted
+	// StatusSkipped means no parser could be inferred for the file.
+	StatusSkipped = runner.StatusSkipped
+	// StatusError means the file could not be read, formatted or written.
+	StatusError = runner.StatusError
+)
+
+// Progress receives the progress of Runner.Run, set in RunArgs.Progress.
+type Progress = runner.Progress
+
+// Options are prettier options. Zero values are unset, leaving the option at
+// its default. Options without a field can be set in Options.Extra.
+type Options = runner.Options
+
+// Bool returns a pointer to b, for setting boolean fields of Options.
+func Bool(b bool) *bool {
+	return &b
+}
+
+// OptionsOverride sets prettier options for files matching a pattern, used in
+// RunArgs.Overrides.
+type OptionsOverride = runner.OptionsOverride
+
+// FileInfo describes how a file is handled by Runner.Run, returned by
+// Runner.FileInfo.
+type FileInfo = runner.FileInfo
+
+// SupportInfo describes the languages and options supported by the embedded
+// prettier, returned by Runner.SupportInfo. Languages and options of plugins
+// in a module passed to WithWasm are not included.
+type SupportInfo = runner.SupportInfo
+
+// LanguageInfo describes a language supported by prettier.
+type LanguageInfo = runner.LanguageInfo
+
+// OptionInfo describes an option of prettier.
+type OptionInfo = runner.OptionInfo
+
+// OptionChoice is an allowed value of a choice option.
+type OptionChoice = runner.OptionChoice
+
+// ErrUnknownParser is returned by Runner.Format when no parser could be
+// inferred for the file.
+var ErrUnknownParser = runner.ErrUnknownParser
+
+// ErrCheckFailed is returned by Runner.Run when a file is not formatted with
+// RunArgs.Check.
+var ErrCheckFailed = runner.ErrCheckFailed
+
+// ErrConfigInvalid is returned when a config file can't be parsed or can't be
+// used, such as a JavaScript config file.
+var ErrConfigInvalid = runner.ErrConfigInvalid
+
+// ErrUnreadable is returned when a file or config file can't be read.
+var ErrUnreadable = runner.ErrUnreadable
+
+// ErrParse is matched by an EngineError with errors.Is when prettier fails to
+// parse a file due to a syntax error.
+var ErrParse = runner.ErrParse
+
+// ErrNodeNotFound is returned by Runner.Run with RunArgs.DelegateToNode when
+// there is no prettier installed in node_modules.
+var ErrNodeNotFound = runner.ErrNodeNotFound
+
+// EngineError is returned by Runner.Format when prettier fails to format a
+// file, for example due to a syntax error. Use errors.As to access the exit
+// code and message of prettier.
+type EngineError = runner.EngineError
+
+// Option configures a Runner.
+type Option = runner.Option
+
+// WithConcurrency sets the maximum number of files formatted concurrently by
+// Runner.Run, which defaults to the number of CPUs.
+func WithConcurrency(n int) Option {
+	return runner.WithConcurrency(n)
+}
+
+// WithWasm sets the prettier module to run instead of the embedded one, such
+// as a build of a different version of prettier or with additional plugins.
+// It must be built from buildtools/wasm of the same version of this package.
+// If the module can't be compiled, methods of the Runner that format or
+// resolve config return the error, which Runner.Prewarm can check for up
+// front.
+func WithWasm(bin []byte) Option {
+	return runner.WithWasm(bin)
+}
+
+// WithDefaultConfig sets config, the contents of a JSON, YAML or TOML config
+// file, to use when no config file is found for a run. It allows wrapper
+// programs to enforce a house style, for example with a config file embedded
+// using go:embed. If config can't be parsed, methods of the Runner that format
+// or resolve config return the error.
+func WithDefaultConfig(config []byte) Option {
+	return runner.WithDefaultConfig(config)
+}
+
+// WithCompilationCacheDir sets the directory to cache the compiled prettier
+// module in, instead of a directory in the user cache directory.
+func WithCompilationCacheDir(dir string) Option {
+	return runner.WithCompilationCacheDir(dir)
+}
+
+// WithLogger sets the logger diagnostics are logged to, instead of the
+// default slog logger.
+func WithLogger(logger *slog.Logger) Option {
+	return runner.WithLogger(logger)
+}
+
+// WithStderr logs diagnostics as text to w.
+func WithStderr(w io.Writer) Option {
+	return runner.WithStderr(w)
+}
+
+// NewRunner returns a new Runner configured with opts.
+func NewRunner(opts ...Option) *Runner {
+	return runner.NewRunner(opts...)
+}
+
+// NewDeterministicRunner returns a Runner that produces identical output for
+// identical inputs on any machine, without reading or writing a compilation
+// cache. Prettier sees a fixed clock and random source. Combined with
+// Runner.Format or RunArgs.FS, formatting has no side effects, which is
+// useful for content-addressed build systems and fuzzing.
+func NewDeterministicRunner(opts ...Option) *Runner {
+	return runner.NewDeterministicRunner(opts...)
+}
+
+var defaultRunner = sync.OnceValue(func() *Runner {
+	return NewRunner()
+})
+
+// Default returns the Runner shared by the package-level functions such as
+// Format, created on first use. Programs that don't need to configure a Runner
+// can use it to avoid compiling prettier more than once. It must not be closed.
+func Default() *Runner {
+	return defaultRunner()
+}
+
+// Format formats src as the contents of a file named filename with opts,
+// without accessing the filesystem. filename is only used to infer the parser.
+// It uses the Default Runner.
+func Format(ctx context.Context, filename string, src []byte, opts Options) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return Default().Format(ctx, filename, src, opts.Map())
+}
+
+// FormatAll formats the contents of each file in files with opts like Format,
+// concurrently, returning the formatted contents keyed by the same paths.
+func FormatAll(ctx context.Context, files map[string][]byte, opts Options) (map[string][]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return Default().FormatAll(ctx, files, opts.Map())
+}
+
+// FormatWithCursor formats src like Format, also returning the position in the
+// formatted output corresponding to cursorOffset, a byte offset in src.
+func FormatWithCursor(ctx context.Context, filename string, src []byte, cursorOffset int, opts Options) ([]byte, int, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, 0, err
+	}
+	return Default().FormatWithCursor(ctx, filename, src, cursorOffset, opts.Map())
+}
+
+// FormatRange formats src like Format, but only the statements overlapping
+// the byte range from rangeStart to rangeEnd.
+func FormatRange(ctx context.Context, filename string, src []byte, rangeStart int, rangeEnd int, opts Options) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return Default().FormatRange(ctx, filename, src, rangeStart, rangeEnd, opts.Map())
+}
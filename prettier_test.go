@@ -1,15 +1,31 @@
 package prettier
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"maps"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/wasilibs/go-prettier/internal/runner"
+	"github.com/wasilibs/go-prettier/internal/wasm"
 )
 
 //go:embed testdata/in
@@ -32,6 +48,16 @@ func TestRun(t *testing.T) {
 		name  string
 		args  runner.RunArgs
 		outFS fs.FS
+		// extraFiles are written to the directory in addition to the input
+		// files.
+		extraFiles map[string]string
+		// wantStatus is the status of files in the results, empty for files
+		// without a result.
+		wantStatus map[string]runner.FileStatus
+		wantErr    error
+		// inDir runs in the directory, for options with paths relative to
+		// the working directory.
+		inDir bool
 	}{
 		{
 			name: "no config, write",
@@ -64,9 +90,87 @@ func TestRun(t *testing.T) {
 			},
 			outFS: outFilesTabWidth4,
 		},
+		{
+			name: "editorconfig, write",
+			args: runner.RunArgs{
+				Write: true,
+			},
+			extraFiles: map[string]string{".editorconfig": "root = true\n\n[*]\nindent_style = space\nindent_size = 4\n"},
+			outFS:      outFilesTabWidth4,
+		},
+		{
+			name: "no editorconfig, write",
+			args: runner.RunArgs{
+				Write:          true,
+				NoEditorConfig: true,
+			},
+			extraFiles: map[string]string{".editorconfig": "root = true\n\n[*]\nindent_style = space\nindent_size = 4\n"},
+			outFS:      outFiles,
+		},
+		{
+			name: "unknown parser, default",
+			args: runner.RunArgs{
+				Write: true,
+			},
+			outFS:      outFiles,
+			extraFiles: map[string]string{"unknown.xyz": "data"},
+			wantStatus: map[string]runner.FileStatus{"unknown.xyz": ""},
+		},
+		{
+			name: "unknown parser, ignore",
+			args: runner.RunArgs{
+				Write:         true,
+				UnknownParser: runner.UnknownParserIgnore,
+			},
+			outFS:      outFiles,
+			extraFiles: map[string]string{"unknown.xyz": "data"},
+			wantStatus: map[string]runner.FileStatus{"unknown.xyz": ""},
+		},
+		{
+			name: "unknown parser, warn",
+			args: runner.RunArgs{
+				Write:         true,
+				UnknownParser: runner.UnknownParserWarn,
+			},
+			outFS:      outFiles,
+			extraFiles: map[string]string{"unknown.xyz": "data"},
+			wantStatus: map[string]runner.FileStatus{"unknown.xyz": runner.StatusSkipped},
+		},
+		{
+			name: "unknown parser, error",
+			args: runner.RunArgs{
+				Write:         true,
+				UnknownParser: runner.UnknownParserError,
+			},
+			outFS:      outFiles,
+			extraFiles: map[string]string{"unknown.xyz": "data"},
+			wantStatus: map[string]runner.FileStatus{"unknown.xyz": runner.StatusError},
+			wantErr:    runner.ErrUnknownParser,
+		},
+		{
+			name: "unknown parser, overrides",
+			args: runner.RunArgs{
+				Write:         true,
+				UnknownParser: runner.UnknownParserError,
+				UnknownParserOverrides: []runner.UnknownParserOverride{
+					{Pattern: "*.warn", Severity: runner.UnknownParserWarn},
+					{Pattern: "*.ignore", Severity: runner.UnknownParserIgnore},
+				},
+			},
+			outFS: outFiles,
+			inDir: true,
+			extraFiles: map[string]string{
+				"unknown.warn":   "data",
+				"unknown.ignore": "data",
+			},
+			wantStatus: map[string]runner.FileStatus{
+				"unknown.warn":   runner.StatusSkipped,
+				"unknown.ignore": "",
+			},
+		},
 	}
 
-	r := runner.NewRunner()
+	r := runner.NewRunner(runner.WithStderr(io.Discard))
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -88,13 +192,36 @@ func TestRun(t *testing.T) {
 			}); err != nil {
 				t.Fatal(err)
 			}
+			for path, content := range tc.extraFiles {
+				if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
 
 			args := tc.args
 			args.Patterns = append(args.Patterns, dir)
-			if err := r.Run(context.Background(), args); err != nil {
+			if tc.inDir {
+				args.Dir = dir
+			}
+			res, err := r.Run(context.Background(), args)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tc.wantErr)
+				}
+			} else if err != nil {
 				t.Fatal(err)
 			}
 
+			statuses := map[string]runner.FileStatus{}
+			for _, f := range res.Files {
+				statuses[f.Path] = f.Status
+			}
+			for path, want := range tc.wantStatus {
+				if got := statuses[filepath.Join(dir, path)]; got != want {
+					t.Errorf("%s: got status %q, want %q", path, got, want)
+				}
+			}
+
 			if err := fs.WalkDir(tc.outFS, ".", func(path string, d fs.DirEntry, err error) error {
 				if err != nil {
 					return err
@@ -121,3 +248,2609 @@ func TestRun(t *testing.T) {
 		})
 	}
 }
+
+func TestRunWriteFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte("#  a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	written := map[string]string{}
+	r := runner.NewRunner()
+	if _, err := r.Run(context.Background(), runner.RunArgs{
+		Patterns: []string{"a.md"},
+		Dir:      dir,
+		Write:    true,
+		WriteFile: func(path string, content []byte) error {
+			written[path] = string(content)
+			return nil
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := map[string]string{"a.md": "# a\n"}; !maps.Equal(written, want) {
+		t.Errorf("got: %v, want: %v", written, want)
+	}
+	// The file on disk is left as is.
+	if got, _ := os.ReadFile(filepath.Join(dir, "a.md")); string(got) != "#  a\n" {
+		t.Errorf("file was modified: %q", got)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"a.md": {Data: []byte("# a\n")},
+		"b.md": {Data: []byte("#  b\n")},
+	}
+
+	var stdout bytes.Buffer
+	r := runner.NewRunner()
+	res, err := r.Run(context.Background(), runner.RunArgs{
+		Patterns: []string{"."},
+		FS:       fsys,
+		Write:    true,
+		DryRun:   true,
+		WriteFile: func(path string, _ []byte) error {
+			t.Errorf("unexpected write of %s", path)
+			return nil
+		},
+		Stdout: &stdout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), "b.md\n"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+	if len(res.Files) != 2 {
+		t.Errorf("got %d results, want 2", len(res.Files))
+	}
+}
+
+func TestCheckDiff(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"a.md": {Data: []byte("# a\n")},
+		"b.md": {Data: []byte("#  b\n")},
+	}
+
+	var stdout bytes.Buffer
+	r := runner.NewRunner(runner.WithStderr(io.Discard))
+	res, err := r.Run(context.Background(), runner.RunArgs{
+		Patterns: []string{"."},
+		FS:       fsys,
+		Check:    true,
+		Diff:     true,
+		Color:    true,
+		Stdout:   &stdout,
+	})
+	if !errors.Is(err, runner.ErrCheckFailed) {
+		t.Fatalf("got error %v, want ErrCheckFailed", err)
+	}
+	want := "Checking formatting...\n" +
+		"\x1b[1m--- b.md\x1b[0m\n\x1b[1m+++ b.md\x1b[0m\n" +
+		"\x1b[36m@@ -1 +1 @@\x1b[0m\n\x1b[31m-#  b\x1b[0m\n\x1b[32m+# b\x1b[0m\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	// Results have the diff without color.
+	wantDiff := "--- b.md\n+++ b.md\n@@ -1 +1 @@\n-#  b\n+# b\n"
+	for _, f := range res.Files {
+		if f.Path == "b.md" && f.Diff != wantDiff {
+			t.Errorf("got diff: %q, want: %q", f.Diff, wantDiff)
+		}
+	}
+}
+
+func TestMaxFileSize(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"a.md": {Data: []byte("#  a\n")},
+		"b.md": {Data: []byte("#  b\n\n" + strings.Repeat("b", 100) + "\n")},
+	}
+
+	r := runner.NewRunner(runner.WithStderr(io.Discard))
+	res, err := r.Run(context.Background(), runner.RunArgs{
+		Patterns:    []string{"."},
+		FS:          fsys,
+		Check:       true,
+		MaxFileSize: 10,
+		Stdout:      io.Discard,
+	})
+	if !errors.Is(err, runner.ErrCheckFailed) {
+		t.Fatalf("got error %v, want ErrCheckFailed", err)
+	}
+	want := []runner.FileStatus{runner.StatusUnformatted, runner.StatusSkipped}
+	if len(res.Files) != len(want) {
+		t.Fatalf("got %d results, want %d", len(res.Files), len(want))
+	}
+	for i, f := range res.Files {
+		if f.Status != want[i] {
+			t.Errorf("%s: got status %s, want %s", f.Path, f.Status, want[i])
+		}
+	}
+}
+
+func TestMaxFailures(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"a.md": {Data: []byte("# a\n")},
+		"b.md": {Data: []byte("#  b\n")},
+		"c.md": {Data: []byte("#  c\n")},
+		"d.md": {Data: []byte("#  d\n")},
+		"e.md": {Data: []byte("#  e\n")},
+	}
+
+	var stderr bytes.Buffer
+	r := runner.NewRunner(runner.WithStderr(&stderr))
+	res, err := r.Run(context.Background(), runner.RunArgs{
+		Patterns:    []string{"."},
+		FS:          fsys,
+		Check:       true,
+		MaxFailures: 2,
+		// Process files in order so the failures are known.
+		Concurrency: 1,
+		Stdout:      io.Discard,
+	})
+	if !errors.Is(err, runner.ErrCheckFailed) {
+		t.Fatalf("got error %v, want ErrCheckFailed", err)
+	}
+
+	var paths []string
+	for _, f := range res.Files {
+		paths = append(paths, f.Path)
+	}
+	if want := []string{"a.md", "b.md", "c.md"}; !slices.Equal(paths, want) {
+		t.Errorf("got processed files %v, want %v", paths, want)
+	}
+	if !strings.Contains(stderr.String(), "Stopped after 2 failures") {
+		t.Errorf("got log %q, want stopped after 2 failures", stderr.String())
+	}
+}
+
+func TestRunConcurrency(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{}
+	for i := 0; i < 8; i++ {
+		fsys[fmt.Sprintf("%d.md", i)] = &fstest.MapFile{Data: []byte("#  a\n")}
+	}
+
+	// The concurrency of the run overrides the one of the runner.
+	r := runner.NewRunner(runner.WithConcurrency(4), runner.WithStderr(io.Discard))
+	progress := &concurrencyProgress{}
+	if _, err := r.Run(context.Background(), runner.RunArgs{
+		Patterns:    []string{"."},
+		FS:          fsys,
+		Concurrency: 1,
+		Stdout:      io.Discard,
+		Progress:    progress,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if progress.max != 1 {
+		t.Errorf("got %d files formatted concurrently, want 1", progress.max)
+	}
+}
+
+// concurrencyProgress records the maximum number of files formatted at once.
+type concurrencyProgress struct {
+	mu       sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (p *concurrencyProgress) Discovered(int) {}
+
+func (p *concurrencyProgress) Started(string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inFlight++
+	p.max = max(p.max, p.inFlight)
+	// Give other files the chance to start.
+	time.Sleep(10 * time.Millisecond)
+}
+
+func (p *concurrencyProgress) Completed(string, runner.FileStatus) { p.done() }
+func (p *concurrencyProgress) Failed(string, error)                { p.done() }
+
+func (p *concurrencyProgress) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inFlight--
+}
+
+func TestJournalResume(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"a.md": {Data: []byte("#  a\n")},
+		"b.md": {Data: []byte("#  b\n")},
+		"c.md": {Data: []byte("#  c\n")},
+		"d.md": {Data: []byte("#  d\n")},
+	}
+	journal := filepath.Join(t.TempDir(), "journal")
+
+	r := runner.NewRunner(runner.WithStderr(io.Discard))
+	run := func(ctx context.Context, resume bool, onFile func()) ([]string, error) {
+		var mu sync.Mutex
+		var written []string
+		_, err := r.Run(ctx, runner.RunArgs{
+			Patterns: []string{"."},
+			FS:       fsys,
+			Write:    true,
+			Journal:  journal,
+			Resume:   resume,
+			// Process files in order so the interrupted files are known.
+			Concurrency: 1,
+			WriteFile: func(path string, _ []byte) error {
+				mu.Lock()
+				defer mu.Unlock()
+				written = append(written, path)
+				return nil
+			},
+			OnFileResult: func(string, runner.FileStatus, error) {
+				onFile()
+			},
+		})
+		return written, err
+	}
+
+	// Interrupt the run after two files.
+	ctx, cancel := context.WithCancel(context.Background())
+	numFiles := 0
+	written, err := run(ctx, false, func() {
+		numFiles++
+		if numFiles == 2 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want context.Canceled", err)
+	}
+	if want := []string{"a.md", "b.md"}; !slices.Equal(written, want) {
+		t.Fatalf("got written files %v, want %v", written, want)
+	}
+	if _, err := os.Stat(journal); err != nil {
+		t.Fatalf("journal of interrupted run: %v", err)
+	}
+
+	written, err = run(context.Background(), true, func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"c.md", "d.md"}; !slices.Equal(written, want) {
+		t.Errorf("got written files on resume %v, want %v", written, want)
+	}
+	if _, err := os.Stat(journal); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got journal after completed run, err %v, want removed", err)
+	}
+}
+
+func TestOutDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.md":     "# a\n",
+		"sub/b.md": "#  b\n",
+	})
+
+	outDir := t.TempDir()
+	r := runner.NewRunner()
+	if _, err := r.Run(context.Background(), runner.RunArgs{Patterns: []string{"."}, Dir: dir, OutDir: outDir}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"a.md": "# a\n", "sub/b.md": "# b\n"}
+	for p, w := range want {
+		got, err := os.ReadFile(filepath.Join(outDir, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != w {
+			t.Errorf("%s: got: %q, want: %q", p, got, w)
+		}
+	}
+	// Files are left in place.
+	if got, _ := os.ReadFile(filepath.Join(dir, "sub", "b.md")); string(got) != "#  b\n" {
+		t.Errorf("got %q in place, want unchanged", got)
+	}
+}
+
+func TestCaptureRepro(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	// Paths that would be the same if flattened are captured separately.
+	writeFiles(t, dir, map[string]string{
+		"a/b.js": "a(\n",
+		"a_b.js": "b(\n",
+		"c.md":   "# c\n",
+	})
+	reproDir := t.TempDir()
+
+	r := runner.NewRunner(runner.WithStderr(io.Discard))
+	if _, err := r.Run(context.Background(), runner.RunArgs{
+		Patterns:        []string{"."},
+		Dir:             dir,
+		Check:           true,
+		CaptureReproDir: reproDir,
+		Options:         map[string]any{"semi": false},
+		Stdout:          io.Discard,
+	}); err == nil {
+		t.Fatal("expected syntax errors to fail the run")
+	}
+
+	for path, input := range map[string]string{"a/b.js": "a(\n", "a_b.js": "b(\n"} {
+		files := map[string]string{}
+		repro := filepath.Join(reproDir, filepath.FromSlash(path)+".repro")
+		entries, err := os.ReadDir(repro)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		for _, e := range entries {
+			b, err := os.ReadFile(filepath.Join(repro, e.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[e.Name()] = string(b)
+		}
+		if got := files["input.js"]; got != input {
+			t.Errorf("%s: got input %q, want %q", path, got, input)
+		}
+		var opts map[string]any
+		if err := json.Unmarshal([]byte(files["options.json"]), &opts); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if opts["filepath"] != filepath.FromSlash(path) || opts["semi"] != false {
+			t.Errorf("%s: got options %v", path, opts)
+		}
+		if !strings.HasPrefix(files["version.txt"], "prettier ") {
+			t.Errorf("%s: got version %q", path, files["version.txt"])
+		}
+		if !strings.Contains(files["stderr.txt"], "Unexpected token") {
+			t.Errorf("%s: got stderr %q, want syntax error", path, files["stderr.txt"])
+		}
+	}
+	// Formatted files are not captured.
+	if _, err := os.Stat(filepath.Join(reproDir, "c.md.repro")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v for formatted file, want not captured", err)
+	}
+}
+
+func TestFSWithOSOutputs(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{"a.md": {Data: []byte("#  a\n")}}
+	outDir := t.TempDir()
+
+	tests := []struct {
+		name string
+		args runner.RunArgs
+	}{
+		{name: "cache", args: runner.RunArgs{Cache: true, Check: true}},
+		{name: "out dir", args: runner.RunArgs{OutDir: outDir}},
+		{name: "capture repro", args: runner.RunArgs{CaptureReproDir: outDir}},
+	}
+	for _, tc := range tests {
+		args := tc.args
+		args.Patterns = []string{"a.md"}
+		args.FS = fsys
+		args.Stdout = io.Discard
+		r := runner.NewRunner(runner.WithStderr(io.Discard))
+		if _, err := r.Run(context.Background(), args); err == nil || !strings.Contains(err.Error(), "can't be used with FS") {
+			t.Errorf("%s: got error %v, want it rejected", tc.name, err)
+		}
+	}
+	if entries, _ := os.ReadDir(outDir); len(entries) != 0 {
+		t.Errorf("got %d files written to the OS filesystem, want none", len(entries))
+	}
+}
+
+func TestDefaultIgnorePaths(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		".gitignore":      {Data: []byte("c.json\n")},
+		".prettierignore": {Data: []byte("b.json\n")},
+		"a.json":          {Data: []byte(`{"a":[1]}`)},
+		"b.json":          {Data: []byte(`{"b":[1]}`)},
+		"c.json":          {Data: []byte(`{"c":[1]}`)},
+	}
+
+	tests := []struct {
+		name        string
+		ignorePaths []string
+		noGitIgnore bool
+		want        int
+	}{
+		{name: "default", want: 1},
+		{name: "no gitignore", noGitIgnore: true, want: 2},
+		{name: "disabled", ignorePaths: []string{}, want: 3},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := runner.NewRunner()
+			res, err := r.Run(context.Background(), runner.RunArgs{
+				Patterns:    []string{"."},
+				IgnorePaths: tc.ignorePaths,
+				NoGitIgnore: tc.noGitIgnore,
+				FS:          fsys,
+				Check:       true,
+				Stdout:      io.Discard,
+			})
+			if !errors.Is(err, runner.ErrCheckFailed) {
+				t.Fatalf("got error %v, want ErrCheckFailed", err)
+			}
+			if len(res.Files) != tc.want {
+				t.Errorf("got %d results, want %d", len(res.Files), tc.want)
+			}
+		})
+	}
+}
+
+func TestRunFiles(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		".prettierrc":     {Data: []byte("tabWidth: 4\n")},
+		".prettierignore": {Data: []byte("ignored\n")},
+		"ignored/a.json":  {Data: []byte(`{"a":[1]}`)},
+		"b.json":          {Data: []byte(`{"b":[1]}`)},
+		"c.json":          {Data: []byte(`{"c":[1]}`)},
+	}
+
+	written := map[string]string{}
+	var mu sync.Mutex
+	r := runner.NewRunner()
+	res, err := r.RunFiles(context.Background(), []string{"ignored/a.json", "b.json"}, runner.RunArgs{
+		Patterns:    []string{"c.json"},
+		IgnorePaths: []string{".prettierignore"},
+		FS:          fsys,
+		Write:       true,
+		WriteFile: func(path string, content []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			written[path] = string(content)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ignore files and Patterns don't apply to the files.
+	want := map[string]string{
+		"ignored/a.json": "{ \"a\": [1] }\n",
+		"b.json":         "{ \"b\": [1] }\n",
+	}
+	if !maps.Equal(written, want) {
+		t.Errorf("got: %v, want: %v", written, want)
+	}
+	if len(res.Files) != 2 {
+		t.Errorf("got %d results, want 2", len(res.Files))
+	}
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	write := func(files map[string]string) {
+		t.Helper()
+		writeFiles(t, dir, files)
+		for p := range files {
+			if err := os.Chtimes(filepath.Join(dir, p), modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	write(map[string]string{
+		"a.md": "# a\n",
+		"b.md": "#  b\n",
+	})
+	args := runner.RunArgs{
+		Patterns:      []string{"."},
+		Dir:           dir,
+		Check:         true,
+		Cache:         true,
+		CacheLocation: filepath.Join(t.TempDir(), "cache"),
+		CacheStrategy: runner.CacheStrategyMetadata,
+		Stdout:        io.Discard,
+	}
+
+	r := runner.NewRunner()
+	if _, err := r.Run(context.Background(), args); !errors.Is(err, runner.ErrCheckFailed) {
+		t.Fatalf("got: %v, want: ErrCheckFailed", err)
+	}
+
+	// With the same size and modification time, a.md is assumed to be
+	// unchanged and skipped, so the check passes.
+	write(map[string]string{
+		"a.md": "#  a",
+		"b.md": "# b\n",
+	})
+	if _, err := r.Run(context.Background(), args); err != nil {
+		t.Errorf("expected cached check to pass, got: %v", err)
+	}
+
+	// A different module invalidates the cache. Custom sections are ignored
+	// when running the module, so appending one changes the module without
+	// changing how it formats.
+	custom := append(slices.Clip(wasm.Prettier), 0x00, 0x06, 0x04, 't', 'e', 's', 't', 0x00)
+	other := runner.NewRunner(runner.WithWasm(custom))
+	defer other.Close(context.Background())
+	if _, err := other.Run(context.Background(), args); !errors.Is(err, runner.ErrCheckFailed) {
+		t.Errorf("got: %v with a different module, want: ErrCheckFailed", err)
+	}
+
+	// The content strategy detects the change.
+	args.CacheStrategy = runner.CacheStrategyContent
+	if _, err := r.Run(context.Background(), args); !errors.Is(err, runner.ErrCheckFailed) {
+		t.Errorf("got: %v, want: ErrCheckFailed", err)
+	}
+}
+
+func TestRunFS(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		".prettierrc":      {Data: []byte("tabWidth: 4\n")},
+		".prettierignore":  {Data: []byte("ignored\n*.min.js\n")},
+		"src/a.min.js":     {Data: []byte("function hello() { return 'world' }")},
+		"src/test.json":    {Data: []byte(`{"name":"test"}`)},
+		"src/test.ts":      {Data: []byte("function hello() { return 'world' }")},
+		"ignored/test.css": {Data: []byte(".animal{color:red}")},
+	}
+
+	written := map[string]string{}
+	var mu sync.Mutex
+
+	r := runner.NewRunner()
+
+	if _, err := r.Run(context.Background(), runner.RunArgs{
+		Patterns:    []string{"."},
+		IgnorePaths: []string{".prettierignore"},
+		Write:       true,
+		FS:          fsys,
+		WriteFile: func(path string, content []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			written[path] = string(content)
+			return nil
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		// prettier infers YAML for .prettierrc so it is formatted as well.
+		".prettierrc":   "tabWidth: 4\n",
+		"src/test.json": "{ \"name\": \"test\" }\n",
+		"src/test.ts":   "function hello() {\n    return \"world\";\n}\n",
+	}
+	if !maps.Equal(written, want) {
+		t.Errorf("got: %v, want: %v", written, want)
+	}
+
+	var stdout bytes.Buffer
+	_, err := r.Run(context.Background(), runner.RunArgs{
+		Patterns: []string{"src/*.ts"},
+		Check:    true,
+		FS:       fsys,
+		Stdout:   &stdout,
+	})
+	if err == nil {
+		t.Error("expected check to fail")
+	}
+	if want := "Checking formatting...\n"; stdout.String() != want {
+		t.Errorf("got: %q, want: %q", stdout.String(), want)
+	}
+}
+
+func TestDeterministicRunner(t *testing.T) {
+	t.Parallel()
+
+	r := runner.NewDeterministicRunner()
+
+	in, _ := testFiles.ReadFile("testdata/in/test.ts")
+	want, _ := outFiles.ReadFile("testdata/out/test.ts")
+
+	for i := 0; i < 2; i++ {
+		got, err := r.Format(context.Background(), "test.ts", in, map[string]any{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("got: %s, want: %s", got, want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	in, _ := testFiles.ReadFile("testdata/in/test.ts")
+	want, _ := outFilesTabWidth4.ReadFile("testdata/outtabwidth4/test.ts")
+
+	got, err := Format(context.Background(), "test.ts", in, Options{TabWidth: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("got: %s, want: %s", got, want)
+	}
+
+	if _, err := Format(context.Background(), "test.ts", in, Options{}); err != nil {
+		t.Errorf("empty options: %v", err)
+	}
+
+	if _, err := Format(context.Background(), "test.ts", in, Options{TrailingComma: "some"}); err == nil {
+		t.Error("expected error for invalid trailingComma")
+	}
+
+	if Default() != Default() {
+		t.Error("expected Default to return the shared runner")
+	}
+}
+
+func TestFormatAll(t *testing.T) {
+	t.Parallel()
+
+	files := map[string][]byte{
+		"a.json": []byte(`{"a":1}`),
+		"b.md":   []byte("#  b\n"),
+		"c.ts":   []byte("const  c  =  1"),
+	}
+	got, err := FormatAll(context.Background(), files, Options{TabWidth: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"a.json": "{ \"a\": 1 }\n",
+		"b.md":   "# b\n",
+		"c.ts":   "const c = 1;\n",
+	}
+	for path, w := range want {
+		if string(got[path]) != w {
+			t.Errorf("%s: got: %q, want: %q", path, got[path], w)
+		}
+	}
+
+	files["d.js"] = []byte("function {")
+	if _, err := FormatAll(context.Background(), files, Options{}); !errors.Is(err, ErrParse) || !strings.HasPrefix(err.Error(), "d.js: ") {
+		t.Errorf("got: %v, want parse error of d.js", err)
+	}
+}
+
+func TestFormatRange(t *testing.T) {
+	t.Parallel()
+
+	in := "a  =  \"😀\";\nb  =  2;\n"
+	start := strings.Index(in, "b")
+
+	got, err := FormatRange(context.Background(), "test.js", []byte(in), start, len(in), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a  =  \"😀\";\nb = 2;\n"; string(got) != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	if _, err := FormatRange(context.Background(), "test.js", []byte(in), start, len(in)+1, Options{}); err == nil {
+		t.Error("expected error for range past the end")
+	}
+}
+
+func TestFormatWithCursor(t *testing.T) {
+	t.Parallel()
+
+	// The cursor is marked with | in the input and the output.
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "unchanged line",
+			in:   "a = |1;\nb  =  2;\n",
+			want: "a = |1;\nb = 2;\n",
+		},
+		{
+			name: "after change",
+			in:   "a  =  1;\n\nb = |2;\n",
+			want: "a = 1;\n\nb = |2;\n",
+		},
+		{
+			name: "changed line",
+			in:   "a = 1;\nb  =  |2;\n",
+			want: "a = 1;\nb = |2;\n",
+		},
+		{
+			name: "whitespace",
+			in:   "a|  =  1;\n",
+			want: "a| = 1;\n",
+		},
+		{
+			name: "changed characters",
+			in:   "a = 'x|';\n",
+			want: "a = \"x|\";\n",
+		},
+		{
+			name: "multibyte",
+			in:   "a  =  \"😀|\";\n",
+			want: "a = \"😀|\";\n",
+		},
+		{
+			name: "start",
+			in:   "|a  =  1;\n",
+			want: "|a = 1;\n",
+		},
+		{
+			name: "end",
+			in:   "a  =  1|",
+			want: "a = 1;\n|",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cursor := strings.Index(tc.in, "|")
+			in := strings.Replace(tc.in, "|", "", 1)
+			out, got, err := FormatWithCursor(context.Background(), "test.js", []byte(in), cursor, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(out[:got]) + "|" + string(out[got:]); got != tc.want {
+				t.Errorf("got: %q, want: %q", got, tc.want)
+			}
+		})
+	}
+
+	if _, _, err := FormatWithCursor(context.Background(), "test.js", []byte("a = 1;\n"), 8, Options{}); err == nil {
+		t.Error("expected error for cursor past the end")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	r := runner.NewRunner()
+
+	got, err := r.Diff(context.Background(), "test.md", []byte("#  a\n\nb\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--- test.md\n+++ test.md\n@@ -1,3 +1,3 @@\n-#  a\n+# a\n \n b\n"
+	if got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	got, err = r.Diff(context.Background(), "test.md", []byte("# a\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "" {
+		t.Errorf("expected empty diff for formatted file, got: %q", got)
+	}
+}
+
+func TestOptionsMap(t *testing.T) {
+	t.Parallel()
+
+	opts := Options{
+		TabWidth: 4,
+		Semi:     Bool(false),
+		Parser:   "babel",
+		Extra:    map[string]any{"objectWrap": "collapse", "tabWidth": 8},
+	}
+	want := "map[objectWrap:collapse parser:babel semi:false tabWidth:4]"
+	if got := fmt.Sprint(opts.Map()); got != want {
+		t.Errorf("got: %s, want: %s", got, want)
+	}
+}
+
+func TestFormatReader(t *testing.T) {
+	t.Parallel()
+
+	in, _ := testFiles.ReadFile("testdata/in/test.ts")
+	want, _ := outFiles.ReadFile("testdata/out/test.ts")
+
+	r := NewDeterministicRunner()
+
+	var out bytes.Buffer
+	if err := r.FormatReader(context.Background(), "test.ts", bytes.NewReader(in), &out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != string(want) {
+		t.Errorf("got: %s, want: %s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := r.FormatReader(context.Background(), "test.js", strings.NewReader("function {"), &out, nil); err == nil {
+		t.Error("expected error for invalid source")
+	}
+	if out.Len() != 0 {
+		t.Errorf("got output for invalid source: %s", out.String())
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	t.Parallel()
+
+	r := NewDeterministicRunner()
+
+	_, err := r.Format(context.Background(), "test.unknown", []byte("foo"), map[string]any{})
+	if !errors.Is(err, ErrUnknownParser) {
+		t.Errorf("got: %v, want: ErrUnknownParser", err)
+	}
+
+	_, err = r.Format(context.Background(), "test.js", []byte("function {"), map[string]any{})
+	var ee *EngineError
+	if !errors.As(err, &ee) {
+		t.Fatalf("got: %v, want: EngineError", err)
+	}
+	if ee.ExitCode != 1 || ee.Stderr == "" {
+		t.Errorf("got exit code %d and stderr %q", ee.ExitCode, ee.Stderr)
+	}
+	if !errors.Is(err, ErrParse) {
+		t.Errorf("got: %v, want: ErrParse", err)
+	}
+
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"a.md":         {Data: []byte("#  a\n")},
+		"invalid.json": {Data: []byte("{")},
+	}
+	if _, err := r.Run(ctx, RunArgs{Patterns: []string{"a.md"}, FS: fsys, Check: true}); !errors.Is(err, ErrCheckFailed) {
+		t.Errorf("got: %v, want: ErrCheckFailed", err)
+	}
+	if _, err := r.Run(ctx, RunArgs{Patterns: []string{"a.md"}, FS: fsys, Config: "invalid.json"}); !errors.Is(err, ErrConfigInvalid) {
+		t.Errorf("got: %v, want: ErrConfigInvalid", err)
+	}
+	if _, err := r.Run(ctx, RunArgs{Patterns: []string{"a.md"}, FS: fsys, Config: "missing.json"}); !errors.Is(err, ErrUnreadable) {
+		t.Errorf("got: %v, want: ErrUnreadable", err)
+	}
+}
+
+func TestEmbeddedLanguageFormatting(t *testing.T) {
+	t.Parallel()
+
+	src := "#  a\n\n```css\na{color:red}\n```\n"
+
+	tests := []struct {
+		formatting string
+		want       string
+	}{
+		{
+			formatting: "auto",
+			want:       "# a\n\n```css\na {\n  color: red;\n}\n```\n",
+		},
+		{
+			formatting: "off",
+			want:       "# a\n\n```css\na{color:red}\n```\n",
+		},
+	}
+
+	r := NewRunner(WithStderr(io.Discard))
+
+	for _, tc := range tests {
+		t.Run(tc.formatting, func(t *testing.T) {
+			t.Parallel()
+
+			var stdout bytes.Buffer
+			if _, err := r.Run(context.Background(), RunArgs{
+				Patterns: []string{"."},
+				FS:       fstest.MapFS{"a.md": {Data: []byte(src)}},
+				Options:  map[string]any{"embeddedLanguageFormatting": tc.formatting},
+				Stdout:   &stdout,
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if got := stdout.String(); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsIgnored(t *testing.T) {
+	t.Parallel()
+
+	args := runner.RunArgs{
+		Patterns:    []string{"src", "!src/skip.js"},
+		IgnorePaths: []string{".prettierignore"},
+		FS: fstest.MapFS{
+			".prettierignore":     {Data: []byte("# generated\nbuild\n*.min.js\n!keep.min.js\n")},
+			"build/out.js":        {},
+			"gen/a.js":            {},
+			"node_modules/dep.js": {},
+			"pkg/.prettierignore": {Data: []byte("/gen\n!b.min.js\n")},
+			"pkg/gen/a.js":        {},
+			"pkg/b.min.js":        {},
+			"pkg/c.min.js":        {},
+			"src/a.js":            {},
+			"src/a.min.js":        {},
+			"src/keep.min.js":     {},
+			"src/skip.js":         {},
+		},
+	}
+
+	tests := []struct {
+		path    string
+		ignored bool
+		rule    string
+	}{
+		{path: "src/a.js"},
+		{path: "node_modules/dep.js", ignored: true, rule: "node_modules (default)"},
+		{path: "build/out.js", ignored: true, rule: ".prettierignore:2: build"},
+		{path: "src/a.min.js", ignored: true, rule: ".prettierignore:3: *.min.js"},
+		{path: "src/keep.min.js", ignored: false, rule: ".prettierignore:4: !keep.min.js"},
+		{path: "src/skip.js", ignored: true, rule: "!src/skip.js (pattern)"},
+		{path: "gen/a.js"},
+		{path: "pkg/gen/a.js", ignored: true, rule: "pkg/.prettierignore:1: /gen"},
+		{path: "pkg/b.min.js", ignored: false, rule: "pkg/.prettierignore:2: !b.min.js"},
+		{path: "pkg/c.min.js", ignored: true, rule: ".prettierignore:3: *.min.js"},
+	}
+
+	r := runner.NewRunner()
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			ignored, rule := r.IsIgnored(args, tc.path)
+			if ignored != tc.ignored || rule != tc.rule {
+				t.Errorf("got: (%v, %q), want: (%v, %q)", ignored, rule, tc.ignored, tc.rule)
+			}
+		})
+	}
+}
+
+func TestFileInfo(t *testing.T) {
+	t.Parallel()
+
+	args := runner.RunArgs{
+		IgnorePaths: []string{".prettierignore"},
+		FS: fstest.MapFS{
+			".prettierignore": {Data: []byte("build\n")},
+			".prettierrc":     {Data: []byte(`{"overrides": [{"files": "*.tpl", "options": {"parser": "html"}}]}`)},
+			"a.md":            {},
+			"build/out.js":    {},
+		},
+		Overrides: []runner.OptionsOverride{{Pattern: "*.mdx.txt", Options: map[string]any{"parser": "mdx"}}},
+	}
+
+	tests := []struct {
+		path string
+		want FileInfo
+	}{
+		{path: "a.md", want: FileInfo{InferredParser: "markdown"}},
+		{path: "build/out.js", want: FileInfo{Ignored: true, InferredParser: "babel"}},
+		{path: "src/b.TS", want: FileInfo{InferredParser: "typescript"}},
+		{path: "app.component.html", want: FileInfo{InferredParser: "angular"}},
+		{path: "index.html", want: FileInfo{InferredParser: "html"}},
+		{path: "package.json", want: FileInfo{InferredParser: "json-stringify"}},
+		{path: "tsconfig.json", want: FileInfo{InferredParser: "json"}},
+		{path: ".prettierrc", want: FileInfo{InferredParser: "yaml"}},
+		{path: "README", want: FileInfo{InferredParser: "markdown"}},
+		{path: "a.txt", want: FileInfo{}},
+		// Parsers set by overrides take precedence.
+		{path: "b.tpl", want: FileInfo{InferredParser: "html"}},
+		{path: "c.mdx.txt", want: FileInfo{InferredParser: "mdx"}},
+	}
+
+	r := runner.NewRunner()
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			got, err := r.FileInfo(context.Background(), args, tc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got: %+v, want: %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSupportInfo(t *testing.T) {
+	t.Parallel()
+
+	r := runner.NewRunner()
+
+	info, err := r.SupportInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, l := range info.Languages {
+		names = append(names, l.Name)
+		for _, p := range l.Parsers {
+			if !slices.Contains(runner.Parsers, p) {
+				t.Errorf("%s: parser %q not in the module", l.Name, p)
+			}
+		}
+	}
+	want := []string{
+		"JavaScript", "Flow", "JSX", "TypeScript", "TSX", "JSON.stringify", "JSON", "JSON with Comments", "JSON5",
+		"Handlebars", "Angular", "HTML", "Lightning Web Components", "Vue", "GraphQL", "Markdown", "MDX", "CSS",
+		"PostCSS", "Less", "SCSS", "YAML",
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("got languages %v, want %v", names, want)
+	}
+
+	// Every field of Options is described.
+	b, err := json.Marshal(Options{
+		PrintWidth: 1, TabWidth: 1, UseTabs: new(bool), Semi: new(bool), SingleQuote: new(bool), QuoteProps: "x",
+		JSXSingleQuote: new(bool), TrailingComma: "x", BracketSpacing: new(bool), BracketSameLine: new(bool),
+		ArrowParens: "x", Parser: "x", ProseWrap: "x", HTMLWhitespaceSensitivity: "x",
+		VueIndentScriptAndStyle: new(bool), EndOfLine: "x", EmbeddedLanguageFormatting: "x",
+		SingleAttributePerLine: new(bool), ExperimentalTernaries: new(bool),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	var options []string
+	for _, o := range info.Options {
+		options = append(options, o.Name)
+	}
+	for name := range fields {
+		if !slices.Contains(options, name) {
+			t.Errorf("option %s not described", name)
+		}
+	}
+
+	// The returned info can be modified.
+	info.Languages[0].Extensions[0] = ".modified"
+	info2, err := r.SupportInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info2.Languages[0].Extensions[0]; got != ".js" {
+		t.Errorf("got extension %q after modifying info, want .js", got)
+	}
+
+	// Modules passed to WithWasm are described like the embedded one.
+	custom := append(slices.Clip(wasm.Prettier), 0x00, 0x06, 0x04, 't', 'e', 's', 't', 0x00)
+	other := runner.NewRunner(runner.WithWasm(custom))
+	defer other.Close(context.Background())
+	info3, err := other.SupportInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info3, info2) {
+		t.Errorf("got %+v for a module passed to WithWasm, want the embedded languages and options", info3)
+	}
+}
+
+func TestPresets(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		".prettierrc": {Data: []byte(`tabWidth: 4
+presets:
+  docs:
+    proseWrap: always
+  strict:
+    tabWidth: 2
+    semi: false
+`)},
+	}
+
+	tests := []struct {
+		presets []string
+		want    map[string]any
+		err     bool
+	}{
+		{want: map[string]any{"tabWidth": 4}},
+		{presets: []string{"docs"}, want: map[string]any{"tabWidth": 4, "proseWrap": "always"}},
+		{presets: []string{"docs", "strict"}, want: map[string]any{"tabWidth": 2, "proseWrap": "always", "semi": false}},
+		{presets: []string{"missing"}, err: true},
+	}
+
+	r := runner.NewRunner()
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprint(tc.presets), func(t *testing.T) {
+			got, _, err := r.Expand(context.Background(), runner.RunArgs{FS: fsys, Presets: tc.presets})
+			if tc.err {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("got: %v, want: %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConfigExpandEnv(t *testing.T) {
+	t.Setenv("PRETTIER_TEST_WIDTH", "100")
+
+	fsys := fstest.MapFS{
+		".prettierrc": {Data: []byte(`{"printWidth": ${PRETTIER_TEST_WIDTH}, "tabWidth": ${PRETTIER_TEST_UNSET:-4}, "quoteProps": "$KEEP"}`)},
+	}
+
+	r := runner.NewRunner()
+
+	got, _, err := r.Expand(context.Background(), runner.RunArgs{FS: fsys, ConfigExpandEnv: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"printWidth": 100, "tabWidth": 4, "quoteProps": "$KEEP"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"a.json":     {Data: []byte("{ \"a\": 1 }\n")},
+		"b.json":     {Data: []byte(`{"b":2}`)},
+		"broken.js":  {Data: []byte("const = ;")},
+		"unknown.zz": {Data: []byte("x")},
+	}
+
+	var report bytes.Buffer
+	_, err := runner.NewRunner().Run(context.Background(), runner.RunArgs{
+		Patterns:     []string{"a.json", "b.json", "broken.js", "unknown.zz"},
+		Check:        true,
+		FS:           fsys,
+		Stdout:       io.Discard,
+		Report:       &report,
+		ReportFormat: runner.ReportFormatJSON,
+	})
+	if err == nil {
+		t.Error("expected check to fail")
+	}
+
+	var got struct {
+		Files []struct {
+			Path   string `json:"path"`
+			Status string `json:"status"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(report.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := "[{a.json formatted} {b.json unformatted} {broken.js error} {unknown.zz skipped}]"
+	if fmt.Sprint(got.Files) != want {
+		t.Errorf("got: %v, want: %v", got.Files, want)
+	}
+
+	var locs struct {
+		Files []struct {
+			Line   int `json:"line"`
+			Column int `json:"column"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(report.Bytes(), &locs); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(locs.Files), "[{0 0} {0 0} {1 7} {0 0}]"; got != want {
+		t.Errorf("got locations: %v, want: %v", got, want)
+	}
+}
+
+func TestReportSARIF(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"a.js":      {Data: []byte("const a = 1;\nlet   b=2\nconst c = 3;\nlet   d=4\n")},
+		"broken.js": {Data: []byte("const = ;")},
+	}
+
+	var report bytes.Buffer
+	_, err := runner.NewRunner().Run(context.Background(), runner.RunArgs{
+		Patterns:     []string{"a.js", "broken.js"},
+		Check:        true,
+		FS:           fsys,
+		Stdout:       io.Discard,
+		Report:       &report,
+		ReportFormat: runner.ReportFormatSARIF,
+	})
+	if err == nil {
+		t.Error("expected check to fail")
+	}
+
+	var got struct {
+		Runs []struct {
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine   int `json:"startLine"`
+							StartColumn int `json:"startColumn"`
+							EndLine     int `json:"endLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(report.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	var results []string
+	for _, r := range got.Runs[0].Results {
+		l := r.Locations[0].PhysicalLocation
+		results = append(results, fmt.Sprintf("%s %s:%d:%d-%d", r.RuleID, l.ArtifactLocation.URI, l.Region.StartLine, l.Region.StartColumn, l.Region.EndLine))
+	}
+	want := "[unformatted a.js:2:0-2 unformatted a.js:4:0-4 error broken.js:1:7-0]"
+	if fmt.Sprint(results) != want {
+		t.Errorf("got: %v, want: %v", results, want)
+	}
+}
+
+func TestPrewarm(t *testing.T) {
+	t.Parallel()
+
+	if err := runner.NewRunner().Prewarm(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShards(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{}
+	for i := 0; i < 20; i++ {
+		fsys[fmt.Sprintf("dir/file%d.js", i)] = &fstest.MapFile{}
+	}
+
+	r := runner.NewRunner()
+
+	seen := map[string]int{}
+	for i := 1; i <= 3; i++ {
+		_, paths, err := r.Expand(context.Background(), runner.RunArgs{
+			Patterns:   []string{"dir"},
+			FS:         fsys,
+			ShardIndex: i,
+			ShardCount: 3,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(paths) == 0 {
+			t.Errorf("shard %d is empty", i)
+		}
+		for _, p := range paths {
+			seen[p.FilePath]++
+		}
+	}
+
+	if len(seen) != len(fsys) {
+		t.Errorf("got %d files across shards, want %d", len(seen), len(fsys))
+	}
+	for p, n := range seen {
+		if n != 1 {
+			t.Errorf("%s is in %d shards", p, n)
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"top.md":           {},
+		"docs/a.md":        {},
+		"docs/deep/b.md":   {},
+		"docs/deep/x/c.md": {},
+	}
+
+	tests := []struct {
+		maxDepth int
+		want     []string
+	}{
+		{maxDepth: 1, want: []string{"top.md"}},
+		{maxDepth: 2, want: []string{"docs/a.md", "top.md"}},
+		{maxDepth: 0, want: []string{"docs/a.md", "docs/deep/b.md", "docs/deep/x/c.md", "top.md"}},
+	}
+
+	r := runner.NewRunner()
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprint(tc.maxDepth), func(t *testing.T) {
+			_, paths, err := r.Expand(context.Background(), runner.RunArgs{Patterns: []string{"."}, FS: fsys, MaxDepth: tc.maxDepth})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, p := range paths {
+				got = append(got, p.FilePath)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("got: %v, want: %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"a.md": {Data: []byte("# a\n")},
+		"b.md": {Data: []byte("# b\n")},
+	}
+
+	r := runner.NewRunner()
+	_, err := r.Run(context.Background(), runner.RunArgs{Patterns: []string{"."}, FS: fsys, Check: true, Timeout: time.Nanosecond, Stdout: io.Discard})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got: %v, want: deadline exceeded", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	t.Parallel()
+
+	// Large enough to take much longer than the cancellation to format.
+	src := []byte(strings.Repeat("const  a  =  [1,2,3];\n", 50000))
+
+	r := runner.NewRunner()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := r.Format(ctx, "test.js", src, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got: %v, want: deadline exceeded", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("formatting was not interrupted, took %v", d)
+	}
+}
+
+func TestFileTimeout(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"a.md": {Data: []byte("# a\n")},
+		"b.md": {Data: []byte("# b\n")},
+	}
+
+	r := runner.NewRunner()
+	res, err := r.Run(context.Background(), runner.RunArgs{Patterns: []string{"."}, FS: fsys, Check: true, FileTimeout: time.Nanosecond, Stdout: io.Discard})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got: %v, want: deadline exceeded", err)
+	}
+	// Each file times out without stopping the run.
+	if len(res.Files) != 2 {
+		t.Fatalf("got %d results, want 2", len(res.Files))
+	}
+	for _, f := range res.Files {
+		if f.Status != runner.StatusError {
+			t.Errorf("%s: got status %s, want %s", f.Path, f.Status, runner.StatusError)
+		}
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{}
+	for i := 0; i < 4; i++ {
+		fsys[fmt.Sprintf("%d.md", i)] = &fstest.MapFile{Data: []byte("#  heading\n")}
+	}
+
+	var mu sync.Mutex
+	written := map[string]string{}
+	r := runner.NewRunner()
+	// A limit that is always exceeded formats files one at a time.
+	_, err := r.Run(context.Background(), runner.RunArgs{
+		Patterns:    []string{"."},
+		FS:          fsys,
+		Write:       true,
+		MemoryLimit: 1,
+		WriteFile: func(path string, content []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			written[path] = string(content)
+			return nil
+		},
+		Stdout: io.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != len(fsys) {
+		t.Errorf("got %d files written, want %d", len(written), len(fsys))
+	}
+}
+
+func TestConfigDiscovery(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  map[string]any
+	}{
+		{
+			name: "package.json",
+			files: fstest.MapFS{
+				"package.json": {Data: []byte(`{"name": "test", "prettier": {"tabWidth": 4}}`)},
+				".prettierrc":  {Data: []byte("tabWidth: 8\n")},
+			},
+			want: map[string]any{"tabWidth": 4},
+		},
+		{
+			name: "package.json without prettier key",
+			files: fstest.MapFS{
+				"package.json": {Data: []byte(`{"name": "test"}`)},
+				".prettierrc":  {Data: []byte("tabWidth: 8\n")},
+			},
+			want: map[string]any{"tabWidth": 8},
+		},
+		{
+			name: "json5",
+			files: fstest.MapFS{
+				".prettierrc.json5": {Data: []byte(`{
+  // Comments are allowed.
+  tabWidth: 4, /* Inline too. */
+  singleQuote: true, // With trailing commas.
+}
+`)},
+			},
+			want: map[string]any{"tabWidth": 4, "singleQuote": true},
+		},
+		{
+			name: "toml after json5",
+			files: fstest.MapFS{
+				".prettierrc.json5": {Data: []byte(`{tabWidth: 4}`)},
+				".prettierrc.toml":  {Data: []byte("tabWidth = 8\n")},
+			},
+			want: map[string]any{"tabWidth": 4},
+		},
+	}
+
+	r := runner.NewRunner()
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pCfg, _, err := r.Expand(context.Background(), runner.RunArgs{FS: tc.files})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(pCfg) != fmt.Sprint(tc.want) {
+				t.Errorf("got: %v, want: %v", pCfg, tc.want)
+			}
+		})
+	}
+
+	t.Run("javascript", func(t *testing.T) {
+		_, _, err := r.Expand(context.Background(), runner.RunArgs{FS: fstest.MapFS{
+			"prettier.config.js": {Data: []byte("export default {};\n")},
+			".prettierrc.toml":   {Data: []byte("tabWidth = 8\n")},
+		}})
+		if err == nil {
+			t.Error("expected error for JavaScript config file")
+		}
+	})
+}
+
+func TestConfigConflicts(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	files := map[string]string{
+		".prettierrc.json":     `{"tabWidth": 8}`,
+		".prettierrc.toml":     "tabWidth = 4\n",
+		"sub/.prettierrc":      "tabWidth: 4\n",
+		"sub/.prettierrc.yaml": "semi: false\n",
+		"sub/package.json":     `{"name": "test"}`,
+		"sub/a.md":             "# a\n",
+	}
+	for path, content := range files {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stderr bytes.Buffer
+	r := runner.NewRunner(runner.WithStderr(&stderr))
+	if _, _, err := r.Expand(context.Background(), runner.RunArgs{Dir: filepath.Join(root, "sub")}); err != nil {
+		t.Fatal(err)
+	}
+
+	log := stderr.String()
+	for _, want := range []string{
+		`using \".prettierrc\" and ignoring \".prettierrc.yaml\".`,
+		`.prettierrc.json\" is ignored in favor of`,
+		"Conflicting options: tabWidth: 4 (used) vs 8",
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("got log %q, want it to contain %q", log, want)
+		}
+	}
+	// Without conflicting options or a prettier key, the files don't matter.
+	for _, notWant := range []string{".prettierrc.toml", "package.json"} {
+		if strings.Contains(log, notWant) {
+			t.Errorf("got log %q, want it not to contain %q", log, notWant)
+		}
+	}
+}
+
+func TestDelegateToNode(t *testing.T) {
+	t.Parallel()
+
+	t.Run("not installed", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte("#  a\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		r := runner.NewRunner(runner.WithStderr(io.Discard))
+		_, err := r.Run(context.Background(), runner.RunArgs{
+			Patterns:       []string{"."},
+			Dir:            dir,
+			Write:          true,
+			DelegateToNode: true,
+		})
+		if !errors.Is(err, ErrNodeNotFound) {
+			t.Fatalf("got error %v, want ErrNodeNotFound", err)
+		}
+		if b, _ := os.ReadFile(filepath.Join(dir, "a.md")); string(b) != "#  a\n" {
+			t.Errorf("got %q, want file not to be formatted", b)
+		}
+	})
+
+	t.Run("installed", func(t *testing.T) {
+		t.Parallel()
+
+		if runtime.GOOS == "windows" {
+			t.Skip("fake prettier is a shell script")
+		}
+
+		dir := t.TempDir()
+		files := map[string]string{
+			"a.md":  "#  a\n",
+			"b.xyz": "b\n",
+			// Stands in for prettier, which formats files no parser could be
+			// inferred for.
+			"node_modules/.bin/prettier": "#!/bin/sh\ncat > /dev/null\necho node\n",
+		}
+		for path, content := range files {
+			path = filepath.Join(dir, path)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		r := runner.NewRunner(runner.WithStderr(io.Discard))
+		if _, err := r.Run(context.Background(), runner.RunArgs{
+			Patterns:       []string{"a.md", "b.xyz"},
+			Dir:            dir,
+			Write:          true,
+			DelegateToNode: true,
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		for path, want := range map[string]string{"a.md": "# a\n", "b.xyz": "node\n"} {
+			if got, _ := os.ReadFile(filepath.Join(dir, path)); string(got) != want {
+				t.Errorf("%s: got %q, want %q", path, got, want)
+			}
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		t.Parallel()
+
+		if runtime.GOOS == "windows" {
+			t.Skip("fake prettier is a shell script")
+		}
+
+		dir := t.TempDir()
+		files := map[string]string{
+			"a.xyz":       "a\n",
+			".prettierrc": `{"singleQuote": true, "overrides": [{"files": "*.xyz", "options": {"printWidth": 100}}]}`,
+			// Stands in for prettier, printing the flags it is passed.
+			"node_modules/.bin/prettier": "#!/bin/sh\ncat > /dev/null\necho \"$@\"\n",
+		}
+		for path, content := range files {
+			path = filepath.Join(dir, path)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		r := runner.NewRunner(runner.WithStderr(io.Discard))
+		if _, err := r.Run(context.Background(), runner.RunArgs{
+			Patterns:       []string{"a.xyz"},
+			Dir:            dir,
+			Write:          true,
+			DelegateToNode: true,
+			Options:        map[string]any{"semi": false, "tabWidth": 4},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		want := "--stdin-filepath a.xyz --print-width=100 --no-semi --single-quote --tab-width=4\n"
+		if got, _ := os.ReadFile(filepath.Join(dir, "a.xyz")); string(got) != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+
+		// Options that aren't flags of prettier on Node fail the file.
+		if _, err := r.Run(context.Background(), runner.RunArgs{
+			Patterns:       []string{"a.xyz"},
+			Dir:            dir,
+			Write:          true,
+			DelegateToNode: true,
+			Options:        map[string]any{"custom": map[string]any{"a": 1}},
+		}); err == nil {
+			t.Error("got no error for an option that isn't a flag")
+		}
+		if got, _ := os.ReadFile(filepath.Join(dir, "a.xyz")); string(got) != want {
+			t.Errorf("got %q, want the file unchanged", got)
+		}
+	})
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"a":[1]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	r := runner.NewRunner()
+	passes := make(discoveredProgress, 100)
+	go func() {
+		done <- r.Watch(ctx, runner.RunArgs{Patterns: []string{"."}, Dir: dir, Write: true, Stdout: io.Discard, Progress: passes}, 500*time.Millisecond)
+	}()
+
+	waitFor := func(name string, want string) {
+		t.Helper()
+		deadline := time.Now().Add(30 * time.Second)
+		for {
+			got, _ := os.ReadFile(filepath.Join(dir, name))
+			if string(got) == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: got: %q, want: %q", name, got, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	write := func(name string, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitFor("a.json", "{ \"a\": [1] }\n")
+	if got := <-passes; got != 1 {
+		t.Errorf("got %d files in the first pass, want 1", got)
+	}
+
+	// New files are picked up, including in new directories.
+	write("b.json", `{"b":[1]}`)
+	waitFor("b.json", "{ \"b\": [1] }\n")
+	write("sub/c.json", `{"c":[1]}`)
+	waitFor("sub/c.json", "{ \"c\": [1] }\n")
+	write("sub/d.json", `{"d":[1]}`)
+	waitFor("sub/d.json", "{ \"d\": [1] }\n")
+
+	// Changes within the delay are processed in a single pass.
+	for len(passes) > 0 {
+		<-passes
+	}
+	write("a.json", `{"a":[2]}`)
+	write("b.json", `{"b":[2]}`)
+	write("sub/c.json", `{"c":[2]}`)
+	waitFor("sub/c.json", "{ \"c\": [2] }\n")
+	waitFor("a.json", "{ \"a\": [2] }\n")
+	if got := <-passes; got != 3 {
+		t.Errorf("got %d files in the pass after changing 3, want 3", got)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// discoveredProgress receives the number of files of each pass of Watch.
+type discoveredProgress chan int
+
+func (p discoveredProgress) Discovered(total int)                { p <- total }
+func (p discoveredProgress) Started(string)                      {}
+func (p discoveredProgress) Completed(string, runner.FileStatus) {}
+func (p discoveredProgress) Failed(string, error)                {}
+
+func TestDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		".prettierrc":     "tabWidth: 4\n",
+		".prettierignore": "ignored.ts\n",
+		"src/test.ts":     "function a() {\nreturn 1}\n",
+		"ignored.ts":      "function a() {\nreturn 1}\n",
+	}
+	for p, content := range files {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(p)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, p), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := runner.NewRunner()
+	if _, err := r.Run(context.Background(), runner.RunArgs{Patterns: []string{"."}, Dir: dir, Write: true, IgnorePaths: []string{".prettierignore"}, Stdout: io.Discard}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := os.ReadFile(filepath.Join(dir, "src", "test.ts"))
+	if want := "function a() {\n    return 1;\n}\n"; string(got) != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+	got, _ = os.ReadFile(filepath.Join(dir, "ignored.ts"))
+	if want := files["ignored.ts"]; string(got) != want {
+		t.Errorf("ignored file was formatted: %q", got)
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		".prettierrc":        {Data: []byte("tabWidth: 8\n")},
+		"docs/a.ts":          {},
+		"src/a.ts":           {},
+		"src/data.special":   {},
+		"src/nested/b.ts":    {},
+		"docs/nested/c.json": {},
+	}
+
+	r := runner.NewRunner()
+	_, paths, err := r.Expand(context.Background(), runner.RunArgs{
+		Patterns: []string{"."},
+		FS:       fsys,
+		Overrides: []runner.OptionsOverride{
+			{Pattern: "docs/", Options: map[string]any{"tabWidth": 4}},
+			{Pattern: "*.special", Options: map[string]any{"parser": "json"}},
+			{Pattern: "*.json", Options: map[string]any{"tabWidth": 2}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		".prettierrc":        "map[tabWidth:8]",
+		"docs/a.ts":          "map[tabWidth:4]",
+		"docs/nested/c.json": "map[tabWidth:2]",
+		"src/a.ts":           "map[tabWidth:8]",
+		"src/data.special":   "map[parser:json tabWidth:8]",
+		"src/nested/b.ts":    "map[tabWidth:8]",
+	}
+	for _, p := range paths {
+		if got := fmt.Sprint(p.Config(map[string]any{"tabWidth": 8})); got != want[p.FilePath] {
+			t.Errorf("%s: got: %v, want: %v", p.FilePath, got, want[p.FilePath])
+		}
+	}
+}
+
+func TestResolveConfigFile(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		".prettierrc.yaml": {Data: []byte("tabWidth: 4\n")},
+		"sub/a.yaml":       {Data: []byte("a: 1\n")},
+	}
+
+	r := runner.NewRunner()
+	if got, want := r.ResolveConfigFile(runner.RunArgs{FS: fsys}), ".prettierrc.yaml"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+	if got := r.ResolveConfigFile(runner.RunArgs{FS: fsys, NoConfig: true}); got != "" {
+		t.Errorf("got: %q, want no config file", got)
+	}
+}
+
+func TestResolveConfig(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		".prettierrc":   {Data: []byte("tabWidth: 8\nsemi: false\n")},
+		".editorconfig": {Data: []byte("[*]\nindent_style = tab\nmax_line_length = 100\n")},
+	}
+
+	r := runner.NewRunner()
+	got, err := r.ResolveConfig(context.Background(), runner.RunArgs{
+		FS:      fsys,
+		Options: map[string]any{"semi": true},
+		Overrides: []runner.OptionsOverride{
+			{Pattern: "docs/", Options: map[string]any{"tabWidth": 4}},
+		},
+	}, "docs/missing.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "map[printWidth:100 semi:true tabWidth:4 useTabs:true]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestConfigOverrides(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		".prettierrc": {Data: []byte(`{
+			"tabWidth": 8,
+			"overrides": [
+				{"files": ["*.tpl", "*.tmpl"], "excludeFiles": "vendor/**", "options": {"parser": "html", "tabWidth": 4}},
+				{"files": "legacy/*.tpl", "options": {"tabWidth": 2}}
+			]
+		}`)},
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "a.tpl", want: "map[parser:html tabWidth:4]"},
+		{path: "nested/b.tmpl", want: "map[parser:html tabWidth:4]"},
+		{path: "legacy/c.tpl", want: "map[parser:html tabWidth:2]"},
+		{path: "vendor/d.tpl", want: "map[tabWidth:8]"},
+		{path: "e.js", want: "map[tabWidth:8]"},
+	}
+
+	r := runner.NewRunner()
+	for _, tc := range tests {
+		got, err := r.ResolveConfig(context.Background(), runner.RunArgs{FS: fsys}, tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != tc.want {
+			t.Errorf("%s: got: %v, want: %v", tc.path, got, tc.want)
+		}
+	}
+
+	// Options take precedence over the overrides of the config file.
+	got, err := r.ResolveConfig(context.Background(), runner.RunArgs{FS: fsys, Options: map[string]any{"tabWidth": 3}}, "a.tpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "map[parser:html tabWidth:3]"; fmt.Sprint(got) != want {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"b.md": {Data: []byte("#  b\n")},
+		"a.md": {Data: []byte("# a\n")},
+	}
+
+	var manifest bytes.Buffer
+	r := runner.NewRunner()
+	if _, err := r.Run(context.Background(), runner.RunArgs{Patterns: []string{"."}, FS: fsys, Check: true, Manifest: &manifest, Stdout: io.Discard}); err == nil {
+		t.Fatal("expected check to fail")
+	}
+
+	var got struct {
+		PrettierVersion string `json:"prettierVersion"`
+		Files           []struct {
+			Path   string `json:"path"`
+			SHA256 string `json:"sha256"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(manifest.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.PrettierVersion == "" {
+		t.Error("missing prettier version")
+	}
+	// Hashes are of the formatted contents, sorted by path.
+	want := []string{"a.md", "# a\n", "b.md", "# b\n"}
+	if len(got.Files) != 2 {
+		t.Fatalf("unexpected manifest: %s", manifest.String())
+	}
+	for i, f := range got.Files {
+		sum := sha256.Sum256([]byte(want[2*i+1]))
+		if f.Path != want[2*i] || f.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("unexpected manifest entry %d: %+v", i, f)
+		}
+	}
+}
+
+func TestRunResult(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"formatted.md":   {Data: []byte("# a\n")},
+		"changed.md":     {Data: []byte("#  a\n")},
+		"broken.js":      {Data: []byte("const = ;\n")},
+		"unknown.zz":     {Data: []byte("a\n")},
+		"dir/unknown.qq": {Data: []byte("a\n")},
+	}
+
+	notified := map[string]FileStatus{}
+	r := NewRunner()
+	res, err := r.Run(context.Background(), RunArgs{
+		Patterns:  []string{"formatted.md", "changed.md", "broken.js", "unknown.zz", "dir"},
+		FS:        fsys,
+		Write:     true,
+		WriteFile: func(string, []byte) error { return nil },
+		Stdout:    io.Discard,
+		OnFileResult: func(path string, status FileStatus, _ error) {
+			notified[path] = status
+		},
+	})
+	if err == nil {
+		t.Fatal("expected error for broken.js")
+	}
+
+	got := map[string]FileStatus{}
+	for _, f := range res.Files {
+		got[f.Path] = f.Status
+		if f.Status == StatusError && f.Err == nil {
+			t.Errorf("%s: missing error", f.Path)
+		}
+	}
+	// Files without a parser found by walking a directory are ignored.
+	want := map[string]FileStatus{
+		"formatted.md": StatusFormatted,
+		"changed.md":   StatusChanged,
+		"broken.js":    StatusError,
+		"unknown.zz":   StatusSkipped,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+	if fmt.Sprint(notified) != fmt.Sprint(want) {
+		t.Errorf("notified: %v, want: %v", notified, want)
+	}
+}
+
+type recordingProgress struct {
+	total     int
+	started   []string
+	completed map[string]FileStatus
+	failed    []string
+}
+
+func (p *recordingProgress) Discovered(total int) { p.total = total }
+func (p *recordingProgress) Started(path string)  { p.started = append(p.started, path) }
+func (p *recordingProgress) Completed(path string, status FileStatus) {
+	p.completed[path] = status
+}
+func (p *recordingProgress) Failed(path string, _ error) { p.failed = append(p.failed, path) }
+
+func TestProgress(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"a.md":      {Data: []byte("# a\n")},
+		"b.md":      {Data: []byte("#  b\n")},
+		"broken.js": {Data: []byte("const = ;\n")},
+	}
+
+	progress := &recordingProgress{completed: map[string]FileStatus{}}
+	r := NewRunner()
+	if _, err := r.Run(context.Background(), RunArgs{
+		Patterns: []string{"."},
+		FS:       fsys,
+		Stdout:   io.Discard,
+		Progress: progress,
+	}); err == nil {
+		t.Fatal("expected error for broken.js")
+	}
+
+	if progress.total != 3 || len(progress.started) != 3 {
+		t.Errorf("got %d discovered and %d started, want 3", progress.total, len(progress.started))
+	}
+	if want := "map[a.md:formatted b.md:changed]"; fmt.Sprint(progress.completed) != want {
+		t.Errorf("completed: %v, want: %v", progress.completed, want)
+	}
+	if want := "[broken.js]"; fmt.Sprint(progress.failed) != want {
+		t.Errorf("failed: %v, want: %v", progress.failed, want)
+	}
+}
+
+func TestRunStream(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"a.md":      {Data: []byte("# a\n")},
+		"b.md":      {Data: []byte("#  b\n")},
+		"broken.js": {Data: []byte("const = ;\n")},
+	}
+
+	r := NewRunner()
+	results, wait := r.RunStream(context.Background(), RunArgs{Patterns: []string{"."}, FS: fsys, Stdout: io.Discard})
+
+	got := map[string]FileStatus{}
+	for res := range results {
+		got[res.Path] = res.Status
+		if res.Status == StatusError && res.Message == "" {
+			t.Errorf("%s: missing message", res.Path)
+		}
+	}
+	if want := "map[a.md:formatted b.md:changed broken.js:error]"; fmt.Sprint(got) != want {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+
+	res, err := wait()
+	if err == nil {
+		t.Error("expected error for broken.js")
+	}
+	if len(res.Files) != 3 {
+		t.Errorf("got %d results, want 3", len(res.Files))
+	}
+}
+
+func TestRunnerOptions(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	r := NewRunner(WithConcurrency(1), WithCompilationCacheDir(t.TempDir()), WithStderr(&logs))
+
+	fsys := fstest.MapFS{
+		"a.md":   {Data: []byte("#  a\n")},
+		"b.md":   {Data: []byte("#  b\n")},
+		"c.zz":   {Data: []byte("c\n")},
+		"d.json": {Data: []byte("{}\n")},
+	}
+	_, err := r.Run(context.Background(), RunArgs{Patterns: []string{"a.md", "b.md", "c.zz", "d.json"}, FS: fsys, Check: true, Stdout: io.Discard})
+	if err == nil {
+		t.Fatal("expected check to fail")
+	}
+	for _, want := range []string{"level=WARN msg=a.md", `No parser could be inferred for file \"c.zz\"`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs missing %q: %s", want, logs.String())
+		}
+	}
+}
+
+func TestWithWasm(t *testing.T) {
+	t.Parallel()
+
+	r := NewRunner(WithWasm(wasm.Prettier))
+	got, err := r.Format(context.Background(), "a.md", []byte("#  a\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "# a\n" {
+		t.Errorf("got: %q", got)
+	}
+
+	// Invalid modules are returned as errors rather than panicking.
+	r = NewRunner(WithWasm([]byte("not wasm")), WithStderr(io.Discard))
+	defer r.Close(context.Background())
+	if err := r.Prewarm(context.Background()); err == nil || !strings.Contains(err.Error(), "failed to compile prettier") {
+		t.Errorf("got error %v, want compile error", err)
+	}
+	if _, err := r.Format(context.Background(), "a.md", []byte("#  a\n"), nil); err == nil {
+		t.Error("Format: expected error for invalid module")
+	}
+	if _, err := r.Run(context.Background(), RunArgs{Patterns: []string{"a.md"}, FS: fstest.MapFS{"a.md": {Data: []byte("#  a\n")}}}); err == nil {
+		t.Error("Run: expected error for invalid module")
+	}
+}
+
+func TestWithDefaultConfig(t *testing.T) {
+	t.Parallel()
+
+	r := NewRunner(WithDefaultConfig([]byte("tabWidth: 4\n")), WithStderr(io.Discard))
+	defer r.Close(context.Background())
+
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		args RunArgs
+		want string
+	}{
+		{
+			name: "no config file",
+			fsys: fstest.MapFS{},
+			want: "{\n    \"a\": 1\n}\n",
+		},
+		{
+			name: "config file",
+			fsys: fstest.MapFS{".prettierrc": {Data: []byte("{}")}},
+			want: "{\n  \"a\": 1\n}\n",
+		},
+		{
+			name: "no config",
+			fsys: fstest.MapFS{},
+			args: RunArgs{NoConfig: true},
+			want: "{\n  \"a\": 1\n}\n",
+		},
+	}
+	for _, tc := range tests {
+		tc.fsys["a.json"] = &fstest.MapFile{Data: []byte("{\n\"a\":1}")}
+		args := tc.args
+		args.Patterns = []string{"a.json"}
+		args.FS = tc.fsys
+		args.Stdout = io.Discard
+		pCfg, paths, err := r.Expand(context.Background(), args)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		out, err := r.Format(context.Background(), "a.json", tc.fsys["a.json"].Data, paths[0].Config(pCfg))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if string(out) != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, out, tc.want)
+		}
+	}
+
+	r = NewRunner(WithDefaultConfig([]byte("{")), WithStderr(io.Discard))
+	defer r.Close(context.Background())
+	if _, err := r.Format(context.Background(), "a.md", []byte("#  a\n"), nil); err == nil || !strings.Contains(err.Error(), "invalid default config") {
+		t.Errorf("got error %v, want invalid default config", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	t.Parallel()
+
+	// A cache directory under a file can't be created, so the runners share
+	// the compilation cache in memory.
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	newRunner := func() *Runner {
+		return NewRunner(WithCompilationCacheDir(filepath.Join(file, "cache")))
+	}
+
+	// Formatting with a cancellable context uses a separately compiled module.
+	cancellable, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	contexts := []context.Context{context.Background(), cancellable}
+
+	format := func(r *Runner) error {
+		for _, ctx := range contexts {
+			if _, err := r.Format(ctx, "test.md", []byte("# a\n"), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	r1 := newRunner()
+	r2 := newRunner()
+	for _, r := range []*Runner{r1, r2} {
+		if err := format(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := r1.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Closing again is a no-op.
+	if err := r1.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r1.Format(context.Background(), "test.md", []byte("# a\n"), nil); err == nil {
+		t.Error("expected error formatting with a closed runner")
+	}
+
+	// Runners sharing the compiled modules still work.
+	if err := format(r2); err != nil {
+		t.Errorf("formatting after closing another runner: %v", err)
+	}
+
+	if err := r2.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r2.Format(context.Background(), "test.md", []byte("# a\n"), nil); err == nil {
+		t.Error("expected error formatting with a closed runner")
+	}
+
+	// The modules are compiled again after all runners using them are closed.
+	r3 := newRunner()
+	defer r3.Close(context.Background())
+	if err := format(r3); err != nil {
+		t.Errorf("formatting after closing all runners: %v", err)
+	}
+}
+
+func TestGitOnly(t *testing.T) {
+	t.Parallel()
+
+	dir, git := newGitRepo(t, map[string]string{
+		"a.md":       "# a\n",
+		"sub/b.md":   "# b\n",
+		".gitignore": "ignored.md\n",
+	})
+	writeFiles(t, dir, map[string]string{
+		"untracked.md": "# untracked\n",
+		"ignored.md":   "# ignored\n",
+	})
+	// Staged but not committed files are tracked.
+	writeFiles(t, dir, map[string]string{"staged.md": "# staged\n"})
+	git("add", "staged.md")
+
+	r := runner.NewRunner(runner.WithStderr(io.Discard))
+	_, paths, err := r.Expand(context.Background(), runner.RunArgs{
+		Patterns: []string{"."},
+		Dir:      dir,
+		GitOnly:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := expandedPaths(paths), []string{".gitignore", "a.md", "staged.md", "sub/b.md"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Tracked files are matched from subdirectories of the repository.
+	_, paths, err = r.Expand(context.Background(), runner.RunArgs{
+		Patterns: []string{"."},
+		Dir:      filepath.Join(dir, "sub"),
+		GitOnly:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := expandedPaths(paths), []string{"b.md"}; !slices.Equal(got, want) {
+		t.Errorf("got %v in subdirectory, want %v", got, want)
+	}
+}
+
+func TestDirtyFirst(t *testing.T) {
+	t.Parallel()
+
+	dir, git := newGitRepo(t, map[string]string{
+		"a.md": "# a\n",
+		"b.md": "# b\n",
+		"c.md": "# c\n",
+		"d.md": "# d\n",
+		"e.md": "# e\n",
+	})
+	writeFiles(t, dir, map[string]string{
+		"d.md":         "#  d\n",
+		"b.md":         "#  b\n",
+		"untracked.md": "# untracked\n",
+	})
+	// Staged changes are uncommitted too.
+	writeFiles(t, dir, map[string]string{"c.md": "#  c\n"})
+	git("add", "c.md")
+
+	r := runner.NewRunner(runner.WithStderr(io.Discard))
+	_, paths, err := r.Expand(context.Background(), runner.RunArgs{
+		Patterns:   []string{"."},
+		Dir:        dir,
+		DirtyFirst: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"b.md", "c.md", "d.md", "untracked.md", "a.md", "e.md"}
+	if got := expandedPaths(paths); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Outside of a repository, the order is kept.
+	plain := t.TempDir()
+	writeFiles(t, plain, map[string]string{"a.md": "# a\n", "b.md": "# b\n"})
+	_, paths, err = r.Expand(context.Background(), runner.RunArgs{
+		Patterns:   []string{"."},
+		Dir:        plain,
+		DirtyFirst: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := expandedPaths(paths), []string{"a.md", "b.md"}; !slices.Equal(got, want) {
+		t.Errorf("got %v outside of a repository, want %v", got, want)
+	}
+}
+
+func TestChangedSince(t *testing.T) {
+	t.Parallel()
+
+	dir, git := newGitRepo(t, map[string]string{
+		".gitignore":   "ignored.md\n",
+		"a.md":         "# a\n",
+		"b.md":         "# b\n",
+		"dirty.md":     "# dirty\n",
+		"deleted.md":   "# deleted\n",
+		"renamed.md":   "# renamed\n",
+		"unchanged.md": "# unchanged\n",
+	})
+
+	// Changes on main after the branch point are not part of the branch.
+	git("checkout", "-q", "-b", "feature")
+	git("checkout", "-q", "main")
+	writeFiles(t, dir, map[string]string{"b.md": "#  b\n"})
+	git("commit", "-q", "-am", "main")
+
+	git("checkout", "-q", "feature")
+	writeFiles(t, dir, map[string]string{"a.md": "#  a\n"})
+	git("rm", "-q", "deleted.md")
+	git("mv", "renamed.md", "moved.md")
+	git("commit", "-q", "-am", "feature")
+	writeFiles(t, dir, map[string]string{
+		"dirty.md":     "#  dirty\n",
+		"untracked.md": "# untracked\n",
+		"ignored.md":   "# ignored\n",
+	})
+
+	r := runner.NewRunner(runner.WithStderr(io.Discard))
+	_, paths, err := r.Expand(context.Background(), runner.RunArgs{
+		Patterns:     []string{"."},
+		Dir:          dir,
+		ChangedSince: "main",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.md", "dirty.md", "moved.md", "untracked.md"}
+	if got := expandedPaths(paths); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Refs that don't exist fail the run.
+	if _, _, err := r.Expand(context.Background(), runner.RunArgs{
+		Patterns:     []string{"."},
+		Dir:          dir,
+		ChangedSince: "missing",
+	}); err == nil {
+		t.Error("expected error for missing ref")
+	}
+}
+
+func TestStaged(t *testing.T) {
+	t.Parallel()
+
+	dir, git := newGitRepo(t, map[string]string{
+		"full.md":     "# full\n",
+		"partial.md":  "# partial\n\ntext\n\n- item\n",
+		"unstaged.md": "# unstaged\n",
+	})
+	writeFiles(t, dir, map[string]string{
+		"full.md":    "#  full\n",
+		"partial.md": "#  partial\n\ntext\n\n- item\n",
+	})
+	git("add", "full.md", "partial.md")
+	// The second hunk of partial.md is not staged.
+	writeFiles(t, dir, map[string]string{
+		"partial.md":  "#  partial\n\ntext\n\n*  item\n",
+		"unstaged.md": "#  unstaged\n",
+	})
+
+	r := runner.NewRunner(runner.WithStderr(io.Discard))
+	res, err := r.Run(context.Background(), runner.RunArgs{
+		Patterns: []string{"."},
+		Dir:      dir,
+		Staged:   true,
+		Write:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(res.Files), 2; got != want {
+		t.Errorf("got %d files, want %d", got, want)
+	}
+
+	tests := []struct {
+		path     string
+		index    string
+		worktree string
+	}{
+		{
+			path:     "full.md",
+			index:    "# full\n",
+			worktree: "# full\n",
+		},
+		{
+			// Unstaged hunks are preserved in the working tree.
+			path:     "partial.md",
+			index:    "# partial\n\ntext\n\n- item\n",
+			worktree: "#  partial\n\ntext\n\n*  item\n",
+		},
+		{
+			path:     "unstaged.md",
+			index:    "# unstaged\n",
+			worktree: "#  unstaged\n",
+		},
+	}
+	for _, tc := range tests {
+		if got := git("show", ":"+tc.path); got != tc.index {
+			t.Errorf("%s: got index %q, want %q", tc.path, got, tc.index)
+		}
+		b, err := os.ReadFile(filepath.Join(dir, tc.path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(b); got != tc.worktree {
+			t.Errorf("%s: got working tree %q, want %q", tc.path, got, tc.worktree)
+		}
+	}
+}
+
+func TestGitSymlinkedCheckout(t *testing.T) {
+	t.Parallel()
+
+	dir, git := newGitRepo(t, map[string]string{
+		"sub/a.md": "# a\n",
+		"sub/b.md": "# b\n",
+	})
+	writeFiles(t, dir, map[string]string{
+		"sub/b.md":         "#  b\n",
+		"sub/untracked.md": "# untracked\n",
+		"sub/staged.md":    "#  staged\n",
+	})
+	git("add", "sub/staged.md")
+
+	// git reports paths under the resolved root of the repository, while
+	// files are found under the path of the checkout through the symlink.
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Skipf("unable to create symlink: %v", err)
+	}
+	sub := filepath.Join(link, "sub")
+
+	r := runner.NewRunner(runner.WithStderr(io.Discard))
+	tests := []struct {
+		name string
+		args runner.RunArgs
+		want []string
+	}{
+		{
+			name: "git only",
+			args: runner.RunArgs{GitOnly: true},
+			want: []string{"a.md", "b.md", "staged.md"},
+		},
+		{
+			name: "changed since",
+			args: runner.RunArgs{ChangedSince: "main"},
+			want: []string{"b.md", "staged.md", "untracked.md"},
+		},
+		{
+			name: "dirty first",
+			args: runner.RunArgs{DirtyFirst: true},
+			want: []string{"b.md", "staged.md", "untracked.md", "a.md"},
+		},
+	}
+	for _, tc := range tests {
+		args := tc.args
+		args.Patterns = []string{"."}
+		args.Dir = sub
+		_, paths, err := r.Expand(context.Background(), args)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := expandedPaths(paths); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	res, err := r.Run(context.Background(), runner.RunArgs{
+		Patterns: []string{"."},
+		Dir:      sub,
+		Staged:   true,
+		Write:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(res.Files), 1; got != want {
+		t.Errorf("staged: got %d files, want %d", got, want)
+	}
+	if got, want := git("show", ":sub/staged.md"), "# staged\n"; got != want {
+		t.Errorf("staged: got index %q, want %q", got, want)
+	}
+}
+
+// newGitRepo creates a git repository in a temporary directory with files
+// committed to its main branch, returning the directory and a function
+// running git in it.
+func newGitRepo(t *testing.T, files map[string]string) (string, func(args ...string) string) {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+		return string(out)
+	}
+
+	git("init", "-q", "-b", "main")
+	writeFiles(t, dir, files)
+	git("add", "-A")
+	git("commit", "-q", "-m", "initial")
+
+	return dir, git
+}
+
+// writeFiles writes files, keyed by slash-separated path, to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for path, content := range files {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// expandedPaths returns the slash-separated paths of paths.
+func expandedPaths(paths []runner.ExpandedPath) []string {
+	res := make([]string, len(paths))
+	for i, p := range paths {
+		res[i] = filepath.ToSlash(p.FilePath)
+	}
+	return res
+}
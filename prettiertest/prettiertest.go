@@ -0,0 +1,171 @@
+// Package prettiertest provides helpers for asserting in Go tests that files
+// are formatted with prettier.
+//
+// All helpers share a single runner so the prettier module is only compiled
+// once per test binary.
+package prettiertest
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/wasilibs/go-prettier/internal/diff"
+	"github.com/wasilibs/go-prettier/internal/runner"
+)
+
+var update = flag.Bool("prettiertest.update", false, "Update the golden files compared by FormatGolden.")
+
+var sharedRunner = sync.OnceValue(func() *runner.Runner {
+	return runner.NewRunner()
+})
+
+type formattedFile struct {
+	path string
+	in   []byte
+	out  []byte
+	err  error
+}
+
+// RequireFormatted fails t if any file in fsys is not formatted, reporting the
+// diff for each such file. A config file such as .prettierrc at the root of
+// fsys is used if present. Files for which no parser can be inferred are
+// skipped.
+func RequireFormatted(t testing.TB, fsys fs.FS) {
+	t.Helper()
+
+	files := formatAll(t, fsys, ".", loadConfig(t, fsys))
+
+	failed := false
+	for _, f := range files {
+		if f.err != nil {
+			t.Errorf("prettiertest: failed to format %s: %v", f.path, f.err)
+			failed = true
+			continue
+		}
+		if d := diff.Unified(f.path, f.path+" (formatted)", f.in, f.out); d != "" {
+			t.Errorf("prettiertest: %s is not formatted:\n%s", f.path, d)
+			failed = true
+		}
+	}
+
+	if failed {
+		t.FailNow()
+	}
+}
+
+// FormatGolden formats each file in the in subdirectory of dir and compares
+// it with the file at the same path in the out subdirectory, failing t on any
+// difference. A config file such as .prettierrc in dir is used if present.
+// When tests are run with -prettiertest.update, the out subdirectory is
+// written instead.
+func FormatGolden(t testing.TB, dir string) {
+	t.Helper()
+
+	fsys := os.DirFS(dir)
+	files := formatAll(t, fsys, "in", loadConfig(t, fsys))
+
+	for _, f := range files {
+		if f.err != nil {
+			t.Errorf("prettiertest: failed to format %s: %v", f.path, f.err)
+			continue
+		}
+
+		rel := path.Join("out", f.path[len("in/"):])
+		outPath := filepath.Join(dir, filepath.FromSlash(rel))
+
+		if *update {
+			if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+				t.Fatalf("prettiertest: failed to create golden directory: %v", err)
+			}
+			if err := os.WriteFile(outPath, f.out, 0o644); err != nil {
+				t.Fatalf("prettiertest: failed to write golden file: %v", err)
+			}
+			continue
+		}
+
+		want, err := fs.ReadFile(fsys, rel)
+		if err != nil {
+			t.Errorf("prettiertest: failed to read golden file, run with -prettiertest.update to create it: %v", err)
+			continue
+		}
+		if d := diff.Unified(rel, f.path+" (formatted)", want, f.out); d != "" {
+			t.Errorf("prettiertest: %s does not match golden file:\n%s", f.path, d)
+		}
+	}
+}
+
+// formatAll formats all files under root in fsys, omitting those for which no
+// parser could be inferred.
+func formatAll(t testing.TB, fsys fs.FS, root string, pCfg map[string]any) []formattedFile {
+	t.Helper()
+
+	var files []formattedFile
+	if err := fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		in, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, formattedFile{path: p, in: in})
+		return nil
+	}); err != nil {
+		t.Fatalf("prettiertest: failed to read files: %v", err)
+	}
+
+	r := sharedRunner()
+
+	var g errgroup.Group
+	g.SetLimit(runtime.NumCPU())
+	for i := range files {
+		f := &files[i]
+		g.Go(func() error {
+			f.out, f.err = r.Format(context.Background(), f.path, f.in, pCfg)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	res := files[:0]
+	for _, f := range files {
+		if !errors.Is(f.err, runner.ErrUnknownParser) {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+func loadConfig(t testing.TB, fsys fs.FS) map[string]any {
+	t.Helper()
+
+	for _, name := range runner.ConfigFileNames {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			continue
+		}
+		pCfg, err := runner.ParseConfig(b)
+		if err == nil {
+			pCfg, err = runner.ApplyPresets(pCfg, nil)
+		}
+		if err != nil {
+			t.Fatalf("prettiertest: failed to load config %s: %v", name, err)
+		}
+		return pCfg
+	}
+
+	return map[string]any{}
+}
@@ -0,0 +1,51 @@
+package prettiertest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRequireFormatted(t *testing.T) {
+	t.Parallel()
+
+	RequireFormatted(t, os.DirFS(filepath.Join("..", "testdata", "out")))
+}
+
+func TestFormatGolden(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	copyDir(t, filepath.Join("..", "testdata", "in"), filepath.Join(dir, "in"))
+	copyDir(t, filepath.Join("..", "testdata", "outtabwidth4"), filepath.Join(dir, "out"))
+	cfg, err := os.ReadFile(filepath.Join("..", "testdata", ".prettierrc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".prettierrc"), cfg, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	FormatGolden(t, dir)
+}
+
+func copyDir(t *testing.T, src string, dst string) {
+	t.Helper()
+
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		c, err := os.ReadFile(filepath.Join(src, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dst, e.Name()), c, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
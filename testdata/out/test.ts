@@ -0,0 +1,3 @@
+function hello() {
+  return "world";
+}